@@ -3,11 +3,26 @@ package constants
 const ProjectsCollectionName = "projects"
 const PriceTicksCollectionName = "price_ticks"
 const MediaAssetsCollectionName = "media_assets"
+const MediaBlobsCollectionName = "media_blobs"
+const MediaHashBlocklistCollectionName = "media_hash_blocklist"
 
 const RequestCollectionName = "requests"
 
 const SpheresCollectionName = "spheres"
 const SphereContentsCollectionName = "sphere_contents"
-const SphereMediaCollectionName = "sphere_media"
+const SphereContentEditsCollectionName = "sphere_content_edits"
+const SphereAuditCollectionName = "sphere_audit"
+const SphereReportsCollectionName = "sphere_reports"
+const SphereSanctionsCollectionName = "sphere_sanctions"
+const SphereReactionsCollectionName = "sphere_reactions"
+const SphereMembersCollectionName = "sphere_members"
+const SpherePollVotesCollectionName = "sphere_poll_votes"
+const SpheresMigrationsCollectionName = "spheres_migrations"
+
+const NotificationsCollectionName = "notifications"
+const NotificationPrefsCollectionName = "notification_prefs"
+
+// colección de typesense para la búsqueda de spheres
+const TypesenseSphereContentsCollection = "sphere_contents"
 
 const MintsCollectionName = "mints"
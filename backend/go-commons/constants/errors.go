@@ -3,3 +3,6 @@ package constants
 import "errors"
 
 var ErrNotReady = errors.New("object not ready")
+
+// el handler se queda con el mensaje (ack/nak/in-progress por su cuenta, ej: trabajos largos en workers)
+var ErrAckDeferred = errors.New("ack deferred to handler")
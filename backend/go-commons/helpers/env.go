@@ -42,3 +42,12 @@ func GetEnvDur(key string, def time.Duration) time.Duration {
 	}
 	return def
 }
+
+func GetEnvBool(k string, def bool) bool {
+	if v := os.Getenv(k); v != "" {
+		if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+			return b
+		}
+	}
+	return def
+}
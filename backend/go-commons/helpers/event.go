@@ -4,16 +4,43 @@ import "strings"
 
 func MatchesAnyEvent(event string, subs []string) bool {
 	for _, s := range subs {
-		if s == "*" || s == event {
+		if Matches(s, event) {
 			return true
 		}
-		// opcional: soportar prefix wildcard tipo "spheres.content.added.*"
-		if strings.HasSuffix(s, ".*") {
-			prefix := strings.TrimSuffix(s, ".*")
-			if strings.HasPrefix(event, prefix) {
-				return true
-			}
+	}
+	return false
+}
+
+// IsWildcardSubject indica si el subject usa "*" o ">".
+func IsWildcardSubject(subject string) bool {
+	for _, tok := range strings.Split(subject, ".") {
+		if tok == "*" || tok == ">" {
+			return true
 		}
 	}
 	return false
 }
+
+// ValidSubjectPattern valida un subject de cliente: sin tokens vacíos ni espacios,
+// wildcards solo como token completo y ">" solo al final.
+func ValidSubjectPattern(pattern string) bool {
+	if pattern == "" || strings.ContainsAny(pattern, " \t\r\n") {
+		return false
+	}
+	toks := strings.Split(pattern, ".")
+	for i, tok := range toks {
+		if tok == "" {
+			return false
+		}
+		if tok == ">" {
+			if i != len(toks)-1 {
+				return false
+			}
+			continue
+		}
+		if tok != "*" && strings.ContainsAny(tok, "*>") {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,9 @@
 package helpers
 
-import "strings"
+import (
+	"bytes"
+	"strings"
+)
 
 func SafeExtFromMime(mime, fallback string) string {
 	switch strings.ToLower(mime) {
@@ -16,6 +19,8 @@ func SafeExtFromMime(mime, fallback string) string {
 		return "mp4"
 	case "video/webm":
 		return "webm"
+	case "video/quicktime":
+		return "mov"
 	default:
 		f := strings.TrimLeft(fallback, ".")
 		if f == "" {
@@ -38,3 +43,40 @@ func ClassifyMime(mime string) string {
 		return "UNKNOWN"
 	}
 }
+
+// major brands de ISO BMFF que son video mp4/m4v
+var mp4Brands = map[string]bool{
+	"isom": true, "iso2": true, "iso3": true, "iso4": true, "iso5": true, "iso6": true,
+	"mp41": true, "mp42": true, "avc1": true, "dash": true, "MSNV": true,
+	"M4V ": true, "M4VH": true, "M4VP": true,
+}
+
+// SniffMime detecta el tipo real por los magic bytes (alcanza con los primeros 512).
+// Solo conoce los formatos que acepta el pipeline; "" si no es ninguno.
+func SniffMime(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("\xff\xd8\xff")):
+		return "image/jpeg"
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return "image/gif"
+	case len(head) >= 12 && bytes.Equal(head[:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WEBP")):
+		return "image/webp"
+	case bytes.HasPrefix(head, []byte("\x1a\x45\xdf\xa3")):
+		return "video/webm" // EBML (webm/mkv)
+	case len(head) >= 12 && bytes.Equal(head[4:8], []byte("ftyp")):
+		// ISO BMFF: el major brand dice qué es. HEIC/HEIF/AVIF (heic, mif1, avif...) usan
+		// el mismo contenedor pero son imágenes: no se aceptan.
+		brand := string(head[8:12])
+		if brand == "qt  " {
+			return "video/quicktime"
+		}
+		if mp4Brands[brand] {
+			return "video/mp4"
+		}
+		return ""
+	default:
+		return ""
+	}
+}
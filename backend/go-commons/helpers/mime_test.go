@@ -0,0 +1,42 @@
+package helpers
+
+import "testing"
+
+func ftyp(brand string) []byte {
+	return append([]byte("\x00\x00\x00\x18ftyp"+brand), "\x00\x00\x02\x00isommp41"...)
+}
+
+func TestSniffMime(t *testing.T) {
+	cases := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"jpeg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), "image/jpeg"},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), "image/png"},
+		{"gif87", []byte("GIF87a\x01\x00"), "image/gif"},
+		{"gif89", []byte("GIF89a\x01\x00"), "image/gif"},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), "image/webp"},
+		{"webm", []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81"), "video/webm"},
+		{"mov", ftyp("qt  "), "video/quicktime"},
+		{"isom", ftyp("isom"), "video/mp4"},
+		{"iso2", ftyp("iso2"), "video/mp4"},
+		{"mp41", ftyp("mp41"), "video/mp4"},
+		{"mp42", ftyp("mp42"), "video/mp4"},
+		{"avc1", ftyp("avc1"), "video/mp4"},
+		{"m4v", ftyp("M4V "), "video/mp4"},
+		{"heic", ftyp("heic"), ""},
+		{"heix", ftyp("heix"), ""},
+		{"mif1", ftyp("mif1"), ""},
+		{"msf1", ftyp("msf1"), ""},
+		{"avif", ftyp("avif"), ""},
+		{"short ftyp", []byte("\x00\x00\x00\x18ftyp"), ""},
+		{"text", []byte("hello world"), ""},
+		{"empty", nil, ""},
+	}
+	for _, c := range cases {
+		if got := SniffMime(c.head); got != c.want {
+			t.Errorf("%s: SniffMime = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
@@ -8,9 +8,15 @@ import (
 )
 
 func RewriteForClient(ps *v4.PresignedHTTPRequest) (clientBase, uploadURL string) {
+	return RewriteURLForClient(ps.URL)
+}
+
+// RewriteURLForClient: igual que RewriteForClient para una url suelta (ej: el action
+// de un presigned POST)
+func RewriteURLForClient(raw string) (clientBase, uploadURL string) {
 
-	uploadURL = ps.URL
-	u, _ := url.Parse(ps.URL)
+	uploadURL = raw
+	u, _ := url.Parse(raw)
 	rewriteHost := GetEnv("S3_ENDPOINT_REWRITE", "")
 	if rewriteHost != "" {
 		ru, _ := url.Parse(rewriteHost)
@@ -23,3 +29,15 @@ func RewriteForClient(ps *v4.PresignedHTTPRequest) (clientBase, uploadURL string
 
 	return
 }
+
+// UploadPolicy: condiciones del presigned POST. S3 rechaza el upload (antes de guardarlo)
+// si el tamaño, el Content-Type o la key no coinciden.
+func UploadPolicy(key, keyPrefix, contentType string, maxBytes int64) []interface{} {
+	return []interface{}{
+		[]interface{}{"content-length-range", 1, maxBytes},
+		map[string]string{"Content-Type": contentType},
+		[]interface{}{"starts-with", "$key", keyPrefix},
+		// la key exacta: con solo el prefijo se podrían pisar las variantes del asset
+		map[string]string{"key": key},
+	}
+}
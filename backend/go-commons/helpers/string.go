@@ -220,16 +220,26 @@ func IdToString(v any) string {
 	return ""
 }
 
+// Matches aplica la semántica de NATS: "*" es un token, ">" uno o más tokens al final.
 func Matches(pattern, subject string) bool {
 	if pattern == subject {
 		return true
 	}
-	// soporte tipo wildcard ">"
-	if strings.HasSuffix(pattern, ">") {
-		prefix := strings.TrimSuffix(pattern, ">")
-		return strings.HasPrefix(subject, prefix)
+
+	pt := strings.Split(pattern, ".")
+	st := strings.Split(subject, ".")
+	for i, tok := range pt {
+		if tok == ">" {
+			return i == len(pt)-1 && len(st) > i
+		}
+		if i >= len(st) {
+			return false
+		}
+		if tok != "*" && tok != st[i] {
+			return false
+		}
 	}
-	return false
+	return len(pt) == len(st)
 }
 
 func IsImageContentType(ct string) bool {
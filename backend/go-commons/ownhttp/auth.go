@@ -0,0 +1,212 @@
+package ownhttp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"moonmap.io/go-commons/helpers"
+)
+
+var (
+	ErrMissingToken = errors.New("missing token")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+)
+
+// subprotocol used to carry the token: "Sec-WebSocket-Protocol: bearer, <token>"
+const BearerSubprotocol = "bearer"
+
+type Principal struct {
+	UserID string         `json:"userId"`
+	Roles  []string       `json:"roles,omitempty"`
+	Claims map[string]any `json:"-"`
+}
+
+func (p *Principal) IsAnonymous() bool {
+	return p == nil || p.UserID == ""
+}
+
+func (p *Principal) HasRole(role string) bool {
+	if p == nil {
+		return false
+	}
+	for _, r := range p.Roles {
+		if strings.EqualFold(r, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenVerifier turns a raw token into a Principal.
+type TokenVerifier interface {
+	Verify(token string) (*Principal, error)
+}
+
+// HMACVerifier validates HS256 JWTs signed with a shared secret.
+// "sub" is the user id, "roles" (optional) a list of strings.
+type HMACVerifier struct {
+	Secret []byte
+	Leeway time.Duration
+}
+
+// AuthSecretEnv: variable con el secreto HS256, la misma en todos los servicios
+const AuthSecretEnv = "AUTH_SECRET"
+
+func NewHMACVerifier(secret string) *HMACVerifier {
+	return &HMACVerifier{Secret: []byte(secret), Leeway: 30 * time.Second}
+}
+
+func (v *HMACVerifier) Verify(token string) (*Principal, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	mac := hmac.New(sha256.New, v.Secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, ErrInvalidToken
+	}
+
+	claims := map[string]any{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(v.Leeway)) {
+		return nil, ErrExpiredToken
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, ErrInvalidToken
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, ErrInvalidToken
+	}
+
+	p := &Principal{UserID: sub, Claims: claims}
+	if roles, ok := claims["roles"].([]any); ok {
+		for _, r := range roles {
+			if s, ok := r.(string); ok && s != "" {
+				p.Roles = append(p.Roles, s)
+			}
+		}
+	}
+	return p, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// TokenFromRequest looks for a token in the Authorization header, the query
+// string (token|access_token) or the websocket subprotocol list.
+// viaSubprotocol reports whether the token came from Sec-WebSocket-Protocol.
+func TokenFromRequest(r *http.Request) (token string, viaSubprotocol bool) {
+	if h := r.Header.Get("Authorization"); h != "" {
+		if len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+			return strings.TrimSpace(h[7:]), false
+		}
+	}
+
+	q := r.URL.Query()
+	if t := helpers.FirstNonEmpty(q.Get("token"), q.Get("access_token")); t != "" {
+		return strings.TrimSpace(t), false
+	}
+
+	protocols := websocketSubprotocols(r)
+	for i, p := range protocols {
+		if strings.EqualFold(p, BearerSubprotocol) && i+1 < len(protocols) {
+			return protocols[i+1], true
+		}
+	}
+
+	return "", false
+}
+
+func websocketSubprotocols(r *http.Request) []string {
+	out := []string{}
+	for _, h := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(h, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				out = append(out, p)
+			}
+		}
+	}
+	return out
+}
+
+type principalCtxKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey{}, p)
+}
+
+func PrincipalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalCtxKey{}).(*Principal)
+	return p
+}
+
+// WithAuth exige un token válido y deja el Principal en el contexto del request.
+func WithAuth(v TokenVerifier, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if v == nil {
+			WriteJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", "authentication not configured")
+			return
+		}
+
+		token, _ := TokenFromRequest(r)
+		p, err := v.Verify(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="moonmap"`)
+			WriteJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+			return
+		}
+
+		h(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	}
+}
+
+// WithOptionalAuth deja el Principal si viene un token (inválido => 401); sin token el
+// request sigue como anónimo.
+func WithOptionalAuth(v TokenVerifier, h http.HandlerFunc) http.HandlerFunc {
+	auth := WithAuth(v, h)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token, _ := TokenFromRequest(r); token == "" || v == nil {
+			h(w, r)
+			return
+		}
+		auth(w, r)
+	}
+}
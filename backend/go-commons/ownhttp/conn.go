@@ -1,11 +1,10 @@
 package ownhttp
 
 import (
-	"encoding/json"
-	"fmt"
 	"io"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -16,28 +15,63 @@ import (
 type ConnectionOptions struct {
 	Action   string   `json:"action"`
 	Subjects []string `json:"subjects"`
+	Token    string   `json:"token,omitempty"`
+	FromSeq  uint64   `json:"fromSeq,omitempty"`
+	SphereID string   `json:"sphereId,omitempty"`
+}
+
+// ErrorFrame is sent back to the client when an action is rejected.
+type ErrorFrame struct {
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+	Action   string   `json:"action,omitempty"`
+	Subjects []string `json:"subjects,omitempty"`
 }
 
 type SocketConnection struct {
-	ID    string
-	Hub   *Hub
-	Conn  *websocket.Conn
-	Mutex *sync.Mutex
-	done  chan struct{}
-	once  sync.Once
-	Subs  map[string]struct{}
+	ID        string
+	Hub       *Hub
+	Conn      *websocket.Conn
+	Mutex     *sync.Mutex
+	done      chan struct{}
+	once      sync.Once
+	Subs      map[string]struct{}
+	Principal *Principal
+	authed    atomic.Bool
+	send      chan liveFrame
+	t         transport
+	encoding  string
+	Dropped   atomic.Uint64 // frames descartados por cola llena (modo drop)
+	evicting  atomic.Bool
+
+	rmu    sync.Mutex
+	replay *replayState // guarded by rmu
 }
 
 func NewSocketConnection(c *websocket.Conn) *SocketConnection {
 	return &SocketConnection{
-		Conn:  c,
-		ID:    ksuid.New().String(),
-		Mutex: &sync.Mutex{},
-		done:  make(chan struct{}),
-		Subs:  make(map[string]struct{}),
+		Conn:     c,
+		ID:       ksuid.New().String(),
+		Mutex:    &sync.Mutex{},
+		done:     make(chan struct{}),
+		Subs:     make(map[string]struct{}),
+		send:     make(chan liveFrame, defaultSendQueue),
+		t:        &wsTransport{conn: c},
+		encoding: EncodingJSON,
+	}
+}
+
+func (s *SocketConnection) setEncoding(encoding string) {
+	s.encoding = encoding
+	if wt, ok := s.t.(*wsTransport); ok {
+		wt.binary = encoding == EncodingMsgpack
 	}
 }
 
+func (s *SocketConnection) Encoding() string {
+	return s.encoding
+}
+
 func (s *SocketConnection) Close() {
 	s.once.Do(func() {
 		s.Hub.mu.Lock()
@@ -45,18 +79,104 @@ func (s *SocketConnection) Close() {
 		s.Hub.removeAllSubsUnsafe(s)
 		s.Hub.mu.Unlock()
 
-		_ = s.Conn.Close()
+		if s.Hub.Rooms != nil {
+			s.Hub.Rooms.Disconnect(s)
+		}
+
+		_ = s.t.close()
 		close(s.done)
 		logrus.WithField("id", s.ID).Info("connection closed")
 	})
 }
 
 func (s *SocketConnection) Init() {
-	s.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+	data := map[string]any{"connectionId": s.ID, "authenticated": s.IsAuthenticated(), "encoding": s.encoding}
+	if !s.Principal.IsAnonymous() {
+		data["userId"] = s.Principal.UserID
+	}
+	_ = s.WriteJSON(map[string]any{"event": "init", "data": data})
+}
+
+func (s *SocketConnection) IsAuthenticated() bool {
+	return s.authed.Load()
+}
+
+// WriteJSON escribe v directo (sin cola) con la encoding negociada.
+func (s *SocketConnection) WriteJSON(v any) error {
+	b, err := marshalFor(s.encoding, v)
+	if err != nil {
+		return err
+	}
 	s.Mutex.Lock()
 	defer s.Mutex.Unlock()
-	msg := fmt.Sprintf(`{"event":"init","data":{"connectionId":"%s"}}`, s.ID)
-	_ = s.Conn.WriteMessage(websocket.TextMessage, []byte(msg))
+	return s.t.writeFrame(0, b)
+}
+
+// writeLive encola un evento en vivo, o lo retiene si hay un replay en curso.
+func (s *SocketConnection) writeLive(subject string, seq uint64, b []byte) error {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+
+	if s.replay != nil {
+		if len(s.replay.frames) >= maxReplayBuffer {
+			s.replay.overflow = true
+			return nil
+		}
+		s.replay.frames = append(s.replay.frames, liveFrame{subject: subject, seq: seq, b: b})
+		return nil
+	}
+	return s.enqueue(liveFrame{subject: subject, seq: seq, b: b})
+}
+
+func (s *SocketConnection) WriteError(code, message, action string, subjects []string) {
+	frame := ErrorFrame{Code: code, Message: message, Action: action, Subjects: subjects}
+	if err := s.WriteJSON(map[string]any{"event": "error", "data": frame}); err != nil {
+		logrus.WithError(err).WithField("id", s.ID).Warn("error frame write failed")
+	}
+}
+
+func (s *SocketConnection) authenticate(token string) {
+	if s.IsAuthenticated() {
+		s.WriteError("ALREADY_AUTHENTICATED", "session already authenticated", "auth", nil)
+		return
+	}
+
+	p, err := s.Hub.Verifier.Verify(token)
+	if err != nil {
+		s.WriteError("UNAUTHORIZED", err.Error(), "auth", nil)
+		s.closeWithCode(websocket.ClosePolicyViolation, "unauthorized")
+		return
+	}
+
+	s.Hub.mu.Lock()
+	s.Principal = p
+	s.Hub.mu.Unlock()
+	s.authed.Store(true)
+
+	_ = s.WriteJSON(map[string]any{"event": "auth", "data": map[string]any{"userId": p.UserID}})
+	s.MainLog().WithField("userId", p.UserID).Info("ws session authenticated")
+}
+
+// awaitAuth closes sessions that did not send an auth frame in time.
+func (s *SocketConnection) awaitAuth(timeout time.Duration) {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+
+	select {
+	case <-s.done:
+	case <-t.C:
+		if !s.IsAuthenticated() {
+			s.WriteError("AUTH_TIMEOUT", "auth frame not received", "auth", nil)
+			s.closeWithCode(websocket.ClosePolicyViolation, "auth timeout")
+		}
+	}
+}
+
+func (s *SocketConnection) closeWithCode(code int, text string) {
+	s.Mutex.Lock()
+	s.t.closeFrame(code, text)
+	s.Mutex.Unlock()
+	s.Close()
 }
 
 func (s *SocketConnection) Config() {
@@ -107,12 +227,16 @@ func (s *SocketConnection) Read() {
 			return
 		}
 
-		if mt != websocket.TextMessage {
+		// texto = JSON; binario solo si se negoció msgpack
+		frameEncoding := EncodingJSON
+		if mt == websocket.BinaryMessage && s.encoding == EncodingMsgpack {
+			frameEncoding = EncodingMsgpack
+		} else if mt != websocket.TextMessage {
 			_, _ = io.Copy(io.Discard, r)
 			continue
 		}
 
-		if !s.Hub.IsSubjectMode() {
+		if !s.Hub.IsSubjectMode() && s.IsAuthenticated() {
 			_, _ = io.Copy(io.Discard, r)
 			continue
 		}
@@ -120,10 +244,7 @@ func (s *SocketConnection) Read() {
 		lr := &io.LimitedReader{R: r, N: 1<<20 + 1} // 1 MiB + 1 for overflow
 
 		var opts ConnectionOptions
-		dec := json.NewDecoder(lr)
-		dec.DisallowUnknownFields()
-
-		if err := dec.Decode(&opts); err != nil {
+		if err := decodeFor(frameEncoding, lr, &opts); err != nil {
 			logrus.WithError(err).Warn("invalid subscription frame")
 			_, _ = io.Copy(io.Discard, lr)
 			continue
@@ -134,16 +255,38 @@ func (s *SocketConnection) Read() {
 			continue
 		}
 
-		switch strings.ToLower(strings.TrimSpace(opts.Action)) {
+		action := strings.ToLower(strings.TrimSpace(opts.Action))
+		if action != "auth" && !s.IsAuthenticated() {
+			s.WriteError("UNAUTHENTICATED", "send an auth frame first", action, opts.Subjects)
+			_, _ = io.Copy(io.Discard, lr)
+			continue
+		}
+
+		switch action {
+		case "auth":
+			if s.Hub.Verifier == nil {
+				s.WriteError("AUTH_DISABLED", "hub does not require auth", action, nil)
+				break
+			}
+			s.authenticate(opts.Token)
 		case "subscribe":
 			s.Hub.subscribe(s, opts.Subjects)
 		case "unsubscribe":
 			s.Hub.unsubscribe(s, opts.Subjects)
+		case "resume":
+			s.Hub.resume(s, opts.Subjects, opts.FromSeq)
+		case "join":
+			s.Hub.join(s, opts.SphereID)
+		case "leave":
+			s.Hub.leave(s, opts.SphereID)
+		case "typing":
+			s.Hub.typing(s, opts.SphereID)
 		default:
 			s.MainLog().WithFields(logrus.Fields{
 				"action": opts.Action,
 				"subs":   opts.Subjects,
 			}).Error("unable to take hub action")
+			s.WriteError("UNKNOWN_ACTION", "unknown action", opts.Action, opts.Subjects)
 		}
 
 		_, _ = io.Copy(io.Discard, lr)
@@ -161,8 +304,7 @@ func (s *SocketConnection) StartPinger() {
 			return
 		case <-t.C:
 			s.Mutex.Lock()
-			s.Conn.SetWriteDeadline(time.Now().Add(writeWait))
-			err := s.Conn.WriteMessage(websocket.PingMessage, nil)
+			err := s.t.ping()
 			s.Mutex.Unlock()
 			if err != nil {
 				logrus.WithError(err).Warn("ping failed")
@@ -176,7 +318,8 @@ func (s *SocketConnection) StartPinger() {
 func (s *SocketConnection) MainLog() *logrus.Entry {
 	return logrus.WithFields(logrus.Fields{
 		"id":         s.ID,
-		"remoteAddr": s.Conn.UnderlyingConn().RemoteAddr().String(),
+		"remoteAddr": s.t.remoteAddr(),
+		"transport":  s.t.name(),
 		"subjects":   s.Subs,
 	})
 }
@@ -0,0 +1,80 @@
+package ownhttp
+
+import (
+	"errors"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultSendQueue = 256
+
+const (
+	SlowConsumerEvict = "evict"
+	SlowConsumerDrop  = "drop"
+)
+
+var (
+	errSlowConsumer = errors.New("slow consumer")
+	errConnClosed   = errors.New("connection closed")
+)
+
+// writePump es el único que escribe los frames encolados; un cliente lento solo
+// se frena a sí mismo y no al broadcast.
+func (s *SocketConnection) writePump() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case f := <-s.send:
+			s.Mutex.Lock()
+			err := s.t.writeFrame(f.seq, f.b)
+			s.Mutex.Unlock()
+			if err != nil {
+				logrus.WithError(err).WithField("id", s.ID).Warn("ws write failed; closing client")
+				s.Close()
+				return
+			}
+		}
+	}
+}
+
+// enqueue no bloquea: con la cola llena aplica la política de slow consumer del Hub.
+func (s *SocketConnection) enqueue(f liveFrame) error {
+	select {
+	case s.send <- f:
+		return nil
+	case <-s.done:
+		return errConnClosed
+	default:
+	}
+
+	if s.Hub != nil && s.Hub.SlowConsumer == SlowConsumerDrop {
+		s.Dropped.Add(1)
+		s.Hub.dropped.Add(1)
+		return nil
+	}
+	return errSlowConsumer
+}
+
+// enqueueWait bloquea hasta que haya lugar; se usa en el replay, donde no se puede perder nada.
+func (s *SocketConnection) enqueueWait(f liveFrame) error {
+	select {
+	case s.send <- f:
+		return nil
+	case <-s.done:
+		return errConnClosed
+	}
+}
+
+func (s *SocketConnection) evict() {
+	if s.evicting.Swap(true) {
+		return
+	}
+	if s.Hub != nil {
+		s.Hub.evicted.Add(1)
+	}
+	s.MainLog().WithField("queued", len(s.send)).Warn("slow consumer evicted")
+	s.WriteError("SLOW_CONSUMER", "outbound queue full", "", nil)
+	s.closeWithCode(websocket.ClosePolicyViolation, "slow consumer")
+}
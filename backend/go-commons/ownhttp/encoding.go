@@ -0,0 +1,99 @@
+package ownhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// encodings negociadas por Sec-WebSocket-Protocol; json es el default
+const (
+	EncodingJSON    = "json"
+	EncodingMsgpack = "msgpack"
+)
+
+type eventFrame struct {
+	Event string `json:"event"`
+	Seq   uint64 `json:"seq,omitempty"`
+	Data  any    `json:"data"`
+}
+
+// RawJSON viaja tal cual en JSON (sin base64) y como estructura decodificada en msgpack.
+type RawJSON []byte
+
+func (r RawJSON) MarshalJSON() ([]byte, error) {
+	if len(r) == 0 {
+		return []byte("null"), nil
+	}
+	if !json.Valid(r) {
+		// payload no JSON: mandarlo como string
+		return json.Marshal(string(r))
+	}
+	return r, nil
+}
+
+func (r *RawJSON) UnmarshalJSON(b []byte) error {
+	*r = append((*r)[:0], b...)
+	return nil
+}
+
+func (r RawJSON) EncodeMsgpack(enc *msgpack.Encoder) error {
+	var v any
+	if len(r) == 0 || json.Unmarshal(r, &v) != nil {
+		if len(r) == 0 {
+			return enc.EncodeNil()
+		}
+		return enc.EncodeString(string(r))
+	}
+	return enc.Encode(v)
+}
+
+func marshalFor(encoding string, v any) ([]byte, error) {
+	if encoding != EncodingMsgpack {
+		return json.Marshal(v)
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json") // mismos nombres/omitempty que en JSON
+	enc.SetSortMapKeys(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeFor(encoding string, r io.Reader, v any) error {
+	if encoding != EncodingMsgpack {
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		return dec.Decode(v)
+	}
+
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	dec.DisallowUnknownFields(true)
+	return dec.Decode(v)
+}
+
+// negotiateSubprotocol elige la encoding ofrecida por el cliente y qué subprotocolo
+// devolver (solo se puede devolver uno: la encoding gana sobre "bearer").
+func negotiateSubprotocol(r *http.Request, bearerOffered bool) (encoding, echo string) {
+	encoding = EncodingJSON
+	for _, p := range websocketSubprotocols(r) {
+		switch strings.ToLower(p) {
+		case EncodingMsgpack:
+			return EncodingMsgpack, EncodingMsgpack
+		case EncodingJSON:
+			echo = EncodingJSON
+		}
+	}
+	if echo == "" && bearerOffered {
+		echo = BearerSubprotocol
+	}
+	return encoding, echo
+}
@@ -94,18 +94,50 @@ func LogRequest(r *http.Request) {
 		"client_ip":   clientIP(r),
 		"remote_addr": r.RemoteAddr,
 		"proto":       r.Proto,
-		"uri":         r.RequestURI,
+		"uri":         redactURI(r),
 	}
 
 	// dinámicamente convertir headers → fields
 	for _, h := range constants.HeaderList {
 		key := strings.ReplaceAll(strings.ToLower(h), "-", "_")
-		fields[key] = r.Header.Get(h)
+		v := r.Header.Get(h)
+		if h == constants.HeaderWSSubprotocol && v != "" {
+			v = redactSubprotocols(r)
+		}
+		fields[key] = v
 	}
 
 	logrus.WithFields(fields).Info("new request")
 }
 
+// no loguear tokens que vengan en la query
+func redactURI(r *http.Request) string {
+	q := r.URL.Query()
+	changed := false
+	for _, k := range []string{"token", "access_token"} {
+		if q.Has(k) {
+			q.Set(k, "REDACTED")
+			changed = true
+		}
+	}
+	if !changed {
+		return r.RequestURI
+	}
+	u := *r.URL
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
+
+func redactSubprotocols(r *http.Request) string {
+	protocols := websocketSubprotocols(r)
+	for i, p := range protocols {
+		if strings.EqualFold(p, BearerSubprotocol) && i+1 < len(protocols) {
+			protocols[i+1] = "REDACTED"
+		}
+	}
+	return strings.Join(protocols, ", ")
+}
+
 func clientIP(r *http.Request) string {
 	if v := r.Header.Get(constants.HeaderCFConnectingIP); v != "" {
 		return v
@@ -1,10 +1,13 @@
 package ownhttp
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -25,41 +28,125 @@ type Hub struct {
 	Clients  map[string]*SocketConnection
 	Subjects map[string]map[string]*SocketConnection
 	closing  bool
+	trie     *subjectTrie
+
+	// señal (sin bloquear) cada vez que cambia el set de subjects con interés
+	interest chan struct{}
+
+	// Rooms maneja join/leave/typing (presencia); nil = no soportado
+	Rooms RoomHandler
+
+	// Replay sirve los resume; nil = resume no soportado
+	Replay ReplayFunc
+
+	// cola de salida por conexión y qué hacer cuando se llena (evict|drop)
+	SendQueueSize int
+	SlowConsumer  string
+	dropped       atomic.Uint64
+	evicted       atomic.Uint64
+
+	// MaxSubsPerConn limita los subjects (incluye wildcards) por conexión; 0 = sin límite
+	MaxSubsPerConn int
+
+	// auth: when Verifier is nil every session is anonymous
+	Verifier       TokenVerifier
+	Authorize      func(p *Principal, subject string) bool
+	AuthTimeout    time.Duration
+	AllowedOrigins []string
 }
 
 func NewHub() *Hub {
-	return &Hub{
-		Mode:     "all",
-		Clients:  make(map[string]*SocketConnection),
-		Subjects: make(map[string]map[string]*SocketConnection),
-		upgrader: websocket.Upgrader{
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
-			CheckOrigin: func(r *http.Request) bool {
-				return true
-			},
-		},
+	h := &Hub{
+		Mode:           "all",
+		Clients:        make(map[string]*SocketConnection),
+		Subjects:       make(map[string]map[string]*SocketConnection),
+		trie:           newSubjectTrie(),
+		interest:       make(chan struct{}, 1),
+		MaxSubsPerConn: helpers.GetEnvInt("WS_MAX_SUBSCRIPTIONS", 100),
+		SendQueueSize:  helpers.GetEnvInt("WS_SEND_QUEUE", defaultSendQueue),
+		SlowConsumer:   strings.ToLower(helpers.GetEnv("WS_SLOW_CONSUMER", SlowConsumerEvict)),
+		AuthTimeout:    10 * time.Second,
+		AllowedOrigins: helpers.FilterEmpty(strings.Split(helpers.GetEnv("ALLOW_ORIGIN", "*"), ",")),
+	}
+	h.upgrader = websocket.Upgrader{
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		CheckOrigin:       h.checkOrigin,
+		EnableCompression: helpers.GetEnvBool("WS_COMPRESSION", true), // permessage-deflate si el cliente lo ofrece
+	}
+	return h
+}
+
+func (h *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// non browser clients
+		return true
+	}
+	for _, o := range h.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
 	}
+	logrus.WithField("origin", origin).Warn("ws origin rejected")
+	return false
+}
+
+func (h *Hub) authorized(p *Principal, subject string) bool {
+	if h.Authorize == nil {
+		return true
+	}
+	return h.Authorize(p, subject)
 }
 
 func (h *Hub) addSubsUnsafe(s *SocketConnection, subjects []string) {
 	for _, subj := range subjects {
 		if h.Subjects[subj] == nil {
 			h.Subjects[subj] = make(map[string]*SocketConnection)
+			h.signalInterest()
 		}
 		if _, ok := h.Subjects[subj][s.ID]; !ok {
 			h.Subjects[subj][s.ID] = s
+			h.trie.insert(subj, s)
 			s.Subs[subj] = struct{}{}
 		}
 	}
 }
 
+func (h *Hub) signalInterest() {
+	select {
+	case h.interest <- struct{}{}:
+	default:
+	}
+}
+
+// InterestChanged avisa cuando se agrega el primer cliente de un subject o se va el último.
+func (h *Hub) InterestChanged() <-chan struct{} {
+	return h.interest
+}
+
+// Patterns devuelve el set de subjects (con wildcards) que tienen al menos un cliente.
+func (h *Hub) Patterns() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make([]string, 0, len(h.Subjects))
+	for subj := range h.Subjects {
+		out = append(out, subj)
+	}
+	sort.Strings(out)
+	return out
+}
+
 func (h *Hub) removeSubsUnsafe(s *SocketConnection, subjects []string) {
 	for _, subj := range subjects {
 		if subs, ok := h.Subjects[subj]; ok {
+			if _, had := subs[s.ID]; had {
+				h.trie.remove(subj, s.ID)
+			}
 			delete(subs, s.ID)
 			if len(subs) == 0 {
 				delete(h.Subjects, subj)
+				h.signalInterest()
 			}
 		}
 		delete(s.Subs, subj)
@@ -92,7 +179,25 @@ func (h *Hub) Add(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	c, err := h.upgrader.Upgrade(w, r, nil)
+	var principal *Principal
+	token, viaSubprotocol := TokenFromRequest(r)
+	if h.Verifier != nil && token != "" {
+		p, err := h.Verifier.Verify(token)
+		if err != nil {
+			WriteJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+			return
+		}
+		principal = p
+	}
+
+	// browsers drop the socket if the offered subprotocol is not echoed
+	encoding, echo := negotiateSubprotocol(r, viaSubprotocol)
+	var respHeader http.Header
+	if echo != "" {
+		respHeader = http.Header{"Sec-WebSocket-Protocol": {echo}}
+	}
+
+	c, err := h.upgrader.Upgrade(w, r, respHeader)
 	if err != nil {
 		WriteJSONError(w, http.StatusBadRequest, "BAD_REQUEST", "bad request")
 		return
@@ -102,6 +207,14 @@ func (h *Hub) Add(w http.ResponseWriter, r *http.Request) {
 
 	conn := NewSocketConnection(c)
 	conn.Hub = h
+	conn.setEncoding(encoding)
+	if h.SendQueueSize > 0 {
+		conn.send = make(chan liveFrame, h.SendQueueSize)
+	}
+	conn.Principal = principal
+	if h.Verifier == nil || principal != nil {
+		conn.authed.Store(true)
+	}
 
 	h.mu.Lock()
 	h.Clients[conn.ID] = conn
@@ -109,18 +222,22 @@ func (h *Hub) Add(w http.ResponseWriter, r *http.Request) {
 
 	conn.Init()
 	go conn.Read()
+	go conn.writePump()
 	go conn.StartPinger()
+
+	if !conn.IsAuthenticated() {
+		go conn.awaitAuth(h.AuthTimeout)
+	}
 }
 
 func (h *Hub) BroadcastJSON(eventName string, data any) {
-	if h.closing {
-		return
-	}
+	h.BroadcastSeqJSON(eventName, 0, data)
+}
 
-	payload := map[string]any{"event": eventName, "data": data}
-	b, err := json.Marshal(payload)
-	if err != nil {
-		logrus.WithError(err).Error("broadcast json marshal failed")
+// BroadcastSeqJSON incluye la secuencia del stream en el frame ("seq") para que
+// el cliente pueda pedir un resume desde ahí.
+func (h *Hub) BroadcastSeqJSON(eventName string, seq uint64, data any) {
+	if h.closing {
 		return
 	}
 
@@ -129,11 +246,7 @@ func (h *Hub) BroadcastJSON(eventName string, data any) {
 	if h.Mode == "all" {
 		targets = h.Clients
 	} else {
-		if subs, ok := h.Subjects[eventName]; ok {
-			targets = subs
-		} else {
-			targets = nil
-		}
+		targets = h.trie.match(eventName)
 	}
 
 	if len(targets) == 0 {
@@ -147,31 +260,96 @@ func (h *Hub) BroadcastJSON(eventName string, data any) {
 	}
 	h.mu.RUnlock()
 
+	// cada encoding se codifica una sola vez y el frame se comparte entre todas las colas
+	encoded := map[string][]byte{}
 	for _, s := range snapshot {
-		if s == nil || s.Conn == nil {
+		if s == nil || s.t == nil || !s.IsAuthenticated() {
 			continue
 		}
 
-		s.Mutex.Lock()
-		s.Conn.SetWriteDeadline(time.Now().Add(writeWait))
-		err := s.Conn.WriteMessage(websocket.TextMessage, b)
-		s.Mutex.Unlock()
-		if err != nil {
-			logrus.WithError(err).Warn("broadcast failed; closing client")
-			s.Close()
+		b, ok := encoded[s.encoding]
+		if !ok {
+			var err error
+			if b, err = encodeEvent(s.encoding, eventName, seq, data); err != nil {
+				logrus.WithError(err).WithField("encoding", s.encoding).Error("broadcast marshal failed")
+				return
+			}
+			encoded[s.encoding] = b
+		}
+
+		if err := s.writeLive(eventName, seq, b); errors.Is(err, errSlowConsumer) {
+			go s.evict()
 		}
 	}
 }
 
 func (h *Hub) subscribe(s *SocketConnection, subjects []string) {
+	h.subscribeAs(s, subjects, "subscribe")
+}
+
+// subscribeAs valida, autoriza y aplica el límite; devuelve los subjects que quedaron
+// activos para la conexión (nuevos o ya existentes).
+func (h *Hub) subscribeAs(s *SocketConnection, subjects []string, action string) []string {
 	sanitized := helpers.SanitizeStrings(subjects)
 	if len(sanitized) == 0 {
-		return
+		return nil
+	}
+
+	allowed := make([]string, 0, len(sanitized))
+	denied := []string{}
+	invalid := []string{}
+	for _, subj := range sanitized {
+		if !helpers.ValidSubjectPattern(subj) {
+			invalid = append(invalid, subj)
+			continue
+		}
+		if h.authorized(s.Principal, subj) {
+			allowed = append(allowed, subj)
+		} else {
+			denied = append(denied, subj)
+		}
+	}
+
+	if len(invalid) > 0 {
+		s.WriteError("INVALID_SUBJECT", "malformed subject", action, invalid)
 	}
+
+	if len(denied) > 0 {
+		s.MainLog().WithField("op", action).WithField("denied", denied).Warn("client subscription rejected")
+		s.WriteError("FORBIDDEN", "not allowed to subscribe", action, denied)
+	}
+
+	if len(allowed) == 0 {
+		return nil
+	}
+
 	h.mu.Lock()
-	defer h.mu.Unlock()
-	h.addSubsUnsafe(s, sanitized)
-	s.MainLog().WithField("op", "subscribe").WithField("subs", sanitized).Info("client subscribed")
+	active := make([]string, 0, len(allowed))
+	added := make([]string, 0, len(allowed))
+	overLimit := []string{}
+	for _, subj := range allowed {
+		if _, ok := s.Subs[subj]; ok {
+			active = append(active, subj)
+			continue
+		}
+		if h.MaxSubsPerConn > 0 && len(s.Subs)+len(added) >= h.MaxSubsPerConn {
+			overLimit = append(overLimit, subj)
+			continue
+		}
+		added = append(added, subj)
+		active = append(active, subj)
+	}
+	h.addSubsUnsafe(s, added)
+	h.mu.Unlock()
+
+	if len(overLimit) > 0 {
+		s.MainLog().WithField("op", action).WithField("rejected", overLimit).Warn("client subscription limit reached")
+		s.WriteError("LIMIT_EXCEEDED", fmt.Sprintf("max %d subscriptions per connection", h.MaxSubsPerConn), action, overLimit)
+	}
+	if len(added) > 0 {
+		s.MainLog().WithField("op", action).WithField("subs", added).Info("client subscribed")
+	}
+	return active
 }
 
 func (h *Hub) unsubscribe(s *SocketConnection, subjects []string) {
@@ -192,6 +370,49 @@ func (h *Hub) unsubscribe(s *SocketConnection, subjects []string) {
 	s.MainLog().WithField("op", op).WithField("subs", sanitized).Info("client unsubscribed")
 }
 
+// DroppedCount y EvictedCount: totales por slow consumers desde que arrancó el Hub.
+func (h *Hub) DroppedCount() uint64 {
+	return h.dropped.Load()
+}
+
+func (h *Hub) EvictedCount() uint64 {
+	return h.evicted.Load()
+}
+
+type HubStats struct {
+	Mode          string         `json:"mode"`
+	Clients       int            `json:"clients"`
+	Subjects      int            `json:"subjects"`
+	Subscriptions int            `json:"subscriptions"`
+	BySubject     map[string]int `json:"bySubject"`
+	ByTransport   map[string]int `json:"byTransport"`
+	Dropped       uint64         `json:"dropped"`
+	Evicted       uint64         `json:"evicted"`
+}
+
+func (h *Hub) Stats() HubStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	st := HubStats{
+		Mode:        h.Mode,
+		Clients:     len(h.Clients),
+		Subjects:    len(h.Subjects),
+		BySubject:   make(map[string]int, len(h.Subjects)),
+		ByTransport: map[string]int{},
+		Dropped:     h.dropped.Load(),
+		Evicted:     h.evicted.Load(),
+	}
+	for _, c := range h.Clients {
+		st.ByTransport[c.t.name()]++
+	}
+	for subj, conns := range h.Subjects {
+		st.BySubject[subj] = len(conns)
+		st.Subscriptions += len(conns)
+	}
+	return st
+}
+
 func (h *Hub) GetClientsLength() int {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -0,0 +1,142 @@
+package ownhttp
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countTransport descarta los frames y avisa por wg cada vez que escribe uno
+type countTransport struct {
+	wg *sync.WaitGroup
+}
+
+func (t *countTransport) name() string                    { return "bench" }
+func (t *countTransport) writeFrame(uint64, []byte) error { t.wg.Done(); return nil }
+func (t *countTransport) ping() error                     { return nil }
+func (t *countTransport) closeFrame(int, string)          {}
+func (t *countTransport) close() error                    { return nil }
+func (t *countTransport) remoteAddr() string              { return "bench" }
+
+func newTestConn(h *Hub, id string, t transport, queue int) *SocketConnection {
+	s := &SocketConnection{
+		ID:       id,
+		Hub:      h,
+		Mutex:    &sync.Mutex{},
+		done:     make(chan struct{}),
+		Subs:     make(map[string]struct{}),
+		send:     make(chan liveFrame, queue),
+		t:        t,
+		encoding: EncodingJSON,
+	}
+	s.authed.Store(true)
+	return s
+}
+
+// BenchmarkBroadcastFanout: un broadcast hasta que los N clientes escribieron el frame
+// (encode + match en el trie + encolado + writePump de cada conexión).
+func BenchmarkBroadcastFanout(b *testing.B) {
+	for _, n := range []int{1_000, 10_000} {
+		b.Run(fmt.Sprintf("clients=%d", n), func(b *testing.B) {
+			h := NewHub()
+			h.Mode = "subjects"
+
+			wg := &sync.WaitGroup{}
+			conns := make([]*SocketConnection, 0, n)
+			h.mu.Lock()
+			for i := range n {
+				s := newTestConn(h, fmt.Sprintf("c%d", i), &countTransport{wg: wg}, defaultSendQueue)
+				h.Clients[s.ID] = s
+				h.addSubsUnsafe(s, []string{"spheres.content.added.*"})
+				conns = append(conns, s)
+			}
+			h.mu.Unlock()
+			for _, s := range conns {
+				go s.writePump()
+			}
+			defer func() {
+				for _, s := range conns {
+					close(s.done)
+				}
+			}()
+
+			payload := map[string]any{"id": "65f0c0ffee", "text": "gm", "sphereId": "s1"}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := range b.N {
+				wg.Add(n)
+				h.BroadcastSeqJSON("spheres.content.added.s1", uint64(i+1), payload)
+				wg.Wait()
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/delivery")
+			if d := h.DroppedCount() + h.EvictedCount(); d > 0 {
+				b.Fatalf("unexpected drops/evictions: %d", d)
+			}
+		})
+	}
+}
+
+// blockTransport no termina de escribir hasta que se cierra release (cliente lento)
+type blockTransport struct {
+	countTransport
+	release chan struct{}
+}
+
+func (t *blockTransport) writeFrame(uint64, []byte) error {
+	<-t.release
+	return nil
+}
+
+// Un cliente lento vaciando su buffer de replay no puede frenar writeLive (el broadcast).
+func TestFinishReplayDoesNotHoldLockWhileBlocked(t *testing.T) {
+	h := NewHub()
+	slow := &blockTransport{release: make(chan struct{})}
+	s := newTestConn(h, "slow", slow, 1)
+	defer close(s.done)
+	go s.writePump()
+
+	if !s.beginReplay([]string{"a.b"}) {
+		t.Fatal("beginReplay failed")
+	}
+	for i := range 8 {
+		if err := s.writeLive("a.b", uint64(i+1), []byte("x")); err != nil {
+			t.Fatalf("buffered writeLive: %v", err)
+		}
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		s.finishReplay(0)
+		close(finished)
+	}()
+
+	// finishReplay queda bloqueado en enqueueWait; writeLive tiene que volver igual
+	time.Sleep(50 * time.Millisecond)
+	returned := make(chan struct{})
+	go func() {
+		_ = s.writeLive("a.b", 100, []byte("y"))
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("writeLive blocked behind finishReplay")
+	}
+
+	close(slow.release)
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("finishReplay did not finish")
+	}
+
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	if s.replay != nil {
+		t.Fatal("replay state not cleared")
+	}
+}
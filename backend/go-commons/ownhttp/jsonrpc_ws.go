@@ -0,0 +1,367 @@
+package ownhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultRPCTimeout = 10 * time.Second
+
+var ErrRPCNotConnected = errors.New("jsonrpc ws not connected")
+
+type RPCRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      uint64 `json:"id"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// RPCMessage covers both responses (id + result|error) and
+// subscription notifications (method + params.subscription).
+type RPCMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *uint64         `json:"id,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  *struct {
+		Subscription json.RawMessage `json:"subscription"`
+	} `json:"params,omitempty"`
+}
+
+// RPCSubscription describes a server side subscription (ej. logsSubscribe / logsUnsubscribe).
+// Handler receives the raw notification frame.
+type RPCSubscription struct {
+	Name        string
+	Method      string
+	Unsubscribe string
+	Params      any
+	Handler     func(data []byte)
+
+	serverID string
+	gen      uint64
+	inflight bool
+}
+
+// SubscriptionID returns the id assigned by the server on the current connection.
+func (s *RPCSubscription) SubscriptionID() string {
+	return s.serverID
+}
+
+func (m *ManagedWS) rpcTimeout() time.Duration {
+	if m.RPCTimeout > 0 {
+		return m.RPCTimeout
+	}
+	return defaultRPCTimeout
+}
+
+// pendingCall: caller waiting for a response. For subscribe calls sub/gen let the
+// reader register the server id before it reads the next frame, so notifications
+// sent right after the confirmation are not lost.
+type pendingCall struct {
+	ch  chan RPCMessage
+	sub *RPCSubscription
+	gen uint64
+}
+
+func (m *ManagedWS) initRPC() {
+	m.rpcOnce.Do(func() {
+		m.pending = make(map[uint64]pendingCall)
+		m.subs = make(map[*RPCSubscription]struct{})
+		m.bySubID = make(map[string]*RPCSubscription)
+	})
+}
+
+// Call sends a JSON-RPC request and waits for its response within the rpc timeout.
+func (m *ManagedWS) Call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	m.initRPC()
+	return m.call(ctx, method, params, nil, 0)
+}
+
+func (m *ManagedWS) call(ctx context.Context, method string, params any, sub *RPCSubscription, gen uint64) (json.RawMessage, error) {
+	id := m.nextID.Add(1)
+	ch := make(chan RPCMessage, 1)
+
+	m.mu.Lock()
+	m.pending[id] = pendingCall{ch: ch, sub: sub, gen: gen}
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.pending, id)
+		m.mu.Unlock()
+	}()
+
+	req := RPCRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params}
+	if err := m.SendJSON(req); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(m.rpcTimeout())
+	defer timer.Stop()
+
+	select {
+	case res := <-ch:
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		return res.Result, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("ws %s: %s id=%d timed out after %s", m.Name, method, id, m.rpcTimeout())
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Subscribe registers the subscription in the active set and, if the socket is
+// connected, waits for the server confirmation. When disconnected it will be
+// sent on the next successful connect. A failed subscribe stays in the set and
+// is retried in the background (and on every reconnect) until it is confirmed
+// or Unsubscribe is called.
+func (m *ManagedWS) Subscribe(ctx context.Context, sub *RPCSubscription) error {
+	m.initRPC()
+
+	m.mu.Lock()
+	m.subs[sub] = struct{}{}
+	connected := m.conn != nil
+	m.mu.Unlock()
+
+	if !connected {
+		logrus.WithField("method", sub.Method).Infof("ws %s not connected; subscription queued", m.Name)
+		return nil
+	}
+
+	err := m.subscribeOne(ctx, sub)
+	if err != nil {
+		go m.retrySubscribe(ctx, sub)
+	}
+	return err
+}
+
+// retrySubscribe keeps trying a failed subscription with backoff. It stops once the
+// subscription is confirmed, removed from the set or the socket is closed; while
+// disconnected it leaves the work to resubscribe.
+func (m *ManagedWS) retrySubscribe(ctx context.Context, sub *RPCSubscription) {
+	backoff := time.Second
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+
+		m.mu.Lock()
+		_, active := m.subs[sub]
+		done := !active || m.closing.Load() || (sub.serverID != "" && sub.gen == m.gen)
+		connected := m.conn != nil
+		m.mu.Unlock()
+		if done {
+			return
+		}
+		if !connected {
+			continue
+		}
+
+		if err := m.subscribeOne(ctx, sub); err != nil {
+			logrus.WithError(err).WithField("method", sub.Method).Warnf("ws %s subscribe retry failed", m.Name)
+			continue
+		}
+		return
+	}
+}
+
+// Unsubscribe removes the subscription from the active set and tells the server.
+func (m *ManagedWS) Unsubscribe(ctx context.Context, sub *RPCSubscription) error {
+	m.initRPC()
+
+	m.mu.Lock()
+	delete(m.subs, sub)
+	serverID := sub.serverID
+	live := serverID != "" && sub.gen == m.gen && m.conn != nil
+	if serverID != "" {
+		delete(m.bySubID, serverID)
+	}
+	sub.serverID = ""
+	m.mu.Unlock()
+
+	if !live || sub.Unsubscribe == "" {
+		return nil
+	}
+
+	res, err := m.Call(ctx, sub.Unsubscribe, []json.RawMessage{json.RawMessage(serverID)})
+	if err != nil {
+		return err
+	}
+
+	var ok bool
+	if json.Unmarshal(res, &ok) == nil && !ok {
+		return fmt.Errorf("ws %s: %s rejected for subscription %s", m.Name, sub.Unsubscribe, serverID)
+	}
+
+	logrus.WithFields(logrus.Fields{"method": sub.Unsubscribe, "subscription": serverID}).Infof("ws %s unsubscribed", m.Name)
+	return nil
+}
+
+func (m *ManagedWS) subscribeOne(ctx context.Context, sub *RPCSubscription) error {
+	m.mu.Lock()
+	if sub.inflight || (sub.serverID != "" && sub.gen == m.gen) {
+		m.mu.Unlock()
+		return nil
+	}
+	sub.inflight = true
+	gen := m.gen
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		sub.inflight = false
+		m.mu.Unlock()
+	}()
+
+	res, err := m.call(ctx, sub.Method, sub.Params, sub, gen)
+	if err != nil {
+		return fmt.Errorf("ws %s: %s failed: %w", m.Name, sub.Method, err)
+	}
+
+	serverID := string(res)
+	if serverID == "" || serverID == "null" {
+		return fmt.Errorf("ws %s: %s returned empty subscription id", m.Name, sub.Method)
+	}
+
+	m.mu.Lock()
+	registered := sub.serverID == serverID && sub.gen == gen
+	m.mu.Unlock()
+	if !registered {
+		// unsubscribed or reconnected while waiting; the server side id is stale
+		return nil
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"method":       sub.Method,
+		"name":         sub.Name,
+		"subscription": serverID,
+	}).Infof("ws %s subscription confirmed", m.Name)
+	return nil
+}
+
+// registerLocked binds the server id to the subscription. Called by the reader
+// (m.mu held) when the subscribe response arrives.
+func (m *ManagedWS) registerLocked(sub *RPCSubscription, gen uint64, result json.RawMessage) {
+	serverID := string(result)
+	if serverID == "" || serverID == "null" {
+		return
+	}
+	if _, active := m.subs[sub]; !active || gen != m.gen {
+		return
+	}
+	if sub.serverID != "" {
+		delete(m.bySubID, sub.serverID)
+	}
+	sub.serverID = serverID
+	sub.gen = gen
+	m.bySubID[serverID] = sub
+}
+
+// resubscribe re-sends every subscription in the active set after a reconnect; the
+// ones that fail go to retrySubscribe.
+func (m *ManagedWS) resubscribe(ctx context.Context) {
+	m.mu.Lock()
+	active := make([]*RPCSubscription, 0, len(m.subs))
+	for sub := range m.subs {
+		active = append(active, sub)
+	}
+	m.mu.Unlock()
+
+	for _, sub := range active {
+		if ctx.Err() != nil || m.closing.Load() {
+			return
+		}
+		if err := m.subscribeOne(ctx, sub); err != nil {
+			logrus.WithError(err).WithField("method", sub.Method).Errorf("ws %s resubscribe failed", m.Name)
+			if m.OnStatus != nil {
+				m.OnStatus(m.Name, "SUBSCRIBE_FAILED")
+			}
+			go m.retrySubscribe(ctx, sub)
+		}
+	}
+}
+
+func (m *ManagedWS) unsubscribeAll() {
+	m.mu.Lock()
+	active := make([]*RPCSubscription, 0, len(m.subs))
+	for sub := range m.subs {
+		active = append(active, sub)
+	}
+	m.mu.Unlock()
+
+	// every call gets its own rpc timeout (see call)
+	for _, sub := range active {
+		if err := m.Unsubscribe(context.Background(), sub); err != nil {
+			logrus.WithError(err).WithField("method", sub.Unsubscribe).Warnf("ws %s unsubscribe failed", m.Name)
+		}
+	}
+}
+
+// dispatch routes responses to the waiting caller and notifications to the
+// handler owning the subscription id. Returns false for unknown frames.
+func (m *ManagedWS) dispatch(data []byte) bool {
+	var msg RPCMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return false
+	}
+
+	if msg.ID != nil {
+		m.mu.Lock()
+		pc, ok := m.pending[*msg.ID]
+		if ok && pc.sub != nil && msg.Error == nil {
+			m.registerLocked(pc.sub, pc.gen, msg.Result)
+		}
+		m.mu.Unlock()
+		if !ok {
+			logrus.WithField("id", *msg.ID).Debugf("ws %s response without caller", m.Name)
+			return true
+		}
+		select {
+		case pc.ch <- msg:
+		default:
+		}
+		return true
+	}
+
+	if msg.Params == nil || len(msg.Params.Subscription) == 0 {
+		return false
+	}
+
+	m.mu.Lock()
+	sub := m.bySubID[string(msg.Params.Subscription)]
+	m.mu.Unlock()
+
+	if sub == nil || sub.Handler == nil {
+		logrus.WithFields(logrus.Fields{
+			"method":       msg.Method,
+			"subscription": string(msg.Params.Subscription),
+		}).Debugf("ws %s notification for unknown subscription", m.Name)
+		return true
+	}
+
+	sub.Handler(data)
+	return true
+}
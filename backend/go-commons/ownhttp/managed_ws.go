@@ -12,17 +12,30 @@ import (
 )
 
 type ManagedWS struct {
-	Name      string
-	Url       string
-	conn      *websocket.Conn
-	healthy   atomic.Bool
-	mu        sync.Mutex
-	Messages  chan []byte
-	OnConnect func(*websocket.Conn) error
-	OnStatus  func(name, status string)
+	Name       string
+	Url        string
+	conn       *websocket.Conn
+	client     *WSClient
+	healthy    atomic.Bool
+	closing    atomic.Bool // Close in progress: no reconnect or resubscribe
+	closed     atomic.Bool // reader stops
+	mu         sync.Mutex
+	wmu        sync.Mutex
+	OnConnect  func(*websocket.Conn) error
+	OnStatus   func(name, status string)
+	RPCTimeout time.Duration
+
+	// json-rpc state
+	rpcOnce sync.Once
+	nextID  atomic.Uint64
+	gen     uint64
+	pending map[uint64]pendingCall
+	subs    map[*RPCSubscription]struct{}
+	bySubID map[string]*RPCSubscription
 }
 
 func (m *ManagedWS) Start(ctx context.Context) {
+	m.initRPC()
 	go m.readerLoop(ctx)
 }
 
@@ -30,24 +43,59 @@ func (m *ManagedWS) IsHealthy() bool {
 	return m.healthy.Load()
 }
 
+// Close unsubscribes the active set and closes the socket without reconnecting.
+// The reader keeps running until the unsubscribe responses are in.
 func (m *ManagedWS) Close() {
-	if m.conn != nil {
-		_ = m.conn.Close()
+	if m.closing.Swap(true) {
+		return
 	}
+
+	m.initRPC()
+	m.unsubscribeAll()
+	m.closed.Store(true)
+
+	m.mu.Lock()
+	conn := m.conn
+	m.mu.Unlock()
+
+	if conn != nil {
+		m.wmu.Lock()
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye"))
+		m.wmu.Unlock()
+	}
+	m.dropConn()
 }
 
 func (m *ManagedWS) SendJSON(v interface{}) error {
-	if m.conn == nil {
-		return fmt.Errorf("ws %s not connected", m.Name)
+	m.mu.Lock()
+	conn := m.conn
+	m.mu.Unlock()
+
+	if conn == nil {
+		return fmt.Errorf("ws %s not connected: %w", m.Name, ErrRPCNotConnected)
 	}
-	return m.conn.WriteJSON(v)
+
+	m.wmu.Lock()
+	defer m.wmu.Unlock()
+	return conn.WriteJSON(v)
 }
 
 func (m *ManagedWS) readerLoop(ctx context.Context) {
 	backoff := time.Second
 
 	for {
-		if m.conn == nil {
+		if m.closed.Load() {
+			return
+		}
+
+		m.mu.Lock()
+		conn := m.conn
+		m.mu.Unlock()
+
+		if conn == nil {
+			if ctx.Err() != nil || m.closing.Load() {
+				return
+			}
 			if err := m.reconnect(ctx); err != nil {
 				logrus.WithError(err).Errorf("failed to connect ws %s", m.Name)
 				time.Sleep(backoff)
@@ -57,16 +105,22 @@ func (m *ManagedWS) readerLoop(ctx context.Context) {
 				continue
 			}
 			backoff = time.Second
+			go m.resubscribe(ctx)
+			continue
 		}
 
-		_, data, err := m.conn.ReadMessage()
+		_, data, err := conn.ReadMessage()
 		if err != nil {
+			if m.closing.Load() {
+				m.dropConn()
+				return
+			}
 			logrus.WithError(err).Warnf("ws %s closed, reconnecting...", m.Name)
 			m.healthy.Store(false)
 			if m.OnStatus != nil {
 				m.OnStatus(m.Name, "DOWN")
 			}
-			m.conn = nil
+			m.dropConn()
 			continue
 		}
 
@@ -77,36 +131,49 @@ func (m *ManagedWS) readerLoop(ctx context.Context) {
 			}
 		}
 
-		select {
-		case m.Messages <- data:
-		case <-ctx.Done():
-			return
+		if !m.dispatch(data) {
+			logrus.WithField("bytes", len(data)).Debugf("ws %s dropping unknown frame", m.Name)
 		}
 	}
 }
 
-func (m *ManagedWS) reconnect(ctx context.Context) error {
+// dropConn forgets the current socket; server side subscription ids die with it.
+func (m *ManagedWS) dropConn() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if m.conn != nil {
-		_ = m.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "reconnect"))
-		_ = m.conn.Close()
-		m.conn = nil
+	if m.client != nil {
+		m.client.Close()
+		m.client = nil
+	}
+	m.conn = nil
+	for id, sub := range m.bySubID {
+		sub.serverID = ""
+		delete(m.bySubID, id)
 	}
+}
+
+func (m *ManagedWS) reconnect(ctx context.Context) error {
+	m.dropConn()
 
 	client, err := NewWSClient(ctx, m.Url)
 	if err != nil {
 		return err
 	}
 
-	m.conn = client.Conn
 	if m.OnConnect != nil {
-		if err := m.OnConnect(m.conn); err != nil {
+		if err := m.OnConnect(client.Conn); err != nil {
+			client.Close()
 			return err
 		}
 	}
 
-	logrus.Infof("ws %v connected and resubscribed url=%s", m.Name, m.Url)
+	m.mu.Lock()
+	m.client = client
+	m.conn = client.Conn
+	m.gen++
+	m.mu.Unlock()
+
+	logrus.Infof("ws %v connected url=%s", m.Name, m.Url)
 	return nil
 }
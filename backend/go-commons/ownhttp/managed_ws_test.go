@@ -0,0 +1,130 @@
+package ownhttp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// rpcServer: json-rpc mínimo sobre ws. logsSubscribe devuelve ids crecientes (o error
+// para las primeras failSubs llamadas), logsUnsubscribe responde true.
+type rpcServer struct {
+	subs     atomic.Int32
+	unsubs   atomic.Int32
+	failSubs atomic.Int32
+}
+
+func (s *rpcServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	up := websocket.Upgrader{}
+	conn, err := up.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for {
+		var req RPCRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		res := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "logsSubscribe":
+			if s.failSubs.Add(-1) >= 0 {
+				res["error"] = RPCError{Code: -32000, Message: "busy"}
+			} else {
+				res["result"] = s.subs.Add(1)
+			}
+		case "logsUnsubscribe":
+			s.unsubs.Add(1)
+			res["result"] = true
+		}
+		if err := conn.WriteJSON(res); err != nil {
+			return
+		}
+	}
+}
+
+func startManaged(t *testing.T, srv *rpcServer) *ManagedWS {
+	t.Helper()
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	m := &ManagedWS{Name: "test", Url: "ws" + strings.TrimPrefix(ts.URL, "http"), RPCTimeout: 5 * time.Second}
+	m.Start(ctx)
+	return m
+}
+
+func logsSub(name string) *RPCSubscription {
+	return &RPCSubscription{
+		Name:        name,
+		Method:      "logsSubscribe",
+		Unsubscribe: "logsUnsubscribe",
+		Params:      []any{json.RawMessage(`{"mentions":["x"]}`)},
+	}
+}
+
+func waitConfirmed(t *testing.T, m *ManagedWS, within time.Duration, subs ...*RPCSubscription) {
+	t.Helper()
+	deadline := time.Now().Add(within)
+	for {
+		// serverID lo escribe el reader bajo m.mu
+		m.mu.Lock()
+		done := true
+		for _, s := range subs {
+			done = done && s.serverID != ""
+		}
+		m.mu.Unlock()
+		if done {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("subscriptions not confirmed in time")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestManagedWSCloseUnsubscribesAll(t *testing.T) {
+	srv := &rpcServer{}
+	m := startManaged(t, srv)
+
+	subs := []*RPCSubscription{logsSub("a"), logsSub("b"), logsSub("c")}
+	for _, s := range subs {
+		if err := m.Subscribe(context.Background(), s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitConfirmed(t, m, 3*time.Second, subs...)
+
+	start := time.Now()
+	m.Close()
+	if took := time.Since(start); took > time.Second {
+		t.Fatalf("Close took %s", took)
+	}
+	if n := srv.unsubs.Load(); n != 3 {
+		t.Fatalf("server got %d unsubscribes, want 3", n)
+	}
+}
+
+func TestManagedWSResubscribeRetries(t *testing.T) {
+	srv := &rpcServer{}
+	srv.failSubs.Store(1)
+	m := startManaged(t, srv)
+
+	// la primera respuesta es un error (venga de Subscribe o de resubscribe al
+	// conectar): queda en el set y se reintenta hasta que el server la confirma
+	sub := logsSub("a")
+	_ = m.Subscribe(context.Background(), sub)
+	waitConfirmed(t, m, 4*time.Second, sub)
+	m.Close()
+}
@@ -0,0 +1,312 @@
+package ownhttp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/sirupsen/logrus"
+	"moonmap.io/go-commons/helpers"
+)
+
+// RateLimit es un token bucket: Burst tokens de capacidad, recarga Rate por segundo.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+	Key   KeyFunc
+}
+
+// Per arma un límite de n requests por período (burst = n).
+func Per(n int, period time.Duration, key KeyFunc) RateLimit {
+	return RateLimit{Rate: float64(n) / period.Seconds(), Burst: n, Key: key}
+}
+
+// window: tiempo en recargar el bucket entero (para RateLimit-Policy)
+func (l RateLimit) window() time.Duration {
+	if l.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// ParseRateLimit acepta "<n>/<s|m|h>" con burst opcional: "10/m", "10/m:20".
+func ParseRateLimit(s string) (RateLimit, error) {
+	var l RateLimit
+	spec, burst, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+	nStr, unit, ok := strings.Cut(spec, "/")
+	if !ok {
+		return l, fmt.Errorf("rate limit %q: expected <n>/<s|m|h>", s)
+	}
+	n, err := strconv.Atoi(nStr)
+	if err != nil || n <= 0 {
+		return l, fmt.Errorf("rate limit %q: invalid count", s)
+	}
+	var period time.Duration
+	switch unit {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		return l, fmt.Errorf("rate limit %q: invalid unit", s)
+	}
+	l = Per(n, period, nil)
+	if hasBurst {
+		b, err := strconv.Atoi(burst)
+		if err != nil || b <= 0 {
+			return l, fmt.Errorf("rate limit %q: invalid burst", s)
+		}
+		l.Burst = b
+	}
+	return l, nil
+}
+
+// KeyFunc decide a quién se le cobra el request.
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP usa clientIP (CF-Connecting-IP, X-Real-IP, X-Forwarded-For).
+func KeyByIP(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// KeyByUser usa el principal autenticado; sin principal cae a la IP.
+func KeyByUser(r *http.Request) string {
+	if p := PrincipalFrom(r.Context()); !p.IsAnonymous() {
+		return "user:" + p.UserID
+	}
+	return KeyByIP(r)
+}
+
+// KeyByRoute comparte un bucket entre todos los clientes de la ruta.
+func KeyByRoute(r *http.Request) string {
+	return "route"
+}
+
+type RateLimitDecision struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration // hasta el próximo token (si no se permitió)
+	Reset      time.Duration // hasta que el bucket esté lleno
+}
+
+// RateLimitStore guarda los buckets; las réplicas comparten estado si el store es compartido.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitDecision, error)
+}
+
+type bucketState struct {
+	Tokens float64 `json:"t"`
+	Last   int64   `json:"l"` // unix nanos
+}
+
+// take recarga el bucket hasta now y consume un token si hay.
+func (b *bucketState) take(limit RateLimit, now time.Time) RateLimitDecision {
+	burst := float64(limit.Burst)
+	if b.Last == 0 {
+		b.Tokens = burst
+	} else if elapsed := now.Sub(time.Unix(0, b.Last)).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(burst, b.Tokens+elapsed*limit.Rate)
+	}
+	b.Last = now.UnixNano()
+
+	d := RateLimitDecision{}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		d.Allowed = true
+	} else if limit.Rate > 0 {
+		d.RetryAfter = time.Duration((1 - b.Tokens) / limit.Rate * float64(time.Second))
+	}
+	d.Remaining = int(b.Tokens)
+	if limit.Rate > 0 {
+		d.Reset = time.Duration((burst - b.Tokens) / limit.Rate * float64(time.Second))
+	}
+	return d
+}
+
+// MemoryRateStore: buckets por proceso.
+type MemoryRateStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memBucket
+	takes   int
+}
+
+type memBucket struct {
+	bucketState
+	idleAfter time.Time
+}
+
+func NewMemoryRateStore() *MemoryRateStore {
+	return &MemoryRateStore{buckets: map[string]*memBucket{}}
+}
+
+func (m *MemoryRateStore) Take(_ context.Context, key string, limit RateLimit, now time.Time) (RateLimitDecision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// limpieza perezosa de buckets llenos
+	m.takes++
+	if m.takes%4096 == 0 {
+		for k, b := range m.buckets {
+			if now.After(b.idleAfter) {
+				delete(m.buckets, k)
+			}
+		}
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &memBucket{}
+		m.buckets[key] = b
+	}
+	d := b.take(limit, now)
+	b.idleAfter = now.Add(d.Reset)
+	return d, nil
+}
+
+// KVRateStore comparte los buckets entre réplicas en un bucket KV de NATS
+// (compare-and-set por revisión; conviene crearlo con TTL para que expiren).
+type KVRateStore struct {
+	KV      jetstream.KeyValue
+	Retries int
+}
+
+func NewKVRateStore(kv jetstream.KeyValue) *KVRateStore {
+	return &KVRateStore{KV: kv, Retries: 5}
+}
+
+func (s *KVRateStore) Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitDecision, error) {
+	for i := 0; i <= s.Retries; i++ {
+		var state bucketState
+		var rev uint64
+
+		entry, err := s.KV.Get(ctx, key)
+		switch {
+		case errors.Is(err, jetstream.ErrKeyNotFound):
+		case err != nil:
+			return RateLimitDecision{}, err
+		default:
+			rev = entry.Revision()
+			if err := json.Unmarshal(entry.Value(), &state); err != nil {
+				state = bucketState{}
+			}
+		}
+
+		d := state.take(limit, now)
+		b, _ := json.Marshal(state)
+
+		if rev == 0 {
+			_, err = s.KV.Create(ctx, key, b)
+		} else {
+			_, err = s.KV.Update(ctx, key, b, rev)
+		}
+		if err == nil {
+			return d, nil
+		}
+		// otra réplica escribió primero: se reintenta con el valor nuevo
+		if !errors.Is(err, jetstream.ErrKeyExists) && !isWrongLastSequence(err) {
+			return RateLimitDecision{}, err
+		}
+	}
+	return RateLimitDecision{}, errors.New("rate limit: too much contention on " + key)
+}
+
+func isWrongLastSequence(err error) bool {
+	var apiErr *jetstream.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode == jetstream.JSErrCodeStreamWrongLastSequence
+}
+
+// RateLimiter aplica límites por ruta sobre un store.
+type RateLimiter struct {
+	Store RateLimitStore
+	// si el store falla se deja pasar el request (por defecto)
+	FailClosed bool
+}
+
+func NewRateLimiter(store RateLimitStore) *RateLimiter {
+	if store == nil {
+		store = NewMemoryRateStore()
+	}
+	return &RateLimiter{Store: store, FailClosed: helpers.GetEnvBool("RATE_LIMIT_FAIL_CLOSED", false)}
+}
+
+// Limit envuelve h con el límite def de la ruta name. RATE_LIMIT_<NAME> ("10/m:20")
+// lo pisa; "off" lo desactiva.
+func (l *RateLimiter) Limit(name string, def RateLimit, h http.HandlerFunc) http.HandlerFunc {
+	limit := def
+	env := "RATE_LIMIT_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_", "/", "_").Replace(name))
+	if v := helpers.GetEnv(env, ""); v != "" {
+		if strings.EqualFold(v, "off") {
+			return h
+		}
+		parsed, err := ParseRateLimit(v)
+		if err != nil {
+			logrus.WithError(err).Warnf("%s ignored", env)
+		} else {
+			limit.Rate, limit.Burst = parsed.Rate, parsed.Burst
+		}
+	}
+	if limit.Key == nil {
+		limit.Key = KeyByIP
+	}
+	if limit.Burst <= 0 {
+		return h
+	}
+
+	policy := fmt.Sprintf("%d;w=%d", limit.Burst, int(math.Ceil(limit.window().Seconds())))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// los preflight no consumen tokens
+		if r.Method == http.MethodOptions {
+			h(w, r)
+			return
+		}
+
+		key := rateLimitKey(name, limit.Key(r))
+		d, err := l.Store.Take(r.Context(), key, limit, time.Now())
+		if err != nil {
+			logrus.WithError(err).Warnf("rate limit %s: store error", name)
+			if l.FailClosed {
+				w.Header().Set("Retry-After", "1")
+				WriteJSONError(w, http.StatusServiceUnavailable, "RATE_LIMIT_UNAVAILABLE", "try again later")
+				return
+			}
+			h(w, r)
+			return
+		}
+
+		hdr := w.Header()
+		hdr.Set("RateLimit-Policy", policy)
+		hdr.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		hdr.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		hdr.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+
+		if !d.Allowed {
+			hdr.Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(d.RetryAfter))))
+			WriteJSONError(w, http.StatusTooManyRequests, "RATE_LIMITED", "too many requests")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// las claves de KV solo aceptan [-/_=.a-zA-Z0-9]; IPv6 o ids raros se hashean
+func rateLimitKey(name, subject string) string {
+	sum := sha256.Sum256([]byte(subject))
+	return "rl." + strings.NewReplacer("/", "_", ":", "_").Replace(name) + "." + hex.EncodeToString(sum[:12])
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,165 @@
+package ownhttp
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"moonmap.io/go-commons/helpers"
+)
+
+// ReplayFunc re-entrega en orden los eventos de subjects desde fromSeq (inclusive)
+// usando emit, y devuelve la última secuencia entregada.
+type ReplayFunc func(ctx context.Context, subjects []string, fromSeq uint64, emit ReplayEmitter) (uint64, error)
+
+type ReplayEmitter func(eventName string, seq uint64, data any) error
+
+// ReplayGap se manda como evento "gap" cuando no se puede garantizar continuidad
+// (retención vencida, replay truncado o fallido).
+type ReplayGap struct {
+	FromSeq  uint64   `json:"fromSeq"`
+	FirstSeq uint64   `json:"firstSeq,omitempty"`
+	Reason   string   `json:"reason"`
+	Subjects []string `json:"subjects,omitempty"`
+}
+
+// eventos en vivo que se guardan mientras dura un replay
+const maxReplayBuffer = 1024
+
+type replayState struct {
+	subjects []string
+	frames   []liveFrame
+	overflow bool
+}
+
+type liveFrame struct {
+	subject string
+	seq     uint64
+	b       []byte
+}
+
+func encodeEvent(encoding, eventName string, seq uint64, data any) ([]byte, error) {
+	return marshalFor(encoding, eventFrame{Event: eventName, Seq: seq, Data: data})
+}
+
+func (h *Hub) resume(s *SocketConnection, subjects []string, fromSeq uint64) {
+	if fromSeq == 0 {
+		h.subscribe(s, subjects)
+		return
+	}
+
+	if h.Replay == nil {
+		active := h.subscribeAs(s, subjects, "resume")
+		if len(active) > 0 {
+			_ = s.emit("gap", 0, ReplayGap{FromSeq: fromSeq, Reason: "unsupported", Subjects: active})
+		}
+		return
+	}
+
+	if !s.beginReplay(helpers.SanitizeStrings(subjects)) {
+		s.WriteError("RESUME_IN_PROGRESS", "a resume is already running", "resume", subjects)
+		return
+	}
+
+	// suscribir antes del replay: lo que llegue en vivo queda en buffer
+	active := h.subscribeAs(s, subjects, "resume")
+	if len(active) == 0 {
+		s.finishReplay(0)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			select {
+			case <-s.done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		last, err := h.Replay(ctx, active, fromSeq, s.emit)
+		if err != nil && ctx.Err() == nil {
+			s.MainLog().WithError(err).WithField("fromSeq", fromSeq).Warn("ws replay failed")
+			s.WriteError("RESUME_FAILED", err.Error(), "resume", active)
+			_ = s.emit("gap", 0, ReplayGap{FromSeq: max(fromSeq, last+1), Reason: "replay_failed", Subjects: active})
+		}
+
+		s.finishReplay(last)
+		s.MainLog().WithFields(logrus.Fields{"fromSeq": fromSeq, "lastSeq": last}).Info("ws replay finished")
+	}()
+}
+
+func (s *SocketConnection) beginReplay(subjects []string) bool {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	if s.replay != nil {
+		return false
+	}
+	s.replay = &replayState{subjects: subjects}
+	return true
+}
+
+// finishReplay vuelve a modo live: manda "resumed" y después los eventos en vivo
+// retenidos, descartando los que el replay ya entregó. rmu no se tiene durante
+// enqueueWait (bloquea con un cliente lento y frenaría el broadcast para todos):
+// mientras se vacía el buffer s.replay sigue puesto y lo nuevo se sigue reteniendo.
+func (s *SocketConnection) finishReplay(lastSeq uint64) {
+	s.rmu.Lock()
+	st := s.replay
+	if st == nil {
+		s.rmu.Unlock()
+		return
+	}
+	overflow := st.overflow
+	st.overflow = false
+	s.rmu.Unlock()
+
+	if overflow {
+		if b, err := encodeEvent(s.encoding, "gap", 0, ReplayGap{FromSeq: lastSeq + 1, Reason: "live_overflow", Subjects: st.subjects}); err == nil {
+			_ = s.enqueueWait(liveFrame{b: b})
+		}
+	}
+	if b, err := encodeEvent(s.encoding, "resumed", 0, map[string]any{"lastSeq": lastSeq}); err == nil {
+		_ = s.enqueueWait(liveFrame{b: b})
+	}
+
+	for {
+		s.rmu.Lock()
+		frames, lost := st.frames, st.overflow
+		st.frames, st.overflow = nil, false
+		if len(frames) == 0 {
+			// vacío con el lock tomado: desde acá writeLive encola directo
+			s.replay = nil
+			s.rmu.Unlock()
+			return
+		}
+		s.rmu.Unlock()
+
+		for _, f := range frames {
+			if f.seq > 0 && f.seq <= lastSeq && helpers.MatchesAnyEvent(f.subject, st.subjects) {
+				continue
+			}
+			if err := s.enqueueWait(f); err != nil {
+				s.rmu.Lock()
+				s.replay = nil
+				s.rmu.Unlock()
+				return
+			}
+		}
+		if lost {
+			// se llenó el buffer mientras se vaciaba el anterior
+			if b, err := encodeEvent(s.encoding, "gap", 0, ReplayGap{FromSeq: lastSeq + 1, Reason: "live_overflow", Subjects: st.subjects}); err == nil {
+				_ = s.enqueueWait(liveFrame{b: b})
+			}
+		}
+	}
+}
+
+func (s *SocketConnection) emit(eventName string, seq uint64, data any) error {
+	b, err := encodeEvent(s.encoding, eventName, seq, data)
+	if err != nil {
+		return err
+	}
+	return s.enqueueWait(liveFrame{subject: eventName, seq: seq, b: b})
+}
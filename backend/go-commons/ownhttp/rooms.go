@@ -0,0 +1,72 @@
+package ownhttp
+
+import (
+	"strings"
+)
+
+// RoomHandler implementa presencia por sala (ej. una sphere). El Hub se encarga de
+// suscribir la conexión al subject de la sala; el handler lleva la cuenta y publica.
+type RoomHandler interface {
+	RoomSubject(room string) string
+	Join(s *SocketConnection, room string) (snapshot any, err error)
+	Leave(s *SocketConnection, room string)
+	Typing(s *SocketConnection, room string) error
+	Disconnect(s *SocketConnection)
+}
+
+func validRoom(room string) bool {
+	return room != "" && len(room) <= 128 && !strings.ContainsAny(room, ".*> \t\r\n")
+}
+
+func (h *Hub) roomCheck(s *SocketConnection, room, action string) bool {
+	if h.Rooms == nil {
+		s.WriteError("ROOMS_UNSUPPORTED", "presence is not enabled", action, nil)
+		return false
+	}
+	if !validRoom(room) {
+		s.WriteError("INVALID_ROOM", "invalid sphereId", action, nil)
+		return false
+	}
+	return true
+}
+
+func (h *Hub) join(s *SocketConnection, room string) {
+	room = strings.TrimSpace(room)
+	if !h.roomCheck(s, room, "join") {
+		return
+	}
+
+	subject := h.Rooms.RoomSubject(room)
+	if len(h.subscribeAs(s, []string{subject}, "join")) == 0 {
+		return
+	}
+
+	snapshot, err := h.Rooms.Join(s, room)
+	if err != nil {
+		s.WriteError("JOIN_FAILED", err.Error(), "join", []string{subject})
+		h.unsubscribe(s, []string{subject})
+		return
+	}
+	if err := s.emit(subject, 0, snapshot); err != nil {
+		s.MainLog().WithError(err).Warn("presence snapshot write failed")
+	}
+}
+
+func (h *Hub) leave(s *SocketConnection, room string) {
+	room = strings.TrimSpace(room)
+	if !h.roomCheck(s, room, "leave") {
+		return
+	}
+	h.Rooms.Leave(s, room)
+	h.unsubscribe(s, []string{h.Rooms.RoomSubject(room)})
+}
+
+func (h *Hub) typing(s *SocketConnection, room string) {
+	room = strings.TrimSpace(room)
+	if !h.roomCheck(s, room, "typing") {
+		return
+	}
+	if err := h.Rooms.Typing(s, room); err != nil {
+		s.WriteError("TYPING_REJECTED", err.Error(), "typing", nil)
+	}
+}
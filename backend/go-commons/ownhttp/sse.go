@@ -0,0 +1,169 @@
+package ownhttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/sirupsen/logrus"
+	"moonmap.io/go-commons/helpers"
+)
+
+// sseTransport escribe los mismos frames JSON del websocket como eventos SSE:
+// "id: <seq>" cuando hay secuencia y "data: <json>". Heartbeat = comentario.
+type sseTransport struct {
+	w      http.ResponseWriter
+	rc     *http.ResponseController
+	remote string
+	closed bool // guarded by s.Mutex; el handler ya devolvió
+}
+
+func (t *sseTransport) name() string { return "sse" }
+
+func (t *sseTransport) write(chunk string) error {
+	if t.closed {
+		return errConnClosed
+	}
+	if err := t.rc.SetWriteDeadline(time.Now().Add(writeWait)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	if _, err := io.WriteString(t.w, chunk); err != nil {
+		return err
+	}
+	return t.rc.Flush()
+}
+
+func (t *sseTransport) writeFrame(seq uint64, b []byte) error {
+	var sb strings.Builder
+	if seq > 0 {
+		fmt.Fprintf(&sb, "id: %d\n", seq)
+	}
+	sb.WriteString("data: ")
+	sb.Write(b)
+	sb.WriteString("\n\n")
+	return t.write(sb.String())
+}
+
+func (t *sseTransport) ping() error {
+	return t.write(": ping\n\n")
+}
+
+func (t *sseTransport) closeFrame(_ int, text string) {
+	_ = t.write(fmt.Sprintf(": close %s\n\n", text))
+}
+
+func (t *sseTransport) close() error { return nil }
+
+func (t *sseTransport) remoteAddr() string { return t.remote }
+
+// parseEventSeq acepta "N" o el id de NotifyEvent "msgID:N".
+func parseEventSeq(id string) uint64 {
+	id = strings.TrimSpace(id)
+	if i := strings.LastIndexByte(id, ':'); i >= 0 {
+		id = id[i+1:]
+	}
+	seq, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return seq
+}
+
+// AddSSE atiende GET /sse?subjects=a,b. La sesión cuenta como un cliente más del Hub
+// (límites, stats, broadcast); Last-Event-ID reanuda desde la secuencia siguiente.
+func (h *Hub) AddSSE(w http.ResponseWriter, r *http.Request) {
+	if h.closing {
+		WriteJSONError(w, http.StatusInternalServerError, "HUB_CLOSING", "hub closing")
+		return
+	}
+	if r.Method != http.MethodGet {
+		WriteJSONError(w, http.StatusMethodNotAllowed, "NOT_ALLOWED", "method")
+		return
+	}
+
+	// SSE no tiene frame de auth: si el Hub pide token tiene que venir en el request
+	var principal *Principal
+	if h.Verifier != nil {
+		token, _ := TokenFromRequest(r)
+		p, err := h.Verifier.Verify(token)
+		if err != nil {
+			WriteJSONError(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
+			return
+		}
+		principal = p
+	}
+
+	q := r.URL.Query()
+	subjects := []string{}
+	for _, v := range q["subjects"] {
+		subjects = append(subjects, strings.Split(v, ",")...)
+	}
+	subjects = helpers.SanitizeStrings(subjects)
+	if h.IsSubjectMode() && len(subjects) == 0 {
+		WriteJSONError(w, http.StatusBadRequest, "BAD_REQUEST", "subjects required")
+		return
+	}
+
+	var fromSeq uint64
+	if last := parseEventSeq(helpers.FirstNonEmpty(r.Header.Get("Last-Event-ID"), q.Get("lastEventId"))); last > 0 {
+		fromSeq = last + 1
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		logrus.WithError(err).Warn("sse: response writer cannot flush")
+		return
+	}
+
+	t := &sseTransport{w: w, rc: rc, remote: clientIP(r)}
+	conn := &SocketConnection{
+		ID:        ksuid.New().String(),
+		Hub:       h,
+		Mutex:     &sync.Mutex{},
+		done:      make(chan struct{}),
+		Subs:      make(map[string]struct{}),
+		send:      make(chan liveFrame, defaultSendQueue),
+		t:         t,
+		encoding:  EncodingJSON,
+		Principal: principal,
+	}
+	if h.SendQueueSize > 0 {
+		conn.send = make(chan liveFrame, h.SendQueueSize)
+	}
+	conn.authed.Store(true)
+
+	h.mu.Lock()
+	h.Clients[conn.ID] = conn
+	h.mu.Unlock()
+
+	conn.Init()
+	go conn.writePump()
+	go conn.StartPinger()
+
+	if h.IsSubjectMode() {
+		h.resume(conn, subjects, fromSeq)
+	}
+	conn.MainLog().WithField("fromSeq", fromSeq).Info("sse session opened")
+
+	select {
+	case <-r.Context().Done():
+	case <-conn.done:
+	}
+	conn.Close()
+
+	// después de devolver no se puede tocar el ResponseWriter
+	conn.Mutex.Lock()
+	t.closed = true
+	conn.Mutex.Unlock()
+}
@@ -0,0 +1,117 @@
+package ownhttp
+
+import "strings"
+
+// subjectTrie indexa los subjects de los clientes por token para resolver
+// wildcards NATS ("*" un token, ">" el resto) sin recorrer todas las subs.
+type subjectTrie struct {
+	root *trieNode
+}
+
+type trieNode struct {
+	children map[string]*trieNode
+	pwc      *trieNode // "*"
+	fwc      *trieNode // ">"
+	subs     map[string]*SocketConnection
+}
+
+func newSubjectTrie() *subjectTrie {
+	return &subjectTrie{root: &trieNode{}}
+}
+
+func (n *trieNode) child(tok string, create bool) *trieNode {
+	switch tok {
+	case "*":
+		if n.pwc == nil && create {
+			n.pwc = &trieNode{}
+		}
+		return n.pwc
+	case ">":
+		if n.fwc == nil && create {
+			n.fwc = &trieNode{}
+		}
+		return n.fwc
+	}
+
+	c := n.children[tok]
+	if c == nil && create {
+		if n.children == nil {
+			n.children = make(map[string]*trieNode)
+		}
+		c = &trieNode{}
+		n.children[tok] = c
+	}
+	return c
+}
+
+func (n *trieNode) empty() bool {
+	return len(n.subs) == 0 && len(n.children) == 0 && n.pwc == nil && n.fwc == nil
+}
+
+func (t *subjectTrie) insert(pattern string, s *SocketConnection) {
+	n := t.root
+	for _, tok := range strings.Split(pattern, ".") {
+		n = n.child(tok, true)
+	}
+	if n.subs == nil {
+		n.subs = make(map[string]*SocketConnection)
+	}
+	n.subs[s.ID] = s
+}
+
+func (t *subjectTrie) remove(pattern, id string) {
+	toks := strings.Split(pattern, ".")
+	path := make([]*trieNode, 0, len(toks)+1)
+
+	n := t.root
+	path = append(path, n)
+	for _, tok := range toks {
+		if n = n.child(tok, false); n == nil {
+			return
+		}
+		path = append(path, n)
+	}
+	delete(n.subs, id)
+
+	// podar nodos vacíos de abajo hacia arriba
+	for i := len(toks) - 1; i >= 0; i-- {
+		node, parent := path[i+1], path[i]
+		if !node.empty() {
+			return
+		}
+		switch toks[i] {
+		case "*":
+			parent.pwc = nil
+		case ">":
+			parent.fwc = nil
+		default:
+			delete(parent.children, toks[i])
+		}
+	}
+}
+
+// match devuelve las conexiones interesadas en subject, sin duplicados.
+func (t *subjectTrie) match(subject string) map[string]*SocketConnection {
+	out := make(map[string]*SocketConnection)
+	matchLevel(t.root, strings.Split(subject, "."), out)
+	return out
+}
+
+func matchLevel(n *trieNode, toks []string, out map[string]*SocketConnection) {
+	if n == nil {
+		return
+	}
+	if len(toks) == 0 {
+		for id, s := range n.subs {
+			out[id] = s
+		}
+		return
+	}
+	if n.fwc != nil {
+		for id, s := range n.fwc.subs {
+			out[id] = s
+		}
+	}
+	matchLevel(n.pwc, toks[1:], out)
+	matchLevel(n.children[toks[0]], toks[1:], out)
+}
@@ -0,0 +1,51 @@
+package ownhttp
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// transport abstrae cómo llegan los frames al cliente (websocket o SSE) para que
+// el Hub, las colas y el replay sean los mismos. Las escrituras van bajo s.Mutex.
+type transport interface {
+	name() string
+	writeFrame(seq uint64, b []byte) error
+	ping() error
+	closeFrame(code int, text string)
+	close() error
+	remoteAddr() string
+}
+
+type wsTransport struct {
+	conn   *websocket.Conn
+	binary bool // msgpack va en frames binarios
+}
+
+func (t *wsTransport) name() string { return "ws" }
+
+func (t *wsTransport) writeFrame(_ uint64, b []byte) error {
+	t.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	mt := websocket.TextMessage
+	if t.binary {
+		mt = websocket.BinaryMessage
+	}
+	return t.conn.WriteMessage(mt, b)
+}
+
+func (t *wsTransport) ping() error {
+	t.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return t.conn.WriteMessage(websocket.PingMessage, nil)
+}
+
+func (t *wsTransport) closeFrame(code int, text string) {
+	_ = t.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(writeWait))
+}
+
+func (t *wsTransport) close() error {
+	return t.conn.Close()
+}
+
+func (t *wsTransport) remoteAddr() string {
+	return t.conn.UnderlyingConn().RemoteAddr().String()
+}
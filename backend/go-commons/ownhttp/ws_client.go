@@ -36,7 +36,7 @@ func NewWSClient(ctx context.Context, url string) (*WSClient, error) {
 				logrus.Info("Finalizing ws client")
 				return
 			case <-ticker.C:
-				_ = c.WriteMessage(websocket.PingMessage, []byte("ping"))
+				_ = c.WriteControl(websocket.PingMessage, []byte("ping"), time.Now().Add(10*time.Second))
 			}
 		}
 	}()
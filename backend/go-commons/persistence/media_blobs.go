@@ -0,0 +1,111 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MediaBlob: variantes compartidas por todos los uploads con el mismo contenido (checksum
+// del original), mismo profile (mismo plan) y misma versión de pipeline.
+// Holders son las keys de media_assets que apuntan acá; Refs = len(Holders).
+type MediaBlob struct {
+	ID              string         `bson:"_id"`
+	Checksum        string         `bson:"checksum"`
+	Profile         string         `bson:"profile"`
+	PipelineVersion int            `bson:"pipelineVersion"`
+	Owner           string         `bson:"owner"`  // key del original que generó las variantes
+	Status          string         `bson:"status"` // processing|ready|failed|released
+	Variants        []MediaVariant `bson:"variants"`
+	Width           int            `bson:"width,omitempty"`
+	Height          int            `bson:"height,omitempty"`
+	Holders         []string       `bson:"holders"`
+	Refs            int            `bson:"refs"`
+	CreatedAt       time.Time      `bson:"createdAt"`
+	UpdatedAt       time.Time      `bson:"updatedAt"`
+}
+
+func MediaBlobID(checksum, profile string, pipelineVersion int) string {
+	return fmt.Sprintf("%s:%s:v%d", checksum, profile, pipelineVersion)
+}
+
+// BlobHoldersUpdate: pipeline que agrega (o quita) key de holders y recalcula refs en la
+// misma escritura. $setUnion/$setDifference lo hacen idempotente ante reentregas.
+// Cuando refs llega a 0 el blob pasa a released y ya no se puede linkear.
+func BlobHoldersUpdate(key string, add bool, extra bson.D) mongo.Pipeline {
+	op := "$setDifference"
+	if add {
+		op = "$setUnion"
+	}
+	set := bson.D{
+		{Key: "holders", Value: bson.D{{Key: op, Value: bson.A{
+			bson.D{{Key: "$ifNull", Value: bson.A{"$holders", bson.A{}}}},
+			bson.A{key},
+		}}}},
+		{Key: "updatedAt", Value: "$$NOW"},
+	}
+	set = append(set, extra...)
+
+	size := bson.D{{Key: "$size", Value: "$holders"}}
+	refs := bson.D{{Key: "refs", Value: size}}
+	if !add {
+		refs = append(refs, bson.E{Key: "status", Value: bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$eq", Value: bson.A{size, 0}}}, "released", "$status",
+		}}}})
+	}
+	return mongo.Pipeline{{{Key: "$set", Value: set}}, {{Key: "$set", Value: refs}}}
+}
+
+// MediaRelease: qué objetos de S3 se pueden borrar al eliminar un media_asset y cuáles
+// hay que conservar porque otros uploads los siguen usando.
+type MediaRelease struct {
+	Delete []string
+	Keep   []string
+}
+
+// ReleaseMedia saca a doc del blob (si tiene) y decide qué keys borrar. Las variantes
+// compartidas solo se borran cuando el último holder se va.
+func ReleaseMedia(ctx context.Context, blobs *mongo.Collection, doc *MediaDoc) (*MediaRelease, error) {
+	rel := &MediaRelease{Delete: []string{doc.Key}}
+
+	if doc.BlobID == "" {
+		for _, pv := range doc.Planned {
+			rel.Delete = append(rel.Delete, pv.Key)
+		}
+		return rel, nil
+	}
+
+	var blob MediaBlob
+	err := blobs.FindOneAndUpdate(ctx,
+		bson.M{"_id": doc.BlobID},
+		BlobHoldersUpdate(doc.Key, false, nil),
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&blob)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return rel, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(blob.Variants))
+	for _, v := range blob.Variants {
+		keys = append(keys, v.Key)
+	}
+	if blob.Status != "released" {
+		rel.Keep = keys
+		return rel, nil
+	}
+
+	rel.Delete = append(rel.Delete, keys...)
+	// si otro upload ya lo reclamó (status != released) el doc queda
+	if _, err := blobs.DeleteOne(ctx, bson.M{"_id": blob.ID, "status": "released"}); err != nil {
+		return nil, err
+	}
+	return rel, nil
+}
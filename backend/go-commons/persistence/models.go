@@ -52,6 +52,7 @@ type MediaVariant struct {
 }
 
 type MediaDoc struct {
+	ID         bson.ObjectID      `bson:"_id,omitempty"`
 	Key        string             `bson:"key"`
 	Namespace  string             `bson:"namespace"`
 	EntityID   string             `bson:"entityId"`
@@ -78,6 +79,42 @@ type MediaDoc struct {
 
 	Width  int `bson:"width,omitempty"`
 	Height int `bson:"height,omitempty"`
+
+	// dedupe: las variantes son las del blob (pueden vivir bajo la key de otro upload)
+	BlobID string `bson:"blobId,omitempty"`
+
+	Screening *MediaScreening `bson:"screening,omitempty"`
+}
+
+// MediaScreening: resultado del screening del consumer (hashes perceptuales + clasificador)
+// y, si quedó en quarantined, la revisión del moderador.
+type MediaScreening struct {
+	Action string             `bson:"action" json:"action"`                     // allow|quarantine
+	Reason string             `bson:"reason,omitempty" json:"reason,omitempty"` // blocklist|classifier|classifier_error
+	Label  string             `bson:"label,omitempty" json:"label,omitempty"`
+	Score  float64            `bson:"score,omitempty" json:"score,omitempty"`
+	Labels map[string]float64 `bson:"labels,omitempty" json:"labels,omitempty"`
+	DHash  string             `bson:"dhash,omitempty" json:"dhash,omitempty"` // hex 64 bits
+	PHash  string             `bson:"phash,omitempty" json:"phash,omitempty"`
+
+	Review     string     `bson:"review,omitempty" json:"review,omitempty"` // approved|rejected
+	ReviewedBy string     `bson:"reviewedBy,omitempty" json:"reviewedBy,omitempty"`
+	ReviewedAt *time.Time `bson:"reviewedAt,omitempty" json:"reviewedAt,omitempty"`
+}
+
+func (s *MediaScreening) Approved() bool {
+	return s != nil && s.Review == "approved"
+}
+
+// MediaHashBlock: entrada de la blocklist perceptual (media_hash_blocklist)
+type MediaHashBlock struct {
+	ID        bson.ObjectID `bson:"_id,omitempty" json:"id"`
+	DHash     string        `bson:"dhash,omitempty" json:"dhash,omitempty"`
+	PHash     string        `bson:"phash,omitempty" json:"phash,omitempty"`
+	Reason    string        `bson:"reason,omitempty" json:"reason,omitempty"`
+	MediaID   string        `bson:"mediaId,omitempty" json:"mediaId,omitempty"` // de dónde salió
+	CreatedBy string        `bson:"createdBy,omitempty" json:"createdBy,omitempty"`
+	CreatedAt time.Time     `bson:"createdAt" json:"createdAt"`
 }
 
 // Exporta el plan a una forma apta para guardar en Mongo (bson-friendly)
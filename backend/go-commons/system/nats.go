@@ -120,6 +120,42 @@ func (n *NatsEventStore) CreateStreamWithConfig(ctx context.Context, config jets
 	logrus.Infof("stream %v created or updated successfully", config.Name)
 }
 
+// CreateOrderedConsumer crea un consumer efímero y ordenado (sin acks) sobre subjects.
+// startSeq > 0 arranca desde esa secuencia del stream; 0 entrega solo mensajes nuevos.
+func (n *NatsEventStore) CreateOrderedConsumer(ctx context.Context, streamName string, subjects []string, startSeq uint64) (jetstream.Consumer, error) {
+	config := jetstream.OrderedConsumerConfig{
+		FilterSubjects:    subjects,
+		DeliverPolicy:     jetstream.DeliverNewPolicy,
+		InactiveThreshold: 30 * time.Second,
+	}
+	if startSeq > 0 {
+		config.DeliverPolicy = jetstream.DeliverByStartSequencePolicy
+		config.OptStartSeq = startSeq
+	}
+	return (*n.js).OrderedConsumer(ctx, streamName, config)
+}
+
+func (n *NatsEventStore) CreateOrUpdateKeyValue(ctx context.Context, config jetstream.KeyValueConfig) (jetstream.KeyValue, error) {
+	return (*n.js).CreateOrUpdateKeyValue(ctx, config)
+}
+
+func (n *NatsEventStore) DeleteConsumer(ctx context.Context, streamName, consumerName string) error {
+	return (*n.js).DeleteConsumer(ctx, streamName, consumerName)
+}
+
+// StreamState devuelve first/last seq del stream para detectar huecos de retención.
+func (n *NatsEventStore) StreamState(ctx context.Context, streamName string) (*jetstream.StreamState, error) {
+	stream, err := (*n.js).Stream(ctx, streamName)
+	if err != nil {
+		return nil, err
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &info.State, nil
+}
+
 func (n *NatsEventStore) CreateEphemeralConsumer(streamName, consumerName string, subjects []string, handler func(msg jetstream.Msg) error) {
 	n.createConsumerInternal(streamName, consumerName, subjects, handler, false)
 
@@ -172,6 +208,10 @@ func (n *NatsEventStore) createConsumerInternal(streamName, consumerName string,
 			return
 		}
 
+		if errors.Is(err, constants.ErrAckDeferred) {
+			return
+		}
+
 		// Mapea tu sentinel de “no listo todavía”
 		if errors.Is(err, constants.ErrNotReady) {
 			// elige delay desde cfg.BackOff según attempt
@@ -0,0 +1,37 @@
+package system
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/sirupsen/logrus"
+	"moonmap.io/go-commons/helpers"
+	"moonmap.io/go-commons/ownhttp"
+)
+
+const rateLimitBucket = "ratelimit"
+
+// NewRateLimiter elige el backend con RATE_LIMIT_BACKEND (nats|memory).
+// Con nats los buckets se comparten entre réplicas; si no hay event store o
+// falla el KV se usa memoria.
+func NewRateLimiter(ctx context.Context, es *NatsEventStore) *ownhttp.RateLimiter {
+	backend := helpers.GetEnv("RATE_LIMIT_BACKEND", "nats")
+	if es == nil || backend != "nats" {
+		return ownhttp.NewRateLimiter(ownhttp.NewMemoryRateStore())
+	}
+
+	kv, err := es.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:      rateLimitBucket,
+		Description: "token buckets for ownhttp rate limiting",
+		History:     1,
+		// un bucket sin tocar por este tiempo ya estaría lleno
+		TTL:     helpers.GetEnvDur("RATE_LIMIT_KV_TTL", time.Hour),
+		Storage: jetstream.MemoryStorage,
+	})
+	if err != nil {
+		logrus.WithError(err).Warn("rate limit: KV unavailable, falling back to memory")
+		return ownhttp.NewRateLimiter(ownhttp.NewMemoryRateStore())
+	}
+	return ownhttp.NewRateLimiter(ownhttp.NewKVRateStore(kv))
+}
@@ -0,0 +1,201 @@
+package typesense
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"moonmap.io/go-commons/helpers"
+)
+
+var ErrNotFound = errors.New("typesense: not found")
+
+// Client es un cliente mínimo de la API REST de Typesense.
+type Client struct {
+	URL  string
+	Key  string
+	HTTP *http.Client
+}
+
+func NewClientFromEnv() *Client {
+	return &Client{
+		URL:  helpers.GetEnv("TYPESENSE_URL", "http://localhost:8010"),
+		Key:  helpers.GetEnv("TYPESENSE_APIKEY", "xzy"),
+		HTTP: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type Field struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Facet    bool   `json:"facet,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+	Index    *bool  `json:"index,omitempty"`
+}
+
+type Schema struct {
+	Name                string  `json:"name"`
+	Fields              []Field `json:"fields"`
+	DefaultSortingField string  `json:"default_sorting_field,omitempty"`
+}
+
+// EnsureCollection crea la colección si no existe (no migra schemas existentes).
+func (c *Client) EnsureCollection(ctx context.Context, schema Schema) (created bool, err error) {
+	err = c.do(ctx, http.MethodGet, "/collections/"+url.PathEscape(schema.Name), nil, nil)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+	if err := c.do(ctx, http.MethodPost, "/collections", schema, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *Client) Upsert(ctx context.Context, coll string, doc any) error {
+	return c.do(ctx, http.MethodPost, "/collections/"+url.PathEscape(coll)+"/documents?action=upsert", doc, nil)
+}
+
+// Import sube documentos en bloque (JSONL, action=upsert). Typesense responde 200 aunque
+// fallen algunos; se devuelve la cantidad de fallidos y el primer error.
+func (c *Client) Import(ctx context.Context, coll string, docs []any) (failed int, err error) {
+	if len(docs) == 0 {
+		return 0, nil
+	}
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, d := range docs {
+		if err := enc.Encode(d); err != nil {
+			return 0, err
+		}
+	}
+
+	path := "/collections/" + url.PathEscape(coll) + "/documents/import?action=upsert"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+path, &body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("X-TYPESENSE-API-KEY", c.Key)
+
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return 0, fmt.Errorf("typesense POST %s: %d %s", path, res.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	dec := json.NewDecoder(res.Body)
+	for dec.More() {
+		var line struct {
+			Success bool   `json:"success"`
+			Error   string `json:"error"`
+		}
+		if err := dec.Decode(&line); err != nil {
+			return failed, err
+		}
+		if !line.Success {
+			failed++
+			if err == nil {
+				err = fmt.Errorf("typesense import: %s", line.Error)
+			}
+		}
+	}
+	return failed, err
+}
+
+// Delete ignora documentos que ya no existen.
+func (c *Client) Delete(ctx context.Context, coll, id string) error {
+	err := c.do(ctx, http.MethodDelete, "/collections/"+url.PathEscape(coll)+"/documents/"+url.PathEscape(id), nil, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+type SearchHit struct {
+	Document   json.RawMessage `json:"document"`
+	Highlights []struct {
+		Field   string `json:"field"`
+		Snippet string `json:"snippet"`
+	} `json:"highlights"`
+	TextMatch int64 `json:"text_match"`
+}
+
+type FacetCount struct {
+	FieldName string `json:"field_name"`
+	Counts    []struct {
+		Value string `json:"value"`
+		Count int    `json:"count"`
+	} `json:"counts"`
+}
+
+type SearchResult struct {
+	Found       int          `json:"found"`
+	Page        int          `json:"page"`
+	Hits        []SearchHit  `json:"hits"`
+	FacetCounts []FacetCount `json:"facet_counts"`
+}
+
+// Search recibe los parámetros de la API tal cual (q, query_by, filter_by, ...).
+func (c *Client) Search(ctx context.Context, coll string, params url.Values) (*SearchResult, error) {
+	var res SearchResult
+	err := c.do(ctx, http.MethodGet, "/collections/"+url.PathEscape(coll)+"/documents/search?"+params.Encode(), nil, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, rd)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-TYPESENSE-API-KEY", c.Key)
+
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("typesense %s %s: %d %s", method, path, res.StatusCode, bytes.TrimSpace(msg))
+	}
+	if out != nil {
+		return json.NewDecoder(res.Body).Decode(out)
+	}
+	return nil
+}
+
+// FilterValue escapa un valor para filter_by.
+func FilterValue(v string) string {
+	return "`" + strings.ReplaceAll(v, "`", "") + "`"
+}
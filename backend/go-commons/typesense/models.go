@@ -28,3 +28,39 @@ type ProjectDoc struct {
 	Source          string `json:"source"`
 	// Raw             any    `json:"raw"`
 }
+
+// SphereContentDoc es un post o reply de una sphere en el índice de búsqueda.
+type SphereContentDoc struct {
+	ID            string   `json:"id"`
+	SphereID      string   `json:"sphereId"`
+	ParentID      string   `json:"parentId"`
+	UserID        string   `json:"userId"`
+	AuthorName    string   `json:"authorName"`
+	Type          string   `json:"type"`
+	Text          string   `json:"text"`
+	Mentions      []string `json:"mentions"`
+	Cashtags      []string `json:"cashtags"`
+	HasMedia      bool     `json:"hasMedia"`
+	IsReply       bool     `json:"isReply"`
+	CreatedAtUnix int64    `json:"createdAt"`
+}
+
+func SphereContentsSchema(name string) Schema {
+	return Schema{
+		Name: name,
+		Fields: []Field{
+			{Name: "sphereId", Type: "string", Facet: true},
+			{Name: "parentId", Type: "string", Optional: true},
+			{Name: "userId", Type: "string", Facet: true},
+			{Name: "authorName", Type: "string", Optional: true},
+			{Name: "type", Type: "string", Facet: true},
+			{Name: "text", Type: "string"},
+			{Name: "mentions", Type: "string[]", Facet: true, Optional: true},
+			{Name: "cashtags", Type: "string[]", Facet: true, Optional: true},
+			{Name: "hasMedia", Type: "bool", Facet: true},
+			{Name: "isReply", Type: "bool", Facet: true},
+			{Name: "createdAt", Type: "int64"},
+		},
+		DefaultSortingField: "createdAt",
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"strings"
+
+	"moonmap.io/go-commons/ownhttp"
+)
+
+const adminRole = "admin"
+
+// subjects privados: el token en ownerIdx tiene que ser el userId del principal
+var privateSubjects = []struct {
+	prefix   []string
+	ownerIdx int
+}{
+	{prefix: []string{"notify", "user"}, ownerIdx: 2},       // notify.user.<userId>
+	{prefix: []string{"notify", "media", "*"}, ownerIdx: 3}, // notify.media.<ns>.<uploaderId>
+}
+
+// authorizeSubject decide si un principal puede escuchar un subject (o patrón con wildcards).
+// Un patrón que pueda tocar un subject privado tiene que fijar el owner con el userId propio.
+func authorizeSubject(p *ownhttp.Principal, subject string) bool {
+	toks := strings.Split(subject, ".")
+	for _, ps := range privateSubjects {
+		if !overlapsPrefix(toks, ps.prefix) {
+			continue
+		}
+		if p.IsAnonymous() || len(toks) <= ps.ownerIdx {
+			return false
+		}
+		for _, tok := range toks[:ps.ownerIdx] {
+			if tok == ">" {
+				return false
+			}
+		}
+		if toks[ps.ownerIdx] != p.UserID {
+			return false
+		}
+	}
+	return true
+}
+
+// overlapsPrefix indica si el patrón puede matchear algún subject que empiece con prefix.
+func overlapsPrefix(toks, prefix []string) bool {
+	for i, want := range prefix {
+		if i >= len(toks) {
+			return false
+		}
+		tok := toks[i]
+		if tok == ">" {
+			return true
+		}
+		if tok != "*" && want != "*" && tok != want {
+			return false
+		}
+	}
+	return true
+}
+
+// redactSubjects junta los subjects privados (notify.user.<id>, notify.media.<ns>.<id>)
+// bajo el owner "*": las stats no exponen ids ni actividad de cada usuario.
+func redactSubjects(bySubject map[string]int) map[string]int {
+	out := make(map[string]int, len(bySubject))
+	for subj, n := range bySubject {
+		toks := strings.Split(subj, ".")
+		for _, ps := range privateSubjects {
+			if len(toks) > ps.ownerIdx && matchesPrefix(toks, ps.prefix) {
+				subj = strings.Join(append(toks[:ps.ownerIdx:ps.ownerIdx], "*"), ".")
+				if len(toks) > ps.ownerIdx+1 {
+					subj += ".>"
+				}
+				break
+			}
+		}
+		out[subj] += n
+	}
+	return out
+}
+
+func matchesPrefix(toks, prefix []string) bool {
+	for i, pt := range prefix {
+		if pt != "*" && toks[i] != pt {
+			return false
+		}
+	}
+	return true
+}
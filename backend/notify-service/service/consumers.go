@@ -1,34 +1,247 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/sirupsen/logrus"
 	"moonmap.io/go-commons/constants"
+	"moonmap.io/go-commons/helpers"
 )
 
-func (s *Service) CreateConsumerSpheres() {
+// feed es un consumer ordenado y efímero por stream cuyo filtro cubre los subjects que
+// piden los clientes de este pod, agrupados por categoría (notify.user.>, spheres.content.>):
+// el filtro no cambia con cada usuario que se conecta y el reparto fino lo hace el hub.
+// Sin interés no hay consumer.
+type feed struct {
+	stream  string
+	mu      sync.Mutex
+	filters []string
+	cons    jetstream.Consumer
+	cc      jetstream.ConsumeContext
+	lastSeq atomic.Uint64
+}
+
+type FeedStats struct {
+	Stream  string   `json:"stream"`
+	Filters []string `json:"filters"`
+	LastSeq uint64   `json:"lastSeq"`
+}
+
+// CreateInterestFeeds reemplaza el durable por hostname y la suscripción a notify.>:
+// cada réplica recibe solo lo que sus clientes escuchan.
+func (s *Service) CreateInterestFeeds() {
+	s.feeds = []*feed{
+		{stream: constants.StreamNotify},
+		{stream: constants.StreamSpheres},
+	}
+	s.cleanupLegacyDurable()
+	go s.watchInterest()
+}
+
+// antes cada pod creaba un durable con su hostname que quedaba huérfano al reprogramarse
+func (s *Service) cleanupLegacyDurable() {
 	hostname, _ := os.Hostname()
 	hostname = strings.Split(hostname, ".")[0]
-	consumerName := hostname
-
-	subjects := []string{fmt.Sprintf("%s.>", constants.StreamSpheres)}
-	s.EventStore.CreateConsumer(constants.StreamSpheres, consumerName, subjects,
-		func(msg jetstream.Msg) error {
-			data := msg.Data()
-			logrus.Infof("consuming event subject=%s", msg.Subject())
-
-			// forward al Hub
-			var ev map[string]any
-			if err := json.Unmarshal(data, &ev); err != nil {
-				return err
+	ctx, cancel := context.WithTimeout(s.Ctx, 5*time.Second)
+	defer cancel()
+
+	err := s.EventStore.DeleteConsumer(ctx, constants.StreamSpheres, hostname)
+	if err != nil && !errors.Is(err, jetstream.ErrConsumerNotFound) {
+		logrus.WithError(err).WithField("consumer", hostname).Warn("unable to delete legacy durable consumer")
+	}
+}
+
+func (s *Service) watchInterest() {
+	debounce := helpers.GetEnvDur("WS_INTEREST_DEBOUNCE", 200*time.Millisecond)
+	for {
+		select {
+		case <-s.Ctx.Done():
+			return
+		case <-s.Hub.InterestChanged():
+			// juntar ráfagas de subscribe/unsubscribe en un solo update
+			time.Sleep(debounce)
+			s.reconcileFeeds()
+		}
+	}
+}
+
+func (s *Service) reconcileFeeds() {
+	patterns := s.Hub.Patterns()
+	for _, f := range s.feeds {
+		s.updateFeed(f, filtersForStream(f.stream, patterns))
+	}
+}
+
+func (s *Service) updateFeed(f *feed, filters []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if slices.Equal(f.filters, filters) {
+		return
+	}
+
+	s.stopFeedUnsafe(f)
+	if len(filters) == 0 {
+		// la continuidad solo vale entre cambios de filtro: un feed nuevo arranca desde
+		// lo último, no re-entrega todo lo que pasó mientras no había nadie escuchando
+		f.lastSeq.Store(0)
+		logrus.WithField("stream", f.stream).Info("no interest left; feed stopped")
+		return
+	}
+
+	// seguir desde lo último entregado para no perder eventos al cambiar el filtro
+	var start uint64
+	if last := f.lastSeq.Load(); last > 0 {
+		start = last + 1
+	}
+
+	cons, err := s.EventStore.CreateOrderedConsumer(s.Ctx, f.stream, filters, start)
+	if err != nil {
+		// JetStream rechaza filtros que se solapan parcialmente; caer al stream completo
+		logrus.WithError(err).WithField("filters", filters).Warn("feed filters rejected; falling back to full stream")
+		filters = []string{fmt.Sprintf("%s.>", f.stream)}
+		if cons, err = s.EventStore.CreateOrderedConsumer(s.Ctx, f.stream, filters, start); err != nil {
+			logrus.WithError(err).WithField("stream", f.stream).Error("unable to create feed consumer")
+			return
+		}
+	}
+
+	cc, err := cons.Consume(func(msg jetstream.Msg) {
+		ev, seq, err := eventFromMsg(msg)
+		if err != nil {
+			logrus.WithError(err).WithField("subject", msg.Subject()).Warn("unable to decode event")
+			return
+		}
+		f.lastSeq.Store(seq)
+		s.Hub.BroadcastSeqJSON(msg.Subject(), seq, ev)
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("stream", f.stream).Error("feed consume failed")
+		s.deleteConsumer(f.stream, cons)
+		return
+	}
+
+	f.cons, f.cc, f.filters = cons, cc, filters
+	logrus.WithFields(logrus.Fields{"stream": f.stream, "filters": strings.Join(filters, ", "), "startSeq": start}).Info("feed updated")
+}
+
+func (s *Service) stopFeedUnsafe(f *feed) {
+	if f.cc != nil {
+		f.cc.Stop()
+	}
+	if f.cons != nil {
+		s.deleteConsumer(f.stream, f.cons)
+	}
+	f.cons, f.cc, f.filters = nil, nil, nil
+}
+
+// el server igual los borra por InactiveThreshold, pero no hace falta esperar
+func (s *Service) deleteConsumer(stream string, cons jetstream.Consumer) {
+	info := cons.CachedInfo()
+	if info == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.EventStore.DeleteConsumer(ctx, stream, info.Name); err != nil && !errors.Is(err, jetstream.ErrConsumerNotFound) {
+		logrus.WithError(err).WithField("consumer", info.Name).Warn("unable to delete feed consumer")
+	}
+}
+
+// StopFeeds corta y borra los consumers efímeros de este pod (shutdown).
+func (s *Service) StopFeeds() {
+	for _, f := range s.feeds {
+		f.mu.Lock()
+		s.stopFeedUnsafe(f)
+		f.lastSeq.Store(0)
+		f.mu.Unlock()
+	}
+}
+
+func (s *Service) FeedStats() []FeedStats {
+	out := make([]FeedStats, 0, len(s.feeds))
+	for _, f := range s.feeds {
+		f.mu.Lock()
+		out = append(out, FeedStats{Stream: f.stream, Filters: slices.Clone(f.filters), LastSeq: f.lastSeq.Load()})
+		f.mu.Unlock()
+	}
+	return out
+}
+
+// filtersForStream traduce los patrones de los clientes a filtros gruesos del stream
+// (<stream>.<categoría>.>) y descarta los que ya están cubiertos por otro (ej.
+// spheres.content.> bajo spheres.>).
+func filtersForStream(stream string, patterns []string) []string {
+	candidates := []string{}
+	for _, p := range patterns {
+		toks := strings.Split(p, ".")
+		switch toks[0] {
+		case stream:
+		case "*":
+			toks[0] = stream
+		case ">":
+			toks = []string{stream, ">"}
+		default:
+			continue
+		}
+		candidates = append(candidates, coarseFilter(toks))
+	}
+
+	out := []string{}
+	for i, c := range candidates {
+		covered := false
+		for j, o := range candidates {
+			if i == j {
+				continue
 			}
+			if covers(o, c) && (!covers(c, o) || j < i) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			out = append(out, c)
+		}
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// coarseFilter: el id (usuario, sphere, media) no entra en el filtro del consumer
+func coarseFilter(toks []string) string {
+	switch {
+	case len(toks) <= 2:
+		return strings.Join(toks, ".")
+	case toks[1] == "*" || toks[1] == ">":
+		return toks[0] + ".>"
+	default:
+		return toks[0] + "." + toks[1] + ".>"
+	}
+}
 
-			s.Hub.BroadcastJSON(msg.Subject(), ev)
-			return nil
-		})
+// covers indica si todo subject que matchea b también matchea a.
+func covers(a, b string) bool {
+	at := strings.Split(a, ".")
+	bt := strings.Split(b, ".")
+	for i, ta := range at {
+		if ta == ">" {
+			return len(bt) > i
+		}
+		if i >= len(bt) || bt[i] == ">" {
+			return false
+		}
+		if ta != "*" && ta != bt[i] {
+			return false
+		}
+	}
+	return len(at) == len(bt)
 }
@@ -1,10 +1,44 @@
 package service
 
-import "github.com/nats-io/nats.go"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"moonmap.io/go-commons/constants"
+	"moonmap.io/go-commons/ownhttp"
+)
 
 type NotifyEvent struct {
-	Subject string      `json:"subject"`
-	ID      string      `json:"id"`
-	Data    []byte      `json:"data"`
-	Header  nats.Header `json:"header"`
+	Subject string          `json:"subject"`
+	ID      string          `json:"id"`
+	Data    ownhttp.RawJSON `json:"data"` // raw JSON, no base64
+	Header  nats.Header     `json:"header"`
+}
+
+// eventFromMsg arma el payload que va al Hub y la secuencia del stream,
+// igual para el camino en vivo y para el replay.
+func eventFromMsg(msg jetstream.Msg) (any, uint64, error) {
+	var seq uint64
+	if md, err := msg.Metadata(); err == nil {
+		seq = md.Sequence.Stream
+	}
+
+	if strings.HasPrefix(msg.Subject(), constants.StreamNotify+".") {
+		msgID := msg.Headers().Get(nats.MsgIdHdr)
+		return NotifyEvent{
+			Subject: msg.Subject(),
+			ID:      fmt.Sprintf("%v:%v", msgID, seq),
+			Data:    ownhttp.RawJSON(msg.Data()),
+			Header:  msg.Headers(),
+		}, seq, nil
+	}
+
+	var ev map[string]any
+	if err := json.Unmarshal(msg.Data(), &ev); err != nil {
+		return nil, seq, err
+	}
+	return ev, seq, nil
 }
@@ -2,6 +2,7 @@ package service
 
 import (
 	"net/http"
+	"os"
 
 	"moonmap.io/go-commons/ownhttp"
 )
@@ -16,5 +17,34 @@ func (s *Service) routes() *http.ServeMux {
 		s.Hub.Add(w, r)
 	})
 
+	mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+		ownhttp.LogRequest(r)
+		if ownhttp.IsOptionsMethod(r, w) {
+			return
+		}
+		s.Hub.AddSSE(w, r)
+	})
+
+	// solo admins: el detalle por subject es operativo, no para clientes
+	mux.HandleFunc("/ws/stats", ownhttp.WithLogging("WsStats", ownhttp.WithAuth(s.Hub.Verifier, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			ownhttp.WriteJSONError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "method not allowed")
+			return
+		}
+		if !ownhttp.PrincipalFrom(r.Context()).HasRole(adminRole) {
+			ownhttp.WriteJSONError(w, http.StatusForbidden, "FORBIDDEN", "admins only")
+			return
+		}
+		hostname, _ := os.Hostname()
+		stats := s.Hub.Stats()
+		stats.BySubject = redactSubjects(stats.BySubject)
+		ownhttp.WriteJSON(w, http.StatusOK, map[string]any{
+			"pod":       hostname,
+			"startedAt": s.startedAt,
+			"hub":       stats,
+			"feeds":     s.FeedStats(),
+		})
+	})))
+
 	return mux
 }
@@ -0,0 +1,353 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/sirupsen/logrus"
+	"moonmap.io/go-commons/helpers"
+	"moonmap.io/go-commons/ownhttp"
+)
+
+const (
+	presenceBucket  = "presence"
+	presencePrefix  = "presence.sphere"
+	presenceTTL     = 30 * time.Second
+	presenceRefresh = 10 * time.Second
+)
+
+var (
+	errNotJoined   = errors.New("join the sphere first")
+	errAnonymous   = errors.New("anonymous sessions cannot send typing")
+	errTypingLimit = errors.New("typing rate limited")
+)
+
+// lo que cada pod guarda en KV por sphere: <sphereId>.<pod>
+type podPresence struct {
+	Users     map[string]int `json:"users"`
+	Anonymous int            `json:"anonymous"`
+}
+
+type PresenceEvent struct {
+	Type      string   `json:"type"` // snapshot|diff|typing
+	SphereID  string   `json:"sphereId"`
+	Users     []string `json:"users,omitempty"`
+	Anonymous int      `json:"anonymous,omitempty"`
+	Joined    []string `json:"joined,omitempty"`
+	Left      []string `json:"left,omitempty"`
+	UserID    string   `json:"userId,omitempty"`
+}
+
+// Presence lleva la presencia local del pod y la agrega entre réplicas vía KV.
+// Los diffs y typing viajan por core NATS en presence.sphere.<id>, sin stream.
+type Presence struct {
+	s   *Service
+	pod string
+	kv  jetstream.KeyValue
+
+	mu       sync.Mutex
+	rooms    map[string]*podPresence      // sphereId -> presencia local
+	conns    map[string]map[string]string // connId -> sphereId -> userId
+	typingAt map[string]time.Time         // connId.sphereId
+	kvMu     sync.Mutex
+	typingIv time.Duration
+}
+
+func (s *Service) CreatePresence() {
+	hostname, _ := os.Hostname()
+	hostname = strings.Split(hostname, ".")[0]
+
+	kv, err := s.EventStore.CreateOrUpdateKeyValue(s.Ctx, jetstream.KeyValueConfig{
+		Bucket:  presenceBucket,
+		TTL:     presenceTTL,
+		History: 1,
+		Storage: jetstream.MemoryStorage,
+	})
+	if err != nil {
+		logrus.WithError(err).Panic("unable to create presence bucket")
+		return
+	}
+
+	p := &Presence{
+		s:        s,
+		pod:      hostname,
+		kv:       kv,
+		rooms:    map[string]*podPresence{},
+		conns:    map[string]map[string]string{},
+		typingAt: map[string]time.Time{},
+		typingIv: helpers.GetEnvDur("WS_TYPING_INTERVAL", 3*time.Second),
+	}
+	s.Hub.Rooms = p
+
+	subject := fmt.Sprintf("%s.>", presencePrefix)
+	_, err = s.EventStore.GetConn().Subscribe(subject, func(m *nats.Msg) {
+		var ev PresenceEvent
+		if err := json.Unmarshal(m.Data, &ev); err != nil {
+			logrus.WithError(err).WithField("subject", m.Subject).Warn("invalid presence event")
+			return
+		}
+		s.Hub.BroadcastJSON(m.Subject, ev)
+	})
+	if err != nil {
+		logrus.Panic(err)
+		return
+	}
+
+	go p.refreshLoop()
+	logrus.Infof("subscribed to %s", subject)
+}
+
+func (p *Presence) RoomSubject(sphereID string) string {
+	return fmt.Sprintf("%s.%s", presencePrefix, sphereID)
+}
+
+func userOf(c *ownhttp.SocketConnection) string {
+	if c.Principal.IsAnonymous() {
+		return ""
+	}
+	return c.Principal.UserID
+}
+
+func (p *Presence) Join(c *ownhttp.SocketConnection, sphereID string) (any, error) {
+	userID := userOf(c)
+
+	p.mu.Lock()
+	if _, ok := p.conns[c.ID][sphereID]; ok {
+		p.mu.Unlock()
+		return p.snapshot(sphereID)
+	}
+	if p.conns[c.ID] == nil {
+		p.conns[c.ID] = map[string]string{}
+	}
+	p.conns[c.ID][sphereID] = userID
+
+	room := p.rooms[sphereID]
+	if room == nil {
+		room = &podPresence{Users: map[string]int{}}
+		p.rooms[sphereID] = room
+	}
+	firstLocal := false
+	if userID == "" {
+		room.Anonymous++
+	} else {
+		room.Users[userID]++
+		firstLocal = room.Users[userID] == 1
+	}
+	p.mu.Unlock()
+
+	// solo es "joined" si el usuario no estaba ya en otra réplica
+	if firstLocal && !p.presentElsewhere(sphereID, userID) {
+		p.publish(PresenceEvent{Type: "diff", SphereID: sphereID, Joined: []string{userID}})
+	}
+	p.flush(sphereID)
+
+	snap, err := p.snapshot(sphereID)
+	if err != nil {
+		p.Leave(c, sphereID)
+		return nil, err
+	}
+	return snap, nil
+}
+
+func (p *Presence) Leave(c *ownhttp.SocketConnection, sphereID string) {
+	p.mu.Lock()
+	userID, ok := p.conns[c.ID][sphereID]
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.conns[c.ID], sphereID)
+	if len(p.conns[c.ID]) == 0 {
+		delete(p.conns, c.ID)
+	}
+	delete(p.typingAt, c.ID+"."+sphereID)
+
+	lastLocal := false
+	if room := p.rooms[sphereID]; room != nil {
+		if userID == "" {
+			room.Anonymous = max(room.Anonymous-1, 0)
+		} else if room.Users[userID]--; room.Users[userID] <= 0 {
+			delete(room.Users, userID)
+			lastLocal = true
+		}
+		if len(room.Users) == 0 && room.Anonymous == 0 {
+			delete(p.rooms, sphereID)
+		}
+	}
+	p.mu.Unlock()
+
+	p.flush(sphereID)
+	if lastLocal && !p.presentElsewhere(sphereID, userID) {
+		p.publish(PresenceEvent{Type: "diff", SphereID: sphereID, Left: []string{userID}})
+	}
+}
+
+func (p *Presence) Disconnect(c *ownhttp.SocketConnection) {
+	p.mu.Lock()
+	spheres := make([]string, 0, len(p.conns[c.ID]))
+	for sphereID := range p.conns[c.ID] {
+		spheres = append(spheres, sphereID)
+	}
+	p.mu.Unlock()
+
+	for _, sphereID := range spheres {
+		p.Leave(c, sphereID)
+	}
+}
+
+func (p *Presence) Typing(c *ownhttp.SocketConnection, sphereID string) error {
+	userID := userOf(c)
+	if userID == "" {
+		return errAnonymous
+	}
+
+	key := c.ID + "." + sphereID
+	now := time.Now()
+
+	p.mu.Lock()
+	if _, ok := p.conns[c.ID][sphereID]; !ok {
+		p.mu.Unlock()
+		return errNotJoined
+	}
+	if last, ok := p.typingAt[key]; ok && now.Sub(last) < p.typingIv {
+		p.mu.Unlock()
+		return errTypingLimit
+	}
+	p.typingAt[key] = now
+	p.mu.Unlock()
+
+	p.publish(PresenceEvent{Type: "typing", SphereID: sphereID, UserID: userID})
+	return nil
+}
+
+func (p *Presence) publish(ev PresenceEvent) {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	if err := p.s.EventStore.GetConn().Publish(p.RoomSubject(ev.SphereID), b); err != nil {
+		logrus.WithError(err).WithField("sphereId", ev.SphereID).Warn("presence publish failed")
+	}
+}
+
+// flush escribe el estado local actual de la sphere en KV (o borra la key si quedó vacía).
+func (p *Presence) flush(sphereID string) {
+	p.kvMu.Lock()
+	defer p.kvMu.Unlock()
+
+	p.mu.Lock()
+	room := p.rooms[sphereID]
+	var b []byte
+	if room != nil {
+		b, _ = json.Marshal(room)
+	}
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	key := sphereID + "." + p.pod
+	var err error
+	if b == nil {
+		err = p.kv.Delete(ctx, key)
+	} else {
+		_, err = p.kv.Put(ctx, key, b)
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("key", key).Warn("presence kv write failed")
+	}
+}
+
+// las keys vencen por TTL si el pod muere; mientras vive las refresca
+func (p *Presence) refreshLoop() {
+	t := time.NewTicker(presenceRefresh)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-p.s.Ctx.Done():
+			return
+		case <-t.C:
+			p.mu.Lock()
+			spheres := make([]string, 0, len(p.rooms))
+			for sphereID := range p.rooms {
+				spheres = append(spheres, sphereID)
+			}
+			p.mu.Unlock()
+
+			for _, sphereID := range spheres {
+				p.flush(sphereID)
+			}
+		}
+	}
+}
+
+// pods devuelve la presencia de cada réplica para la sphere (incluida esta).
+func (p *Presence) pods(sphereID string) (map[string]podPresence, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	lister, err := p.kv.ListKeysFiltered(ctx, sphereID+".*")
+	if err != nil {
+		return nil, err
+	}
+	defer lister.Stop()
+
+	out := map[string]podPresence{}
+	for key := range lister.Keys() {
+		entry, err := p.kv.Get(ctx, key)
+		if err != nil {
+			continue
+		}
+		var pp podPresence
+		if json.Unmarshal(entry.Value(), &pp) == nil {
+			out[strings.TrimPrefix(key, sphereID+".")] = pp
+		}
+	}
+	return out, nil
+}
+
+func (p *Presence) presentElsewhere(sphereID, userID string) bool {
+	pods, err := p.pods(sphereID)
+	if err != nil {
+		return false
+	}
+	for pod, pp := range pods {
+		if pod != p.pod && pp.Users[userID] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Presence) snapshot(sphereID string) (any, error) {
+	pods, err := p.pods(sphereID)
+	if err != nil {
+		return nil, err
+	}
+
+	users := map[string]struct{}{}
+	anonymous := 0
+	for _, pp := range pods {
+		for uid := range pp.Users {
+			users[uid] = struct{}{}
+		}
+		anonymous += pp.Anonymous
+	}
+
+	ev := PresenceEvent{Type: "snapshot", SphereID: sphereID, Users: make([]string, 0, len(users)), Anonymous: anonymous}
+	for uid := range users {
+		ev.Users = append(ev.Users, uid)
+	}
+	sort.Strings(ev.Users)
+	return ev, nil
+}
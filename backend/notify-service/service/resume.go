@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/sirupsen/logrus"
+	"moonmap.io/go-commons/constants"
+	"moonmap.io/go-commons/helpers"
+	"moonmap.io/go-commons/ownhttp"
+)
+
+var errMixedStreams = errors.New("resume subjects must belong to a single stream")
+
+// streamForSubjects: las secuencias son por stream, así que un resume solo
+// puede cubrir subjects de un mismo stream.
+func streamForSubjects(subjects []string) (string, error) {
+	stream := ""
+	for _, subj := range subjects {
+		first := strings.SplitN(subj, ".", 2)[0]
+		switch first {
+		case constants.StreamNotify, constants.StreamSpheres:
+		default:
+			return "", fmt.Errorf("subject %s is not replayable", subj)
+		}
+		if stream != "" && stream != first {
+			return "", errMixedStreams
+		}
+		stream = first
+	}
+	return stream, nil
+}
+
+// replay crea un consumer ordenado efímero desde fromSeq y entrega lo que había
+// en el stream al momento del resume; lo posterior llega por el camino en vivo.
+func (s *Service) replay(ctx context.Context, subjects []string, fromSeq uint64, emit ownhttp.ReplayEmitter) (uint64, error) {
+	stream, err := streamForSubjects(subjects)
+	if err != nil {
+		return 0, err
+	}
+
+	state, err := s.EventStore.StreamState(ctx, stream)
+	if err != nil {
+		return 0, err
+	}
+
+	start := fromSeq
+	if state.FirstSeq > fromSeq {
+		// la retención ya borró parte de lo pedido
+		gap := ownhttp.ReplayGap{FromSeq: fromSeq, FirstSeq: state.FirstSeq, Reason: "retention", Subjects: subjects}
+		if err := emit("gap", 0, gap); err != nil {
+			return 0, err
+		}
+		start = state.FirstSeq
+	}
+
+	if start > state.LastSeq {
+		return state.LastSeq, nil
+	}
+
+	consumer, err := s.EventStore.CreateOrderedConsumer(ctx, stream, subjects, start)
+	if err != nil {
+		return 0, err
+	}
+
+	maxEvents := helpers.GetEnvInt("WS_RESUME_MAX_EVENTS", 1000)
+	// last: hasta dónde se entregó; al terminar completo se devuelve LastSeq
+	// para que el Hub descarte duplicados del buffer en vivo
+	var last uint64
+	sent := 0
+
+	for {
+		batch, err := consumer.Fetch(256, jetstream.FetchMaxWait(time.Second))
+		if err != nil {
+			return last, err
+		}
+
+		got := 0
+		for msg := range batch.Messages() {
+			got++
+			md, err := msg.Metadata()
+			if err != nil {
+				continue
+			}
+			if md.Sequence.Stream > state.LastSeq {
+				return state.LastSeq, nil
+			}
+
+			if sent >= maxEvents {
+				gap := ownhttp.ReplayGap{FromSeq: md.Sequence.Stream, Reason: "truncated", Subjects: subjects}
+				return last, emit("gap", 0, gap)
+			}
+
+			ev, seq, err := eventFromMsg(msg)
+			if err != nil {
+				logrus.WithError(err).WithField("subject", msg.Subject()).Warn("replay: skipping undecodable event")
+				continue
+			}
+			if err := emit(msg.Subject(), seq, ev); err != nil {
+				return last, err
+			}
+			last = seq
+			sent++
+
+			if md.NumPending == 0 || seq >= state.LastSeq {
+				return state.LastSeq, nil
+			}
+		}
+		if err := batch.Error(); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return last, err
+		}
+		if ctx.Err() != nil {
+			return last, ctx.Err()
+		}
+		if got == 0 {
+			// nada más para estos subjects hasta LastSeq
+			return state.LastSeq, nil
+		}
+	}
+}
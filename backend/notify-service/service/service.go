@@ -9,6 +9,8 @@ import (
 	"moonmap.io/go-commons/messages"
 	"moonmap.io/go-commons/ownhttp"
 	"moonmap.io/go-commons/system"
+
+	"github.com/sirupsen/logrus"
 )
 
 type Service struct {
@@ -19,6 +21,7 @@ type Service struct {
 	Origin      string
 	Hub         *ownhttp.Hub
 	AlertClient *messages.AlertServiceClient
+	feeds       []*feed
 }
 
 func New() *Service {
@@ -34,6 +37,18 @@ func New() *Service {
 	}
 
 	s.Hub.Mode = "subjects"
+	s.Hub.Authorize = authorizeSubject
+	s.Hub.Replay = s.replay
+	// mismo secreto que spheres-service; WS_AUTH_SECRET era el nombre viejo y no puede divergir
+	secret := helpers.GetEnv(ownhttp.AuthSecretEnv, "")
+	if legacy := helpers.GetEnv("WS_AUTH_SECRET", ""); legacy != "" && legacy != secret {
+		logrus.Fatalf("WS_AUTH_SECRET is no longer read; set %s (shared with spheres-service) instead", ownhttp.AuthSecretEnv)
+	}
+	if secret != "" {
+		s.Hub.Verifier = ownhttp.NewHMACVerifier(secret)
+	} else {
+		logrus.Warnf("%s not set; websocket sessions are anonymous", ownhttp.AuthSecretEnv)
+	}
 	s.EventStore = system.NewEventStore(constants.NotifyServiceName)
 	return s
 }
@@ -51,8 +66,8 @@ func (s *Service) Config(ctx context.Context, cancelFunc context.CancelFunc) {
 	s.CreateStreamSolana()
 	s.CreateStreamSpheres()
 
-	s.CreateConsumerSpheres()
-	s.CreateSubscriberNotify()
+	s.CreateInterestFeeds()
+	s.CreatePresence()
 }
 
 func (s *Service) Start(sys *system.System) {
@@ -68,5 +83,6 @@ func (s *Service) Start(sys *system.System) {
 		ownhttp.NewServer(ctx, constants.NotifyServiceName, sys.Bind, s.routes(), &opts)
 		<-ctx.Done()
 		s.Hub.Close()
+		s.StopFeeds()
 	})
 }
@@ -16,13 +16,14 @@ func (s *Service) CreateStreamMedia() {
 
 	// media.uploaded.<mediaType>.<scopeType>.<scopeId>.<profile>.<entityId>
 	// media.process.started.<mediaId>
-	// media.process.completed.<mediaId>
-	// media.process.failed.<mediaId>
+	// media.process.completed.<mediaType>.<scopeType>.<scopeId>.<profile>.<entityId>
+	// media.process.failed.<mediaType>.<scopeType>.<scopeId>.<profile>.<entityId>
+	// media.process.quarantined.<mediaType>.<scopeType>.<scopeId>.<profile>.<entityId>
 	// media.reprocess.<mediaId>
 	// media.delete.<mediaId>
 
 	// mediaType: image|gif|video
-	// scopeType: user|community|project
+	// scopeType: user|community|project|sphere
 	// profile: avatar|post_image|post_video|logo|banner|cove
 
 	// this could be moved to another service who just configures nats
@@ -217,6 +217,10 @@ func (s *Service) removeMediaForProject(ctx context.Context, projectId *bson.Obj
 	// Construir keys de S3 (ejemplo: todos los prefijos bajo projects/{projectId}/)
 	prefix := fmt.Sprintf("projects/%s/", projectId.Hex())
 
+	// dedupe: variantes que otros uploads siguen usando no se borran con el prefijo,
+	// y las de un blob que se liberó pueden estar fuera del prefijo
+	keep, extra := s.releaseProjectBlobs(ctx, projectId, prefix)
+
 	// Listar objetos bajo ese prefijo
 	listOut, err := s.S3c.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
 		Bucket: aws.String(s.S3Cfg.S3Bucket),
@@ -226,13 +230,21 @@ func (s *Service) removeMediaForProject(ctx context.Context, projectId *bson.Obj
 		mainLog.Warnf("error while listing prefix %v from s3 bucket %v. %v", prefix, s.S3Cfg.S3Bucket, err.Error())
 	}
 
-	if len(listOut.Contents) > 0 {
-		// Preparar batch de deletes
-		var objects []types.ObjectIdentifier
+	// Preparar batch de deletes
+	var objects []types.ObjectIdentifier
+	if listOut != nil {
 		for _, o := range listOut.Contents {
+			if keep[aws.ToString(o.Key)] {
+				continue
+			}
 			objects = append(objects, types.ObjectIdentifier{Key: o.Key})
 		}
+	}
+	for _, k := range extra {
+		objects = append(objects, types.ObjectIdentifier{Key: aws.String(k)})
+	}
 
+	if len(objects) > 0 {
 		_, err = s.S3c.DeleteObjects(ctx, &s3.DeleteObjectsInput{
 			Bucket: aws.String(s.S3Cfg.S3Bucket),
 			Delete: &types.Delete{Objects: objects},
@@ -259,3 +271,40 @@ func (s *Service) removeMediaForProject(ctx context.Context, projectId *bson.Obj
 	}
 
 }
+
+func (s *Service) releaseProjectBlobs(ctx context.Context, projectId *bson.ObjectID, prefix string) (map[string]bool, []string) {
+	keep := map[string]bool{}
+	var extra []string
+
+	cur, err := s.mediaColl.Find(ctx, bson.M{
+		"namespace": "projects",
+		"entityId":  projectId.Hex(),
+		"blobId":    bson.M{"$exists": true},
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("entityId", projectId.Hex()).Warn("unable to list deduped media")
+		return keep, nil
+	}
+	var docs []persistence.MediaDoc
+	if err := cur.All(ctx, &docs); err != nil {
+		logrus.WithError(err).WithField("entityId", projectId.Hex()).Warn("unable to list deduped media")
+		return keep, nil
+	}
+
+	for i := range docs {
+		rel, err := persistence.ReleaseMedia(ctx, s.blobsColl, &docs[i])
+		if err != nil {
+			logrus.WithError(err).WithField("key", docs[i].Key).Warn("unable to release media blob")
+			continue
+		}
+		for _, k := range rel.Keep {
+			keep[k] = true
+		}
+		for _, k := range rel.Delete {
+			if !strings.HasPrefix(k, prefix) {
+				extra = append(extra, k)
+			}
+		}
+	}
+	return keep, extra
+}
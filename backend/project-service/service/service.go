@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/sirupsen/logrus"
@@ -20,7 +21,9 @@ type Service struct {
 	ctx        context.Context
 	coll       *mongo.Collection
 	mediaColl  *mongo.Collection
+	blobsColl  *mongo.Collection
 	EventStore *system.NatsEventStore
+	limiter    *ownhttp.RateLimiter
 
 	S3Cfg     *system.S3Config
 	S3c       *s3.Client
@@ -35,8 +38,10 @@ func (s *Service) Config(ctx context.Context) {
 	s.ctx = ctx
 	s.coll = persistence.MustGetCollection(constants.ProjectsCollectionName)
 	s.mediaColl = persistence.MustGetCollection(constants.MediaAssetsCollectionName)
+	s.blobsColl = persistence.MustGetCollection(constants.MediaBlobsCollectionName)
 
 	s.EventStore = system.NewEventStore(constants.ProjectServiceName)
+	s.limiter = system.NewRateLimiter(ctx, s.EventStore)
 
 	cfg, s3c, p := system.LoadS3(ctx)
 	s.S3c = s3c
@@ -57,7 +62,7 @@ func (s *Service) Config(ctx context.Context) {
 
 func (s *Service) routes() *http.ServeMux {
 	mux := ownhttp.Routes()
-	mux.HandleFunc("/projects", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/projects", s.limiter.Limit("projects", ownhttp.Per(30, time.Minute, ownhttp.KeyByIP), func(w http.ResponseWriter, r *http.Request) {
 		ownhttp.LogRequest(r)
 		if ownhttp.IsOptionsMethod(r, w) {
 			return
@@ -69,7 +74,7 @@ func (s *Service) routes() *http.ServeMux {
 		}
 
 		s.HandleCreateOrUpdateProject(w, r)
-	})
+	}))
 
 	mux.HandleFunc("/project/", func(w http.ResponseWriter, r *http.Request) {
 		ownhttp.LogRequest(r)
@@ -2,11 +2,13 @@ package consumer
 
 import (
 	"encoding/json"
-	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/sirupsen/logrus"
+	"moonmap.io/go-commons/constants"
 	"moonmap.io/s3-service/core"
 )
 
@@ -39,13 +41,38 @@ func (c *Consumer) createTransformConsumer() {
 				return err
 			}
 
+			// el worker hace ack; el in-progress corre desde ahora para que la espera
+			// en la cola no cuente contra el AckWait
+			stop := heartbeat(msg, c.service.AckProgressEvery, media.Key)
 			select {
-			case c.service.MediaChannel <- media:
+			case c.service.MediaChannel <- core.MediaJob{Media: media, Msg: msg, Stop: stop}:
 			default:
-				err := fmt.Errorf("queue full")
-				mainLog.Error(err)
-				return err
+				stop()
+				// cola llena: nak con backoff en vez de redelivery inmediato
+				mainLog.WithField("key", media.Key).Warn("queue full")
+				return constants.ErrNotReady
 			}
-			return nil
+			return constants.ErrAckDeferred
 		})
 }
+
+// heartbeat manda InProgress cada `every` hasta que se llama a stop
+func heartbeat(msg jetstream.Msg, every time.Duration, key string) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if err := msg.InProgress(); err != nil {
+					logrus.WithError(err).WithField("key", key).Warn("in-progress ack failed")
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
@@ -3,14 +3,15 @@ package consumer
 import (
 	"bytes"
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/h2non/bimg"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -40,6 +41,27 @@ func (c *Consumer) notify(doc *persistence.MediaDoc, status string, ok bool) {
 	}
 }
 
+// publishProcessed avisa en el stream media que el asset terminó (ready), falló o quedó
+// en revisión, con el subject por scope para que el servicio dueño (ej: spheres) filtre lo suyo:
+// media.process.<completed|failed|quarantined>.<mediaType>.<scopeType>.<scopeId>.<profile>.<entityId>
+func (c *Consumer) publishProcessed(doc *persistence.MediaDoc, status string) {
+	outcome := "failed"
+	switch status {
+	case "ready":
+		outcome = "completed"
+	case "quarantined":
+		outcome = "quarantined"
+	}
+	data := core.MediaStateFromDocument(doc)
+	data.Status = status
+	subject := doc.CreateMediaSubject("process." + outcome)
+	msgID := doc.CreateMessageId() + ":" + outcome
+	err := c.service.EventStore.PublishJSON(constants.StreamMedia, subject, msgID, data, nil)
+	if err != nil {
+		logrus.WithError(err).WithField("subject", subject).Error("failed: publishing. Verify connection to NATS server")
+	}
+}
+
 func (c *Consumer) UpdateAsset(ctx context.Context, doc *persistence.MediaDoc, nextStatus string) {
 	mainLog := logrus.WithFields(logrus.Fields{"key": doc.Key})
 	mainFilter := map[string]any{"key": doc.Key}
@@ -59,6 +81,9 @@ func (c *Consumer) UpdateAsset(ctx context.Context, doc *persistence.MediaDoc, n
 	doc.Status = nextStatus
 	mainLog.Infof("media asset update from  status %v->%v", doc.Status, nextStatus)
 	c.notify(doc, nextStatus, true)
+	if nextStatus == "failed" {
+		c.publishProcessed(doc, nextStatus)
+	}
 }
 
 func (c *Consumer) RemoveObject(ctx context.Context, doc *persistence.MediaDoc) {
@@ -77,136 +102,119 @@ func (c *Consumer) RemoveObject(ctx context.Context, doc *persistence.MediaDoc)
 	mainLog.Info("media asset removed")
 }
 
-func (c *Consumer) process(media core.MediaState) {
-	ctx, cancel := context.WithTimeout(c.service.Ctx, 2*time.Minute)
+// run procesa el job y recién entonces hace ACK; mientras tanto mantiene vivo el mensaje
+// con InProgress para que JetStream no lo reentregue (un video tarda más que el AckWait).
+func (c *Consumer) run(job core.MediaJob, res *core.Reservation) {
+	err := c.process(job.Media, res)
+	job.Stop()
+
+	if errors.Is(err, errBlobBusy) {
+		// el mismo archivo lo está procesando otro upload: se vuelve a intentar y para
+		// entonces lo más probable es que solo haya que linkear
+		if err := job.Msg.NakWithDelay(c.service.AckProgressEvery); err != nil {
+			logrus.WithError(err).WithField("key", job.Media.Key).Error("nak failed")
+		}
+		return
+	}
+	if errors.Is(err, errShutdown) {
+		// cortado a medias por el apagado: que lo tome otro pod
+		if err := job.Msg.Nak(); err != nil {
+			logrus.WithError(err).WithField("key", job.Media.Key).Error("nak failed")
+		}
+		return
+	}
+	if err := job.Msg.Ack(); err != nil {
+		logrus.WithError(err).WithField("key", job.Media.Key).Error("ack failed")
+	}
+}
+
+// process devuelve error solo cuando el job se tiene que reintentar (errBlobBusy,
+// errShutdown)
+func (c *Consumer) process(media core.MediaState, res *core.Reservation) error {
+	// ffmpeg puede correr varias veces por asset (poster + 2 transcodes + probes)
+	ctx, cancel := context.WithTimeout(c.service.Ctx, c.jobTimeout())
 	defer cancel()
 
 	var doc persistence.MediaDoc
 	if err := c.service.Coll.FindOne(ctx, bson.M{"key": media.Key}).Decode(&doc); err != nil {
+		if c.service.Ctx.Err() != nil {
+			return errShutdown
+		}
 		logrus.WithError(err).WithField("key", media.Key).Error("doc not found")
-		return
+		return nil
 	}
 
 	c.UpdateAsset(ctx, &doc, "processing")
 	// TODO: notify proccesing
 
-	// 2) descarga original
-	targetObj := &s3.GetObjectInput{
-		Bucket: aws.String(c.service.S3Bucket),
-		Key:    aws.String(media.Key),
+	var (
+		p   *processed
+		err error
+	)
+	switch doc.MediaType {
+	case "GIF", "VIDEO":
+		p, err = c.processMotion(ctx, &doc)
+	default:
+		p, err = c.processImage(ctx, &doc, res)
 	}
-	obj, err := c.service.S3c.GetObject(ctx, targetObj)
-	if err != nil {
-		c.UpdateAsset(ctx, &doc, "failed")
-		logrus.WithError(err).WithFields(logrus.Fields{
-			"key":    doc.Key,
-			"bucket": c.service.S3Bucket,
-		}).Errorln("error while downloading object from s3 bucket")
-		return
+	if errors.Is(err, errBlobBusy) {
+		return err
 	}
-
-	defer obj.Body.Close()
-
-	buf := new(bytes.Buffer)
-	if _, err := buf.ReadFrom(obj.Body); err != nil {
+	if c.service.Ctx.Err() != nil {
+		// apagando: el resultado puede estar incompleto (ffmpeg/S3 cortados). El blob se
+		// libera (failed) para que la reentrega lo tome sin esperar a jobTimeout.
+		if p != nil && p.Owner {
+			rctx, rcancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			c.settleBlob(rctx, &doc, &processed{Blob: p.Blob})
+			rcancel()
+		}
+		return errShutdown
+	}
+	if err != nil {
 		c.UpdateAsset(ctx, &doc, "failed")
-		logrus.WithError(err).WithFields(logrus.Fields{
+		log := logrus.WithError(err).WithFields(logrus.Fields{
 			"key":    doc.Key,
 			"bucket": c.service.S3Bucket,
-		}).Errorln("error while reading buffer from s3 bucket")
-		return
-	}
-
-	orig := buf.Bytes()
-
-	// Bytes en memoria: corta si supera tu límite (defensa de doble capa)
-	sentBytes := int64(len(orig))
-	if sentBytes > c.service.MaxUploadBytes {
-		c.UpdateAsset(ctx, &doc, "failed")
-		c.RemoveObject(ctx, &doc)
-		logrus.WithFields(logrus.Fields{
-			"key":   doc.Key,
-			"bytes": sentBytes,
-		}).Errorln("media asset too big. Will be removed from s3")
-		return
-	}
-
-	// Dimensiones (px)
-	size, err := bimg.NewImage(orig).Size()
-	if err != nil || int64(size.Width)*int64(size.Height) > c.service.MaxPixels || size.Width <= 0 || size.Height <= 0 {
-		c.UpdateAsset(ctx, &doc, "failed")
-		c.RemoveObject(ctx, &doc)
-		logrus.WithFields(logrus.Fields{
-			"key":  doc.Key,
-			"size": size,
-		}).Errorln("media asset wrong dimensions. Will be removed from s3")
-		return
-	}
-
-	sum := sha256.Sum256(orig)
-	chk := hex.EncodeToString(sum[:])
-
-	out := make([]persistence.MediaVariant, 0, len(doc.Planned))
-	for _, pv := range doc.Planned {
-
-		if !strings.HasPrefix(pv.Mime, "image/") {
-			// TODO: gif/video pipeline futura
-			continue
-		}
-
-		proccessObj := bimg.Options{
-			Type:          bimg.WEBP,
-			Quality:       80,
-			StripMetadata: true,
-			Width:         pv.W,
-			Height:        pv.H,
-			Enlarge:       false,
-		}
-		img, e := bimg.NewImage(orig).Process(proccessObj)
-		if e != nil {
-			logrus.WithError(e).WithField("key", pv.Key).Warn("variant process failed")
-			continue
-		}
-
-		acl := types.ObjectCannedACLPrivate
-		if c.service.S3PublicAcl {
-			acl = types.ObjectCannedACLPublicRead
-		}
-
-		_, e = c.service.S3c.PutObject(ctx, &s3.PutObjectInput{
-			Bucket:       aws.String(c.service.S3Bucket),
-			Key:          aws.String(pv.Key),
-			Body:         bytes.NewReader(img),
-			ACL:          acl,
-			ContentType:  aws.String(pv.Mime),
-			CacheControl: aws.String("public, max-age=31536000, immutable"),
 		})
-
-		if e != nil {
-			logrus.WithError(e).WithField("key", pv.Key).Warn("put variant failed")
-			continue
+		if errors.Is(err, errRejected) {
+			c.RemoveObject(ctx, &doc)
+			log.Errorln("media asset rejected. Will be removed from s3")
+			return nil
 		}
-
-		vsz, _ := bimg.NewImage(img).Size()
-		out = append(out, persistence.MediaVariant{
-			Key: pv.Key, W: vsz.Width, H: vsz.Height, Bytes: int64(len(img)),
-		})
+		log.Errorln("error while processing media asset")
+		return nil
+	}
+	if p.Owner {
+		c.settleBlob(ctx, &doc, p)
+	}
+	if isQuarantined(p.Screening) {
+		c.quarantine(ctx, &doc, p)
+		return nil
 	}
 
-	orig = nil
 	status := "ready"
-	if len(out) == 0 {
+	if len(p.Variants) == 0 {
 		status = "failed"
 	}
 
 	set := bson.M{
-		"checksum":        chk,
-		"variants":        out,
+		"checksum":        p.Checksum,
+		"variants":        p.Variants,
 		"status":          status,
-		"width":           size.Width,
-		"height":          size.Height,
+		"width":           p.Width,
+		"height":          p.Height,
 		"pipelineVersion": core.PipelineVersion,
 	}
+	if status == "ready" {
+		set["blobId"] = p.Blob.ID
+	}
+	if !p.Owner {
+		// duplicado: mismas variantes que el blob, sin procesar ni subir nada
+		set["urls"] = sharedUrls(&doc, p.Blob)
+	}
+	if p.Screening != nil {
+		set["screening"] = p.Screening
+	}
 
 	filter := bson.M{"key": doc.Key}
 	update := bson.M{
@@ -229,15 +237,159 @@ func (c *Consumer) process(media core.MediaState) {
 			logrus.WithError(err).Error("findOneAndUpdate failed")
 		}
 
-		return
+		return nil
 	}
 
 	logrus.WithFields(logrus.Fields{
 		"key":        doc.Key,
 		"uploaderId": media.UploaderID,
+		"variants":   len(p.Variants),
+		"blobId":     p.Blob.ID,
+		"dedupe":     !p.Owner,
 	}).Info("media processed successfully")
 
 	doc.Status = status
 	c.notify(&updated, status, true)
+	c.publishProcessed(&updated, status)
+	return nil
+}
+
+// processImage: el original se decodifica una sola vez, para la variante más grande;
+// las demás salen en cascada de la anterior (mucho menos pixeles que el original).
+func (c *Consumer) processImage(ctx context.Context, doc *persistence.MediaDoc, res *core.Reservation) (*processed, error) {
+	buf := new(bytes.Buffer)
+	_, chk, err := c.download(ctx, doc, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dimensiones (px), solo lee el header
+	size, err := bimg.NewImage(buf.Bytes()).Size()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errRejected, err)
+	}
+	if int64(size.Width)*int64(size.Height) > c.service.MaxPixels || size.Width <= 0 || size.Height <= 0 {
+		return nil, fmt.Errorf("%w: wrong dimensions %dx%d", errRejected, size.Width, size.Height)
+	}
+
+	// los hashes ya decodifican el original: la reserva pasa al costo real antes
+	if err := res.Grow(ctx, int64(buf.Len())+core.DecodeCost(size.Width, size.Height)); err != nil {
+		return nil, fmt.Errorf("memory budget wait aborted: %w", err)
+	}
+
+	scr, err := c.screen(doc, buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if isQuarantined(scr) {
+		return &processed{Width: size.Width, Height: size.Height, Checksum: chk, Screening: scr}, nil
+	}
+
+	blob, owner, err := c.claimBlob(ctx, doc, chk)
+	if err != nil {
+		return nil, err
+	}
+	if !owner {
+		return &processed{Variants: blob.Variants, Width: blob.Width, Height: blob.Height, Checksum: chk, Blob: blob, Screening: scr}, nil
+	}
+	p := &processed{Width: size.Width, Height: size.Height, Checksum: chk, Blob: blob, Owner: true, Screening: scr}
+
+	// el clasificador externo corre una vez por contenido (los duplicados reusan el blob)
+	c.service.Screener.Classify(ctx, buf.Bytes(), scr)
+	if isQuarantined(scr) {
+		return p, nil
+	}
+
+	planned := make([]persistence.PlannedVariantDB, 0, len(doc.Planned))
+	for _, pv := range doc.Planned {
+		if strings.HasPrefix(pv.Mime, "image/") {
+			planned = append(planned, pv)
+		}
+	}
+	// mayor a menor; W=0 es tamaño original
+	sort.SliceStable(planned, func(i, j int) bool {
+		wi, wj := planned[i].W, planned[j].W
+		if wi == 0 || wj == 0 {
+			return wi == 0 && wj != 0
+		}
+		return wi > wj
+	})
 
+	// el original se decodifica una sola vez, a un intermedio sin pérdida del tamaño de
+	// la variante más grande; las variantes salen de ahí.
+	iw := intermediateWidth(planned, size.Width, size.Height)
+	ih := max(1, int(math.Ceil(float64(size.Height)*float64(iw)/float64(size.Width))))
+
+	// ahora que se conocen los pixeles, la reserva pasa a ser la real: original descargado
+	// y decodificado + intermedio en pixeles y en png (que no pasa de los pixeles crudos)
+	// (si se corta esperando, sin variantes => failed y el blob se libera)
+	if err := res.Grow(ctx, int64(buf.Len())+core.DecodeCost(size.Width, size.Height)+2*core.DecodeCost(iw, ih)); err != nil {
+		logrus.WithError(err).WithField("key", doc.Key).Warn("memory budget wait aborted")
+		return p, nil
+	}
+
+	src := buf.Bytes()
+	if len(planned) > 1 {
+		inter, e := bimg.NewImage(src).Process(bimg.Options{
+			Type:        bimg.PNG,
+			Compression: 1,
+			Width:       iw,
+			Enlarge:     false,
+		})
+		if e != nil {
+			logrus.WithError(e).WithField("key", doc.Key).Warn("intermediate process failed")
+			return p, nil
+		}
+		// el original ya se puede liberar
+		src, buf = inter, nil
+	}
+
+	p.Variants = make([]persistence.MediaVariant, 0, len(planned))
+	for _, pv := range planned {
+		proccessObj := bimg.Options{
+			Type:          bimg.WEBP,
+			Quality:       80,
+			StripMetadata: true,
+			Width:         pv.W,
+			Height:        pv.H,
+			Enlarge:       false,
+		}
+		img, e := bimg.NewImage(src).Process(proccessObj)
+		if e != nil {
+			logrus.WithError(e).WithField("key", pv.Key).Warn("variant process failed")
+			continue
+		}
+
+		if _, e := c.putStream(ctx, pv.Key, pv.Mime, bytes.NewReader(img)); e != nil {
+			logrus.WithError(e).WithField("key", pv.Key).Warn("put variant failed")
+			continue
+		}
+
+		vsz, _ := bimg.NewImage(img).Size()
+		p.Variants = append(p.Variants, persistence.MediaVariant{
+			Key: pv.Key, W: vsz.Width, H: vsz.Height, Bytes: int64(len(img)),
+		})
+	}
+	return p, nil
+}
+
+// intermediateWidth: el ancho más chico del que salen todas las variantes sin agrandar.
+// Con alto fijo la variante puede recortar, así que se toma la escala que cubre ambos lados.
+func intermediateWidth(planned []persistence.PlannedVariantDB, w, h int) int {
+	scale := 0.0
+	for _, pv := range planned {
+		if pv.W <= 0 && pv.H <= 0 {
+			return w
+		}
+		if pv.W > 0 {
+			scale = max(scale, float64(pv.W)/float64(w))
+		}
+		if pv.H > 0 {
+			scale = max(scale, float64(pv.H)/float64(h))
+		}
+	}
+	if scale <= 0 || scale >= 1 {
+		return w
+	}
+	return min(w, int(math.Ceil(float64(w)*scale)))
 }
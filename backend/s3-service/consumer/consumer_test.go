@@ -0,0 +1,64 @@
+package consumer
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"moonmap.io/go-commons/persistence"
+)
+
+type progressMsg struct {
+	jetstream.Msg
+	n atomic.Int32
+}
+
+func (m *progressMsg) InProgress() error {
+	m.n.Add(1)
+	return nil
+}
+
+func TestHeartbeat(t *testing.T) {
+	msg := &progressMsg{}
+	stop := heartbeat(msg, 10*time.Millisecond, "k")
+
+	deadline := time.Now().Add(time.Second)
+	for msg.n.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("in-progress sent %d times, want >= 3", msg.n.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	stop()
+	stop() // idempotente
+	time.Sleep(20 * time.Millisecond)
+	n := msg.n.Load()
+	time.Sleep(50 * time.Millisecond)
+	if got := msg.n.Load(); got != n {
+		t.Fatalf("in-progress after stop: %d -> %d", n, got)
+	}
+}
+
+func TestIntermediateWidth(t *testing.T) {
+	pv := func(w, h int) persistence.PlannedVariantDB { return persistence.PlannedVariantDB{W: w, H: h} }
+
+	cases := []struct {
+		name    string
+		planned []persistence.PlannedVariantDB
+		w, h    int
+		want    int
+	}{
+		{"original-size variant", []persistence.PlannedVariantDB{pv(0, 0), pv(320, 0)}, 4000, 3000, 4000},
+		{"largest width", []persistence.PlannedVariantDB{pv(1280, 0), pv(320, 0)}, 4000, 3000, 1280},
+		// 200x200 recortado de un panorama: manda el alto
+		{"fixed height covers", []persistence.PlannedVariantDB{pv(640, 0), pv(200, 200)}, 4000, 1000, 800},
+		{"never enlarges", []persistence.PlannedVariantDB{pv(1280, 0)}, 800, 600, 800},
+	}
+	for _, c := range cases {
+		if got := intermediateWidth(c.planned, c.w, c.h); got != c.want {
+			t.Errorf("%s: intermediateWidth = %d, want %d", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"moonmap.io/go-commons/persistence"
+	"moonmap.io/s3-service/core"
+)
+
+// errBlobBusy: otro upload con el mismo contenido se está procesando; se reintenta después
+var errBlobBusy = errors.New("blob being processed by another upload")
+
+// errShutdown: el job se cortó por el apagado del consumer; nak para que lo tome otro pod
+var errShutdown = errors.New("consumer shutting down")
+
+// processed: resultado de processImage/processMotion
+type processed struct {
+	Variants      []persistence.MediaVariant
+	Width, Height int
+	Checksum      string
+	Blob          *persistence.MediaBlob
+	Owner         bool // este upload genera las variantes del blob; si no, las reusa
+	Screening     *persistence.MediaScreening
+}
+
+// jobTimeout: lo máximo que puede durar process; un blob en processing más viejo que
+// esto quedó huérfano (pod caído) y otro upload lo puede tomar.
+func (c *Consumer) jobTimeout() time.Duration {
+	return 2*time.Minute + 3*c.service.Video.Timeout
+}
+
+// claimBlob decide si este upload procesa (owner) o reusa las variantes de otro con el
+// mismo checksum. Todas las transiciones son condicionales sobre el doc del blob, así
+// dos uploads iguales en paralelo no generan dos veces ni linkean a algo que se borra.
+func (c *Consumer) claimBlob(ctx context.Context, doc *persistence.MediaDoc, chk string) (*persistence.MediaBlob, bool, error) {
+	id := persistence.MediaBlobID(chk, doc.Profile, core.PipelineVersion)
+	now := time.Now()
+
+	for range 3 {
+		ins, err := c.service.Blobs.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+			"$setOnInsert": bson.M{
+				"checksum":        chk,
+				"profile":         doc.Profile,
+				"pipelineVersion": core.PipelineVersion,
+				"owner":           doc.Key,
+				"status":          "processing",
+				"variants":        []persistence.MediaVariant{},
+				"holders":         []string{},
+				"refs":            0,
+				"createdAt":       now,
+				"updatedAt":       now,
+			},
+		}, options.UpdateOne().SetUpsert(true))
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return nil, false, err
+		}
+		if err == nil && ins.UpsertedCount == 1 {
+			return &persistence.MediaBlob{
+				ID: id, Checksum: chk, Profile: doc.Profile, PipelineVersion: core.PipelineVersion,
+				Owner: doc.Key, Status: "processing", CreatedAt: now, UpdatedAt: now,
+			}, true, nil
+		}
+
+		var blob persistence.MediaBlob
+		if err := c.service.Blobs.FindOne(ctx, bson.M{"_id": id}).Decode(&blob); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				continue // se liberó entre medio
+			}
+			return nil, false, err
+		}
+
+		switch {
+		case blob.Status == "ready":
+			// link: solo si sigue ready (no released) en el momento de escribir
+			res, err := c.service.Blobs.UpdateOne(ctx,
+				bson.M{"_id": id, "status": "ready"},
+				persistence.BlobHoldersUpdate(doc.Key, true, nil),
+			)
+			if err != nil {
+				return nil, false, err
+			}
+			if res.MatchedCount == 0 {
+				continue
+			}
+			return &blob, false, nil
+
+		case blob.Status == "processing" && now.Sub(blob.UpdatedAt) < c.jobTimeout():
+			// también si el owner es este mismo upload: una reentrega mientras la primera
+			// entrega sigue viva no puede correr en paralelo como owner
+			return nil, false, errBlobBusy
+		}
+
+		// failed, released o processing huérfano: se lo queda este upload
+		res, err := c.service.Blobs.UpdateOne(ctx,
+			bson.M{"_id": id, "status": blob.Status, "owner": blob.Owner, "updatedAt": blob.UpdatedAt},
+			bson.M{"$set": bson.M{"owner": doc.Key, "status": "processing", "updatedAt": now}},
+		)
+		if err != nil {
+			return nil, false, err
+		}
+		if res.ModifiedCount == 1 {
+			blob.Owner, blob.Status = doc.Key, "processing"
+			return &blob, true, nil
+		}
+	}
+	return nil, false, errBlobBusy
+}
+
+// settleBlob: el owner publica sus variantes (ready) o libera el blob (failed)
+func (c *Consumer) settleBlob(ctx context.Context, doc *persistence.MediaDoc, p *processed) {
+	filter := bson.M{"_id": p.Blob.ID, "owner": doc.Key}
+
+	var err error
+	if len(p.Variants) == 0 {
+		_, err = c.service.Blobs.UpdateOne(ctx, filter, bson.M{
+			"$set": bson.M{"status": "failed", "updatedAt": time.Now()},
+		})
+	} else {
+		_, err = c.service.Blobs.UpdateOne(ctx, filter, persistence.BlobHoldersUpdate(doc.Key, true, bson.D{
+			{Key: "status", Value: "ready"},
+			{Key: "variants", Value: p.Variants},
+			{Key: "width", Value: p.Width},
+			{Key: "height", Value: p.Height},
+		}))
+	}
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{"key": doc.Key, "blobId": p.Blob.ID}).Error("blob settle failed")
+	}
+}
+
+// sharedUrls: urls del doc apuntando a las variantes del blob. Mismo profile => mismo
+// plan, así que cada variante planeada tiene su par con el mismo nombre de archivo.
+func sharedUrls(doc *persistence.MediaDoc, blob *persistence.MediaBlob) map[string]string {
+	byName := make(map[string]string, len(blob.Variants))
+	for _, v := range blob.Variants {
+		byName[path.Base(v.Key)] = v.Key
+	}
+
+	urls := make(map[string]string, len(doc.Urls))
+	for k, u := range doc.Urls {
+		urls[k] = u
+	}
+	for _, pv := range doc.Planned {
+		shared, ok := byName[path.Base(pv.Key)]
+		u, has := urls[pv.Kind]
+		if !ok || !has || !strings.HasSuffix(u, pv.Key) {
+			continue
+		}
+		urls[pv.Kind] = strings.TrimSuffix(u, pv.Key) + shared
+	}
+	return urls
+}
@@ -0,0 +1,353 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/h2non/bimg"
+	"github.com/sirupsen/logrus"
+	"moonmap.io/go-commons/persistence"
+)
+
+// salida máxima que se guarda de ffmpeg/ffprobe (el resto se descarta)
+const toolOutputMax = 64 * 1024
+
+type capBuffer struct {
+	bytes.Buffer
+}
+
+func (b *capBuffer) Write(p []byte) (int, error) {
+	if room := toolOutputMax - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// runTool ejecuta ffmpeg/ffprobe como subproceso: timeout propio, sin stdin, entorno
+// vacío, dentro del directorio temporal del trabajo y con la salida acotada.
+func (c *Consumer) runTool(ctx context.Context, dir, bin string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.service.Video.Timeout)
+	defer cancel()
+
+	var stdout, stderr capBuffer
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Dir = dir
+	cmd.Env = []string{}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = 5 * time.Second
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%s: timeout after %s", filepath.Base(bin), c.service.Video.Timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", filepath.Base(bin), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+type probeInfo struct {
+	Width    int
+	Height   int
+	Duration float64
+	HasAudio bool
+}
+
+func (c *Consumer) probe(ctx context.Context, dir, path string) (*probeInfo, error) {
+	out, err := c.runTool(ctx, dir, c.service.Video.FFprobe,
+		"-v", "error",
+		"-protocol_whitelist", "file",
+		"-show_entries", "stream=codec_type,width,height:format=duration",
+		"-of", "json",
+		path,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+			Width     int    `json:"width"`
+			Height    int    `json:"height"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &res); err != nil {
+		return nil, fmt.Errorf("ffprobe: %w", err)
+	}
+
+	info := &probeInfo{}
+	for _, st := range res.Streams {
+		switch st.CodecType {
+		case "video":
+			if info.Width == 0 {
+				info.Width, info.Height = st.Width, st.Height
+			}
+		case "audio":
+			info.HasAudio = true
+		}
+	}
+	if info.Width <= 0 || info.Height <= 0 {
+		return nil, fmt.Errorf("ffprobe: no video stream")
+	}
+	info.Duration, _ = strconv.ParseFloat(res.Format.Duration, 64)
+	return info, nil
+}
+
+// screenFrameWidth: los frames extra del screening se achican, alcanza para hashes y clasificador
+const screenFrameWidth = 1024
+
+// frame: un frame del clip como png (1s adentro si alcanza, para evitar fundidos a negro).
+// Sirve para el screening y para el poster.
+func (c *Consumer) frame(ctx context.Context, dir, in string, info *probeInfo) ([]byte, error) {
+	return c.frameAt(ctx, dir, in, posterAt(info.Duration), "frame.png", 0)
+}
+
+// frames: el del poster más ScreenFrames-1 repartidos en la parte que se publica (hasta
+// MaxSeconds). Es un muestreo: un clip con pocos frames problemáticos entre dos muestras
+// pasa el screening; para eso está la revisión por reportes.
+func (c *Consumer) frames(ctx context.Context, dir, in string, info *probeInfo) ([][]byte, error) {
+	first, err := c.frame(ctx, dir, in, info)
+	if err != nil {
+		return nil, err
+	}
+	out := [][]byte{first}
+	for i, at := range sampleTimes(info.Duration, c.service.Video.MaxSeconds, c.service.Video.ScreenFrames) {
+		f, err := c.frameAt(ctx, dir, in, at, fmt.Sprintf("screen-%d.png", i), screenFrameWidth)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+func posterAt(duration float64) float64 {
+	if duration >= 2 {
+		return 1
+	}
+	return 0
+}
+
+// sampleTimes: n-1 instantes parejos dentro de min(duration, maxSeconds), sin repetir el
+// del poster. Clips de menos de 2s solo usan el frame del poster.
+func sampleTimes(duration float64, maxSeconds, n int) []float64 {
+	span := min(duration, float64(maxSeconds))
+	if n <= 1 || span < 2 {
+		return nil
+	}
+	out := make([]float64, 0, n-1)
+	last := posterAt(duration)
+	for i := 1; i < n; i++ {
+		at := span * float64(i) / float64(n)
+		if at-last < 0.5 {
+			continue
+		}
+		out = append(out, at)
+		last = at
+	}
+	return out
+}
+
+// frameAt: un frame en at segundos; maxWidth > 0 lo achica (nunca lo agranda)
+func (c *Consumer) frameAt(ctx context.Context, dir, in string, at float64, name string, maxWidth int) ([]byte, error) {
+	out := filepath.Join(dir, name)
+	args := []string{
+		"-nostdin", "-hide_banner", "-loglevel", "error", "-y",
+		"-protocol_whitelist", "file",
+		"-ss", strconv.FormatFloat(at, 'f', 3, 64),
+		"-i", in,
+		"-frames:v", "1",
+	}
+	if maxWidth > 0 {
+		args = append(args, "-vf", fmt.Sprintf("scale='min(%d,iw)':-2", maxWidth))
+	}
+	args = append(args, "-map_metadata", "-1", out)
+	if _, err := c.runTool(ctx, dir, c.service.Video.FFmpeg, args...); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(out)
+}
+
+// poster: el frame a webp con bimg (mismo camino que las imágenes)
+func poster(frame []byte, pv persistence.PlannedVariantDB) ([]byte, error) {
+	return bimg.NewImage(frame).Process(bimg.Options{
+		Type:          bimg.WEBP,
+		Quality:       80,
+		StripMetadata: true,
+		Width:         pv.W,
+		Enlarge:       false,
+	})
+}
+
+// transcode a H.264 (yuv420p, faststart) con la altura del plan como tope,
+// duración cortada en MaxSeconds y bitrate limitado por -maxrate.
+func (c *Consumer) transcode(ctx context.Context, dir, in string, info *probeInfo, pv persistence.PlannedVariantDB, withAudio bool) (string, error) {
+	lim := c.service.Video
+	height := pv.H
+	if height <= 0 {
+		height = 480
+	}
+	rate, ok := lim.MaxRate[height]
+	if !ok {
+		rate = lim.MaxRate[480]
+	}
+
+	out := filepath.Join(dir, pv.Kind+".mp4")
+	args := []string{
+		"-nostdin", "-hide_banner", "-loglevel", "error", "-y",
+		"-protocol_whitelist", "file",
+		"-i", in,
+		"-t", strconv.Itoa(lim.MaxSeconds),
+		"-map", "0:v:0",
+		"-vf", fmt.Sprintf("scale=-2:'trunc(min(%d,ih)/2)*2',format=yuv420p", height),
+		"-fpsmax", "30",
+		"-c:v", "libx264", "-preset", "veryfast", "-profile:v", "main", "-crf", "23",
+		"-maxrate", rate, "-bufsize", rate,
+		"-threads", strconv.Itoa(lim.Threads),
+		"-map_metadata", "-1",
+		"-movflags", "+faststart",
+	}
+	if withAudio && info.HasAudio {
+		args = append(args, "-map", "0:a:0", "-c:a", "aac", "-b:a", "128k", "-ac", "2")
+	} else {
+		args = append(args, "-an")
+	}
+	args = append(args, out)
+
+	if _, err := c.runTool(ctx, dir, lim.FFmpeg, args...); err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// processMotion: gif/video -> poster + mp4 por cada variante planeada. El original va
+// directo de S3 a un archivo temporal, nunca entero en memoria.
+func (c *Consumer) processMotion(ctx context.Context, doc *persistence.MediaDoc) (*processed, error) {
+	dir, err := os.MkdirTemp("", "media-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	// sin extensión: ffmpeg detecta el formato por contenido, no por el nombre
+	in := filepath.Join(dir, "input")
+	f, err := os.OpenFile(in, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	_, chk, err := c.download(ctx, doc, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := c.probe(ctx, dir, in)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errRejected, err)
+	}
+	if int64(info.Width)*int64(info.Height) > c.service.MaxPixels {
+		return nil, fmt.Errorf("%w: frame too big %dx%d", errRejected, info.Width, info.Height)
+	}
+
+	frames, err := c.frames(ctx, dir, in, info)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errRejected, err)
+	}
+	frame := frames[0]
+	scr, err := c.screen(doc, frames...)
+	if err != nil {
+		return nil, err
+	}
+	if isQuarantined(scr) {
+		return &processed{Width: info.Width, Height: info.Height, Checksum: chk, Screening: scr}, nil
+	}
+
+	blob, owner, err := c.claimBlob(ctx, doc, chk)
+	if err != nil {
+		return nil, err
+	}
+	if !owner {
+		return &processed{Variants: blob.Variants, Width: blob.Width, Height: blob.Height, Checksum: chk, Blob: blob, Screening: scr}, nil
+	}
+	p := &processed{Width: info.Width, Height: info.Height, Checksum: chk, Blob: blob, Owner: true, Screening: scr}
+
+	// Classify no hace nada una vez que el asset quedó en quarantined
+	for _, f := range frames {
+		c.service.Screener.Classify(ctx, f, scr)
+	}
+	if isQuarantined(scr) {
+		return p, nil
+	}
+
+	out := make([]persistence.MediaVariant, 0, len(doc.Planned))
+	for _, pv := range doc.Planned {
+		log := logrus.WithFields(logrus.Fields{"key": pv.Key, "kind": pv.Kind})
+
+		switch {
+		case strings.HasPrefix(pv.Mime, "image/"):
+			img, err := poster(frame, pv)
+			if err != nil {
+				log.WithError(err).Warn("poster failed")
+				continue
+			}
+			if _, err := c.putStream(ctx, pv.Key, pv.Mime, bytes.NewReader(img)); err != nil {
+				log.WithError(err).Warn("put variant failed")
+				continue
+			}
+			vsz, _ := bimg.NewImage(img).Size()
+			out = append(out, persistence.MediaVariant{Key: pv.Key, W: vsz.Width, H: vsz.Height, Bytes: int64(len(img))})
+
+		case pv.Mime == "video/mp4":
+			path, err := c.transcode(ctx, dir, in, info, pv, doc.MediaType == "VIDEO")
+			if err != nil {
+				log.WithError(err).Warn("transcode failed")
+				continue
+			}
+			v, err := c.putFileVariant(ctx, dir, path, pv)
+			if err != nil {
+				log.WithError(err).Warn("put variant failed")
+				continue
+			}
+			out = append(out, *v)
+		}
+	}
+	p.Variants = out
+	return p, nil
+}
+
+func (c *Consumer) putFileVariant(ctx context.Context, dir, path string, pv persistence.PlannedVariantDB) (*persistence.MediaVariant, error) {
+	vi, err := c.probe(ctx, dir, path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	n, err := c.putStream(ctx, pv.Key, pv.Mime, f)
+	if err != nil {
+		return nil, err
+	}
+	return &persistence.MediaVariant{Key: pv.Key, W: vi.Width, H: vi.Height, Bytes: n}, nil
+}
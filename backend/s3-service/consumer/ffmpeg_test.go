@@ -0,0 +1,226 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"moonmap.io/go-commons/persistence"
+	"moonmap.io/s3-service/core"
+)
+
+// clips chicos generados con lavfi en el momento; sin ffmpeg en el PATH se saltean
+func fixtureConsumer(t *testing.T) *Consumer {
+	t.Helper()
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		t.Skip("ffmpeg not installed")
+	}
+	ffprobe, err := exec.LookPath("ffprobe")
+	if err != nil {
+		t.Skip("ffprobe not installed")
+	}
+	return &Consumer{service: &core.Service{Video: core.VideoLimits{
+		FFmpeg:       ffmpeg,
+		FFprobe:      ffprobe,
+		Timeout:      30 * time.Second,
+		MaxSeconds:   60,
+		Threads:      1,
+		MaxRate:      map[int]string{480: "500k", 720: "1000k"},
+		ScreenFrames: 4,
+	}}}
+}
+
+func fixtureClip(t *testing.T, c *Consumer, dir, name string, args ...string) string {
+	t.Helper()
+	out := filepath.Join(dir, name)
+	args = append([]string{"-nostdin", "-hide_banner", "-loglevel", "error", "-y"}, append(args, out)...)
+	if _, err := c.runTool(context.Background(), dir, c.service.Video.FFmpeg, args...); err != nil {
+		t.Fatalf("fixture %s: %v", name, err)
+	}
+	return out
+}
+
+// 3s 320x240 con audio
+func fixtureVideo(t *testing.T, c *Consumer, dir string) string {
+	return fixtureClip(t, c, dir, "clip.mp4",
+		"-f", "lavfi", "-i", "testsrc=size=320x240:rate=15:duration=3",
+		"-f", "lavfi", "-i", "sine=frequency=440:duration=3",
+		"-c:v", "libx264", "-pix_fmt", "yuv420p", "-c:a", "aac", "-shortest",
+	)
+}
+
+// 1s 64x48, sin audio
+func fixtureGif(t *testing.T, c *Consumer, dir string) string {
+	return fixtureClip(t, c, dir, "anim.gif",
+		"-f", "lavfi", "-i", "testsrc=size=64x48:rate=10:duration=1",
+	)
+}
+
+func TestProbe(t *testing.T) {
+	c := fixtureConsumer(t)
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	info, err := c.probe(ctx, dir, fixtureVideo(t, c, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Width != 320 || info.Height != 240 || !info.HasAudio || math.Abs(info.Duration-3) > 0.2 {
+		t.Fatalf("video probe = %+v", info)
+	}
+
+	info, err = c.probe(ctx, dir, fixtureGif(t, c, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Width != 64 || info.Height != 48 || info.HasAudio {
+		t.Fatalf("gif probe = %+v", info)
+	}
+}
+
+func TestProbeRejectsNonVideo(t *testing.T) {
+	c := fixtureConsumer(t)
+	dir := t.TempDir()
+	audio := fixtureClip(t, c, dir, "tone.m4a", "-f", "lavfi", "-i", "sine=duration=1", "-c:a", "aac")
+
+	if _, err := c.probe(context.Background(), dir, audio); err == nil {
+		t.Fatal("probe accepted a file without video stream")
+	}
+}
+
+func TestFrame(t *testing.T) {
+	c := fixtureConsumer(t)
+	dir := t.TempDir()
+	ctx := context.Background()
+	pngMagic := []byte("\x89PNG\r\n\x1a\n")
+
+	for _, in := range []string{fixtureVideo(t, c, dir), fixtureGif(t, c, dir)} {
+		info, err := c.probe(ctx, dir, in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// el gif dura menos de 2s: el frame sale del principio
+		frame, err := c.frame(ctx, dir, in, info)
+		if err != nil {
+			t.Fatalf("%s: %v", filepath.Base(in), err)
+		}
+		if !bytes.HasPrefix(frame, pngMagic) {
+			t.Fatalf("%s: frame is not a png", filepath.Base(in))
+		}
+	}
+}
+
+func TestFrames(t *testing.T) {
+	c := fixtureConsumer(t)
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	cases := []struct {
+		in   string
+		want int
+	}{
+		{fixtureVideo(t, c, dir), 3}, // poster (1s) + 1.5s + 2.25s
+		{fixtureGif(t, c, dir), 1},   // menos de 2s: solo el del poster
+	}
+	for _, tc := range cases {
+		info, err := c.probe(ctx, dir, tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames, err := c.frames(ctx, dir, tc.in, info)
+		if err != nil {
+			t.Fatalf("%s: %v", filepath.Base(tc.in), err)
+		}
+		if len(frames) != tc.want {
+			t.Fatalf("%s: %d frames, want %d", filepath.Base(tc.in), len(frames), tc.want)
+		}
+	}
+}
+
+func TestSampleTimes(t *testing.T) {
+	cases := []struct {
+		duration   float64
+		maxSeconds int
+		n          int
+		want       []float64
+	}{
+		{1.5, 60, 4, nil},
+		{10, 60, 1, nil},
+		{10, 60, 4, []float64{2.5, 5, 7.5}},
+		// solo la parte publicada (se corta en maxSeconds)
+		{600, 60, 4, []float64{15, 30, 45}},
+		// demasiado cerca del poster (1s): se saltea
+		{3, 60, 4, []float64{1.5, 2.25}},
+	}
+	for _, tc := range cases {
+		got := sampleTimes(tc.duration, tc.maxSeconds, tc.n)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("sampleTimes(%v, %d, %d) = %v, want %v", tc.duration, tc.maxSeconds, tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestTranscode(t *testing.T) {
+	c := fixtureConsumer(t)
+	c.service.Video.MaxSeconds = 1
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	in := fixtureVideo(t, c, dir)
+	info, err := c.probe(ctx, dir, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		pv        persistence.PlannedVariantDB
+		withAudio bool
+		w, h      int
+	}{
+		// la altura del plan es un tope: no se agranda
+		{"no-upscale", persistence.PlannedVariantDB{Kind: "v720", H: 720}, true, 320, 240},
+		// -2 en el ancho: queda par
+		{"downscale", persistence.PlannedVariantDB{Kind: "v120", H: 120}, false, 160, 120},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := c.transcode(ctx, dir, in, info, tc.pv, tc.withAudio)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.probe(ctx, dir, out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Width != tc.w || got.Height != tc.h {
+				t.Fatalf("size = %dx%d, want %dx%d", got.Width, got.Height, tc.w, tc.h)
+			}
+			if got.HasAudio != tc.withAudio {
+				t.Fatalf("audio = %v, want %v", got.HasAudio, tc.withAudio)
+			}
+			if got.Duration > float64(c.service.Video.MaxSeconds)+0.2 {
+				t.Fatalf("duration %.2fs over MaxSeconds", got.Duration)
+			}
+		})
+	}
+}
+
+func TestRunToolTimeout(t *testing.T) {
+	c := fixtureConsumer(t)
+	c.service.Video.Timeout = 200 * time.Millisecond
+	dir := t.TempDir()
+
+	// lavfi sin duración no termina nunca
+	_, err := c.runTool(context.Background(), dir, c.service.Video.FFmpeg,
+		"-nostdin", "-loglevel", "error", "-f", "lavfi", "-i", "testsrc", "-f", "null", "-")
+	if err == nil {
+		t.Fatal("runTool did not time out")
+	}
+}
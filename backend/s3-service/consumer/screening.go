@@ -0,0 +1,87 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"moonmap.io/go-commons/persistence"
+	"moonmap.io/s3-service/screening"
+)
+
+// screen: hashes + blocklist antes de tocar el blob. Si un moderador ya lo aprobó
+// (reproceso después de la revisión) no se vuelve a mirar. Con varios frames (gif/video)
+// alcanza un match; se guardan los hashes del frame que matcheó, o los del primero.
+func (c *Consumer) screen(doc *persistence.MediaDoc, imgs ...[]byte) (*persistence.MediaScreening, error) {
+	if doc.Screening.Approved() {
+		return doc.Screening, nil
+	}
+	var first *persistence.MediaScreening
+	for _, img := range imgs {
+		scr, err := c.service.Screener.CheckHashes(img)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errRejected, err)
+		}
+		if isQuarantined(scr) {
+			return scr, nil
+		}
+		if first == nil {
+			first = scr
+		}
+	}
+	return first, nil
+}
+
+func isQuarantined(scr *persistence.MediaScreening) bool {
+	return scr != nil && scr.Action == screening.ActionQuarantine && !scr.Approved()
+}
+
+// quarantine: no se publica ninguna variante, el original queda privado hasta que un
+// moderador lo revise (approve => se reprocesa, reject => se borra).
+func (c *Consumer) quarantine(ctx context.Context, doc *persistence.MediaDoc, p *processed) {
+	mainLog := logrus.WithFields(logrus.Fields{
+		"key":    doc.Key,
+		"reason": p.Screening.Reason,
+		"label":  p.Screening.Label,
+		"score":  p.Screening.Score,
+	})
+
+	_, err := c.service.S3c.PutObjectAcl(ctx, &s3.PutObjectAclInput{
+		Bucket: aws.String(c.service.S3Bucket),
+		Key:    aws.String(doc.Key),
+		ACL:    types.ObjectCannedACLPrivate,
+	})
+	if err != nil {
+		mainLog.WithError(err).Error("unable to make quarantined original private")
+	}
+
+	var updated persistence.MediaDoc
+	err = c.service.Coll.FindOneAndUpdate(ctx,
+		bson.M{"key": doc.Key},
+		bson.M{
+			"$set": bson.M{
+				"status":    "quarantined",
+				"screening": p.Screening,
+				"checksum":  p.Checksum,
+				"width":     p.Width,
+				"height":    p.Height,
+				"variants":  []persistence.MediaVariant{},
+			},
+			"$currentDate": bson.M{"updatedAt": true},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		mainLog.WithError(err).Error("quarantine update failed")
+		return
+	}
+
+	mainLog.Warn("media asset quarantined")
+	c.notify(&updated, "quarantined", true)
+	c.publishProcessed(&updated, "quarantined")
+}
@@ -8,34 +8,54 @@ import (
 	"moonmap.io/go-commons/constants"
 	"moonmap.io/go-commons/ownhttp"
 	"moonmap.io/go-commons/system"
+	"moonmap.io/s3-service/core"
 )
 
 func (c *Consumer) Start(sys *system.System) {
 	sys.Run(func(ctx context.Context) {
 		c.service.Config(ctx)
 
+		// dispatcher: cada job entra cuando su costo estimado cabe en el presupuesto
 		var wg sync.WaitGroup
-		for i := 0; i < c.service.Workers; i++ {
-			wg.Add(1)
-			go func(id int) {
-				defer wg.Done()
-				for media := range c.service.MediaChannel {
-					logrus.WithFields(logrus.Fields{
-						"worker":     id,
-						"key":        media.Key,
-						"etag":       media.ETag,
-						"uploaderId": media.UploaderID,
-					}).Info("processing...")
-					c.process(media)
+		dispatched := make(chan struct{})
+		go func() {
+			defer close(dispatched)
+			for job := range c.service.MediaChannel {
+				cost := c.service.JobCost(job.Media)
+				res, err := c.service.Budget.Reserve(ctx, cost)
+				if err == nil && ctx.Err() != nil {
+					res.Release()
+					err = ctx.Err()
 				}
-			}(i)
-		}
+				if err != nil {
+					// apagando: que otro pod lo tome
+					job.Stop()
+					_ = job.Msg.Nak()
+					continue
+				}
+
+				logrus.WithFields(logrus.Fields{
+					"key":        job.Media.Key,
+					"etag":       job.Media.ETag,
+					"uploaderId": job.Media.UploaderID,
+					"cost":       cost,
+				}).Info("processing...")
+
+				wg.Add(1)
+				go func(job core.MediaJob) {
+					defer wg.Done()
+					defer res.Release()
+					c.run(job, res)
+				}(job)
+			}
+		}()
 
 		go c.createTransformConsumer()
 		ownhttp.NewServer(ctx, constants.S3ConsumerServiceName, sys.Bind, c.consumerRoutes(), nil)
 
 		<-ctx.Done()
 		close(c.service.MediaChannel)
+		<-dispatched
 		wg.Wait()
 		return
 	})
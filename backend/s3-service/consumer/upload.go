@@ -0,0 +1,176 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sirupsen/logrus"
+	"moonmap.io/go-commons/helpers"
+	"moonmap.io/go-commons/persistence"
+)
+
+// errRejected: el archivo no cumple los límites; el original se borra del bucket
+var errRejected = errors.New("media rejected")
+
+// download copia el original a dst sin pasar de MaxBytesFor(mime) y devuelve bytes + sha256.
+// Si el objeto declara más bytes que el tope ni se empieza a leer.
+func (c *Consumer) download(ctx context.Context, doc *persistence.MediaDoc, dst io.Writer) (int64, string, error) {
+	limit := c.service.MaxBytesFor(doc.Mime)
+
+	obj, err := c.service.S3c.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.service.S3Bucket),
+		Key:    aws.String(doc.Key),
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	defer obj.Body.Close()
+
+	if n := aws.ToInt64(obj.ContentLength); n > limit {
+		return n, "", fmt.Errorf("%w: %d bytes (max %d)", errRejected, n, limit)
+	}
+	if buf, ok := dst.(*bytes.Buffer); ok {
+		buf.Grow(int(aws.ToInt64(obj.ContentLength)))
+	}
+
+	h := sha256.New()
+	head := &headWriter{}
+	n, err := io.Copy(io.MultiWriter(dst, h, head), io.LimitReader(obj.Body, limit+1))
+	if err != nil {
+		return n, "", err
+	}
+	if n > limit {
+		return n, "", fmt.Errorf("%w: more than %d bytes", errRejected, limit)
+	}
+	// el publisher ya lo miró, pero la policy deja volver a subir a la misma key
+	// mientras no vence: se chequea de nuevo lo que realmente se procesa
+	if mt := helpers.ClassifyMime(helpers.SniffMime(head.b)); mt != doc.MediaType {
+		return n, "", fmt.Errorf("%w: content is %s, expected %s", errRejected, mt, doc.MediaType)
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// headWriter guarda los primeros 512 bytes (para SniffMime)
+type headWriter struct{ b []byte }
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if rest := 512 - len(w.b); rest > 0 {
+		w.b = append(w.b, p[:min(rest, len(p))]...)
+	}
+	return len(p), nil
+}
+
+func (c *Consumer) acl() types.ObjectCannedACL {
+	if c.service.S3PublicAcl {
+		return types.ObjectCannedACLPublicRead
+	}
+	return types.ObjectCannedACLPrivate
+}
+
+const variantCacheControl = "public, max-age=31536000, immutable"
+
+func (c *Consumer) putVariant(ctx context.Context, key, mime string, body io.Reader) error {
+	_, err := c.service.S3c.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:       aws.String(c.service.S3Bucket),
+		Key:          aws.String(key),
+		Body:         body,
+		ACL:          c.acl(),
+		ContentType:  aws.String(mime),
+		CacheControl: aws.String(variantCacheControl),
+	})
+	return err
+}
+
+// putStream sube r en partes de PartSize reutilizando un solo buffer, así un mp4 de
+// 20MB no se carga entero en memoria. Si entra en una parte va directo con PutObject.
+func (c *Consumer) putStream(ctx context.Context, key, mime string, r io.Reader) (int64, error) {
+	part := make([]byte, c.service.PartSize)
+	n, err := io.ReadFull(r, part)
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		return int64(n), c.putVariant(ctx, key, mime, bytes.NewReader(part[:n]))
+	case err != nil:
+		return 0, err
+	}
+
+	up, err := c.service.S3c.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:       aws.String(c.service.S3Bucket),
+		Key:          aws.String(key),
+		ACL:          c.acl(),
+		ContentType:  aws.String(mime),
+		CacheControl: aws.String(variantCacheControl),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	abort := func(cause error) (int64, error) {
+		// sin abort las partes quedan ocupando espacio en el bucket
+		_, e := c.service.S3c.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(c.service.S3Bucket),
+			Key:      aws.String(key),
+			UploadId: up.UploadId,
+		})
+		if e != nil {
+			logrus.WithError(e).WithField("key", key).Warn("abort multipart failed")
+		}
+		return 0, cause
+	}
+
+	var (
+		parts []types.CompletedPart
+		total int64
+		last  bool
+	)
+	for num := int32(1); n > 0; num++ {
+		res, err := c.service.S3c.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(c.service.S3Bucket),
+			Key:           aws.String(key),
+			UploadId:      up.UploadId,
+			PartNumber:    aws.Int32(num),
+			Body:          bytes.NewReader(part[:n]),
+			ContentLength: aws.Int64(int64(n)),
+		})
+		if err != nil {
+			return abort(err)
+		}
+		parts = append(parts, types.CompletedPart{
+			ETag:          res.ETag,
+			PartNumber:    aws.Int32(num),
+			ChecksumCRC32: res.ChecksumCRC32,
+		})
+		total += int64(n)
+		if last {
+			break
+		}
+
+		n, err = io.ReadFull(r, part)
+		switch {
+		case err == io.EOF:
+			n = 0
+		case err == io.ErrUnexpectedEOF:
+			last = true
+		case err != nil:
+			return abort(err)
+		}
+	}
+
+	_, err = c.service.S3c.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(c.service.S3Bucket),
+		Key:             aws.String(key),
+		UploadId:        up.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return abort(err)
+	}
+	return total, nil
+}
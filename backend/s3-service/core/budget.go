@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+
+	"golang.org/x/sync/semaphore"
+	"moonmap.io/go-commons/helpers"
+)
+
+// MemBudget: la concurrencia del consumer la pone la memoria estimada de cada job
+// (bytes descargados + pixeles decodificados), no una cantidad fija de workers.
+type MemBudget struct {
+	Total int64
+	sem   *semaphore.Weighted
+}
+
+func NewMemBudget(total int64) *MemBudget {
+	return &MemBudget{Total: total, sem: semaphore.NewWeighted(total)}
+}
+
+type Reservation struct {
+	b *MemBudget
+	n int64
+}
+
+func (b *MemBudget) clamp(n int64) int64 {
+	return min(max(n, minJobCost), b.Total)
+}
+
+// Reserve bloquea hasta que haya n bytes libres (o se cancele ctx)
+func (b *MemBudget) Reserve(ctx context.Context, n int64) (*Reservation, error) {
+	n = b.clamp(n)
+	if err := b.sem.Acquire(ctx, n); err != nil {
+		return nil, err
+	}
+	return &Reservation{b: b, n: n}, nil
+}
+
+// Grow sube la reserva a total cuando se conoce el costo real (ej: pixeles del header).
+// Si no entra sin esperar, suelta lo que tiene y espera el total: nunca se espera
+// reteniendo memoria, así dos jobs creciendo a la vez no se bloquean entre sí.
+func (r *Reservation) Grow(ctx context.Context, total int64) error {
+	total = r.b.clamp(total)
+	if total <= r.n {
+		return nil
+	}
+	if r.b.sem.TryAcquire(total - r.n) {
+		r.n = total
+		return nil
+	}
+	r.b.sem.Release(r.n)
+	r.n = 0
+	if err := r.b.sem.Acquire(ctx, total); err != nil {
+		return err
+	}
+	r.n = total
+	return nil
+}
+
+func (r *Reservation) Release() {
+	if r.n > 0 {
+		r.b.sem.Release(r.n)
+		r.n = 0
+	}
+}
+
+const minJobCost = 4 << 20 // 4MB
+
+// JobCost: estimación previa a la descarga. Las imágenes reservan el archivo dos veces
+// (buffer + copia de libvips) y crecen con Grow al leer el header; gif/video van a
+// disco y el costo es el del proceso ffmpeg.
+func (s *Service) JobCost(m MediaState) int64 {
+	bytes := m.Bytes
+	if bytes <= 0 {
+		bytes = s.MaxBytesFor(m.Mime)
+	}
+	switch helpers.ClassifyMime(m.Mime) {
+	case "GIF", "VIDEO":
+		return s.VideoMemCost
+	default:
+		return 2 * bytes
+	}
+}
+
+// DecodeCost: RGBA en memoria para w*h
+func DecodeCost(w, h int) int64 {
+	return int64(w) * int64(h) * 4
+}
@@ -4,6 +4,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nats-io/nats.go/jetstream"
 	"moonmap.io/go-commons/persistence"
 )
 
@@ -26,6 +27,8 @@ type PresignReq struct {
 // projects/project123/avatar/project123/v1/original.png
 // users/user123/avatar/user123/v1/original.png
 // users/user123/banner/user123/v1/original.png
+// spheres/sphere123/post_image/media456/v1/original.jpg
+// spheres/sphere123/banner/media789/v1/original.png
 
 type PresignPendingEvent struct {
 	Stream  string     `json:"stream"`
@@ -37,6 +40,9 @@ type PresignPendingEvent struct {
 type PresignRes struct {
 	Key          string              `json:"key"`
 	UploadURL    string              `json:"uploadUrl"`
+	UploadMethod string              `json:"uploadMethod"` // POST multipart: uploadFields + "file" al final
+	UploadFields map[string]string   `json:"uploadFields"`
+	MaxBytes     int64               `json:"maxBytes"`
 	ExpiresAt    time.Time           `json:"expiresAt"`
 	MediaID      string              `json:"mediaId"`
 	Urls         map[string]string   `json:"urls"`
@@ -77,22 +83,35 @@ func (r PresignReq) ToInsertDoc(matrix VariantsMatrix, now time.Time) persistenc
 	}
 }
 
-func BuildPresignRes(m VariantsMatrix, uploadURL string, expiresAt time.Time) PresignRes {
+func BuildPresignRes(mediaID string, m VariantsMatrix, uploadURL string, expiresAt time.Time) PresignRes {
 	return PresignRes{
-		MediaID:   m.Key,
-		Key:       m.Key,
-		Urls:      m.Urls,
-		Status:    "pending",
-		UploadURL: uploadURL,
-		ExpiresAt: expiresAt,
+		MediaID:      mediaID,
+		Key:          m.Key,
+		Urls:         m.Urls,
+		Status:       "pending",
+		UploadURL:    uploadURL,
+		UploadMethod: "POST",
+		ExpiresAt:    expiresAt,
 	}
 }
 
+// trabajo de transformación: el worker hace ack sobre Msg. El in-progress arranca
+// cuando el mensaje se acepta (también cubre la espera en la cola y en el presupuesto);
+// Stop lo corta y se puede llamar más de una vez.
+type MediaJob struct {
+	Media MediaState
+	Msg   jetstream.Msg
+	Stop  func()
+}
+
 type MediaState struct {
+	MediaID      string    `json:"mediaId,omitempty"` // _id en media_assets
 	Key          string    `json:"key"`
+	Namespace    string    `json:"namespace,omitempty"`
+	ScopeID      string    `json:"scopeId,omitempty"`
 	Mime         string    `json:"mime"`
 	UploaderID   string    `json:"uploaderId"`
-	Status       string    `json:"status"` // pending|uploaded|processing|ready|failed
+	Status       string    `json:"status"` // pending|uploaded|processing|ready|failed|quarantined
 	ETag         string    `json:"etag,omitempty"`
 	Bytes        int64     `json:"bytes,omitempty"`
 	UpdatedAt    time.Time `json:"updatedAt"`
@@ -100,8 +119,10 @@ type MediaState struct {
 }
 
 func MediaStateFromDocument(doc *persistence.MediaDoc) MediaState {
-	return MediaState{
+	state := MediaState{
 		Key:          doc.Key,
+		Namespace:    doc.Namespace,
+		ScopeID:      doc.ScopeID,
 		Mime:         doc.Mime,
 		UploaderID:   doc.UploaderID,
 		Status:       doc.Status,
@@ -110,4 +131,8 @@ func MediaStateFromDocument(doc *persistence.MediaDoc) MediaState {
 		UpdatedAt:    doc.UpdatedAt,
 		TransitionOk: true,
 	}
+	if !doc.ID.IsZero() {
+		state.MediaID = doc.ID.Hex()
+	}
+	return state
 }
@@ -41,6 +41,9 @@ func CreateMongoIndexes() []mongo.IndexModel {
 				SetPartialFilterExpression(bson.M{"status": "ready"}),
 		},
 
+		// dedupe: quién apunta a un blob
+		{Keys: bson.D{{Key: "blobId", Value: 1}}, Options: options.Index().SetName("blobId").SetSparse(true)},
+
 		// 6) Por mediaType (solo ready) — útil para feeds/analíticas
 		{
 			Keys: bson.D{
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/retry"
@@ -15,8 +16,10 @@ import (
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"moonmap.io/go-commons/constants"
 	"moonmap.io/go-commons/helpers"
+	"moonmap.io/go-commons/ownhttp"
 	"moonmap.io/go-commons/persistence"
 	"moonmap.io/go-commons/system"
+	"moonmap.io/s3-service/screening"
 )
 
 type Service struct {
@@ -29,17 +32,27 @@ type Service struct {
 	S3PublicAcl bool
 
 	Coll      *mongo.Collection
+	Blobs     *mongo.Collection // media_blobs (dedupe por checksum)
 	S3c       *s3.Client
 	Presigner *s3.PresignClient
 
-	MediaChannel chan MediaState
-	Workers      int
+	MediaChannel chan MediaJob
+	// memoria que pueden ocupar los jobs en vuelo (ver MemBudget)
+	Budget       *MemBudget
+	VideoMemCost int64 // reserva por job de gif/video (ffmpeg)
+	PartSize     int64 // multipart upload de variantes
+	// cada cuánto el worker avisa in-progress a JetStream (AckWait es 1m)
+	AckProgressEvery time.Duration
 
 	MaxUploadBytes int64
+	MaxVideoBytes  int64 // gif/video
 	MaxPixels      int64
 	AllowedMimes   map[string]bool
+	Video          VideoLimits
+	Screener       *screening.Screener // solo consumer
 
 	EventStore *system.NatsEventStore
+	Limiter    *ownhttp.RateLimiter // solo publisher
 	Mode       string
 }
 
@@ -52,7 +65,6 @@ func New() *Service {
 
 	// system.LoadS3()
 
-	w, _ := strconv.Atoi(helpers.GetEnv("WORKERS", "2"))
 	s := &Service{
 		S3AccessKey:  helpers.GetEnvOrFail("S3_ACCESS_KEY"),
 		S3SecretKey:  helpers.GetEnvOrFail("S3_SECRET_KEY"),
@@ -60,23 +72,33 @@ func New() *Service {
 		S3Bucket:     helpers.GetEnv("S3_BUCKET", "moonmap"),
 		S3Region:     helpers.GetEnv("S3_REGION", "eu-central"),
 		S3PublicAcl:  helpers.GetEnv("S3_PUBLIC_ACL", "true") == "true",
-		Workers:      w,
-		MediaChannel: make(chan MediaState, 1024),
+		MediaChannel: make(chan MediaJob, 1024),
 	}
 
 	s.Mode = mode
 
-	maxBytes, _ := strconv.ParseInt(helpers.GetEnv("MAX_UPLOAD_BYTES", "10485760"), 10, 64) // 10MB
-	maxPx, _ := strconv.ParseInt(helpers.GetEnv("MAX_PIXELS", "25000000"), 10, 64)          // 25MP
-	mimes := strings.Split(helpers.GetEnv("ALLOWED_MIME", "image/png,image/jpeg,image/webp"), ",")
+	maxBytes, _ := strconv.ParseInt(helpers.GetEnv("MAX_UPLOAD_BYTES", "10485760"), 10, 64)       // 10MB
+	maxVideo, _ := strconv.ParseInt(helpers.GetEnv("MAX_VIDEO_UPLOAD_BYTES", "52428800"), 10, 64) // 50MB
+	maxPx, _ := strconv.ParseInt(helpers.GetEnv("MAX_PIXELS", "25000000"), 10, 64)                // 25MP
+	mimes := strings.Split(helpers.GetEnv("ALLOWED_MIME", "image/png,image/jpeg,image/webp,image/gif,video/mp4,video/webm,video/quicktime"), ",")
 	allow := make(map[string]bool, len(mimes))
 	for _, m := range mimes {
 		allow[strings.ToLower(strings.TrimSpace(m))] = true
 	}
 
 	s.MaxUploadBytes = maxBytes
+	s.MaxVideoBytes = maxVideo
 	s.MaxPixels = maxPx
 	s.AllowedMimes = allow
+	s.AckProgressEvery = helpers.GetEnvDur("ACK_PROGRESS_EVERY", 20*time.Second)
+	s.Video = LoadVideoLimits()
+
+	budget, _ := strconv.ParseInt(helpers.GetEnv("MEDIA_MEM_BUDGET", "536870912"), 10, 64)  // 512MB
+	videoCost, _ := strconv.ParseInt(helpers.GetEnv("VIDEO_MEM_COST", "268435456"), 10, 64) // 256MB
+	part, _ := strconv.ParseInt(helpers.GetEnv("S3_PART_SIZE", "8388608"), 10, 64)          // 8MB
+	s.Budget = NewMemBudget(budget)
+	s.VideoMemCost = videoCost
+	s.PartSize = max(part, 5<<20) // S3 no acepta partes < 5MB (salvo la última)
 
 	return s
 }
@@ -90,10 +112,14 @@ func (s *Service) Config(ctx context.Context) {
 	if err != nil {
 		logrus.Fatal(err)
 	}
+	s.Blobs = persistence.MustGetCollection(constants.MediaBlobsCollectionName)
 
 	if s.IsConsumer() {
 		bimg.VipsCacheSetMaxMem(32 * 1024 * 1024)
 		bimg.VipsCacheSetMax(100)
+
+		s.Screener = screening.NewFromEnv(persistence.MustGetCollection(constants.MediaHashBlocklistCollectionName))
+		s.Screener.Start(ctx)
 	}
 
 	cfg, err := config.LoadDefaultConfig(ctx,
@@ -118,10 +144,23 @@ func (s *Service) Config(ctx context.Context) {
 	s.Presigner = s3.NewPresignClient(s.S3c)
 
 	s.EventStore = system.NewEventStore("s3-service")
+	if s.IsPublisher() {
+		s.Limiter = system.NewRateLimiter(ctx, s.EventStore)
+	}
 
 	logrus.Infof("s3 client configured successfully in mode %v", s.Mode)
 }
 
+// MaxBytesFor: gif/video tienen su propio tope
+func (s *Service) MaxBytesFor(mime string) int64 {
+	switch helpers.ClassifyMime(mime) {
+	case "GIF", "VIDEO":
+		return s.MaxVideoBytes
+	default:
+		return s.MaxUploadBytes
+	}
+}
+
 func (s *Service) IsConsumer() bool {
 	return s.Mode == "consumer"
 }
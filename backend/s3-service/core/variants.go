@@ -97,7 +97,7 @@ func (r *PresignReq) planGifVariants(m *VariantsMatrix, clientBase string) {
 
 	m.Plan = append(m.Plan,
 		VariantPlan{Kind: "poster", Width: 512, Height: 0, Quality: 80, Ext: "webp", Mime: "image/webp", Key: poster},
-		VariantPlan{Kind: "mp4_480", Width: 0, Height: 0, Quality: 0, Ext: "mp4", Mime: "video/mp4", Key: mp4480},
+		VariantPlan{Kind: "mp4_480", Width: 0, Height: 480, Quality: 0, Ext: "mp4", Mime: "video/mp4", Key: mp4480},
 	)
 }
 
@@ -112,8 +112,8 @@ func (r *PresignReq) planVideoVariants(m *VariantsMatrix, clientBase string) {
 
 	m.Plan = append(m.Plan,
 		VariantPlan{Kind: "poster", Width: 720, Height: 0, Quality: 80, Ext: "webp", Mime: "image/webp", Key: poster},
-		VariantPlan{Kind: "mp4_480", Width: 0, Height: 0, Quality: 0, Ext: "mp4", Mime: "video/mp4", Key: mp4480},
-		VariantPlan{Kind: "mp4_720", Width: 0, Height: 0, Quality: 0, Ext: "mp4", Mime: "video/mp4", Key: mp4720},
+		VariantPlan{Kind: "mp4_480", Width: 0, Height: 480, Quality: 0, Ext: "mp4", Mime: "video/mp4", Key: mp4480},
+		VariantPlan{Kind: "mp4_720", Width: 0, Height: 720, Quality: 0, Ext: "mp4", Mime: "video/mp4", Key: mp4720},
 	)
 }
 
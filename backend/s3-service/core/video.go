@@ -0,0 +1,35 @@
+package core
+
+import (
+	"time"
+
+	"moonmap.io/go-commons/helpers"
+)
+
+// límites del pipeline de gif/video (ffmpeg como subproceso)
+type VideoLimits struct {
+	FFmpeg     string        // FFMPEG_BIN
+	FFprobe    string        // FFPROBE_BIN
+	Timeout    time.Duration // por invocación de ffmpeg/ffprobe
+	MaxSeconds int           // la salida se corta ahí
+	Threads    int
+	// tope de bitrate por altura (-maxrate y -bufsize)
+	MaxRate map[int]string
+	// frames que pasan por el screening (el primero es el del poster)
+	ScreenFrames int
+}
+
+func LoadVideoLimits() VideoLimits {
+	return VideoLimits{
+		FFmpeg:     helpers.GetEnv("FFMPEG_BIN", "ffmpeg"),
+		FFprobe:    helpers.GetEnv("FFPROBE_BIN", "ffprobe"),
+		Timeout:    helpers.GetEnvDur("FFMPEG_TIMEOUT", 90*time.Second),
+		MaxSeconds: helpers.GetEnvInt("MAX_VIDEO_SECONDS", 60),
+		Threads:    helpers.GetEnvInt("FFMPEG_THREADS", 2),
+		MaxRate: map[int]string{
+			480: helpers.GetEnv("VIDEO_MAXRATE_480", "1000k"),
+			720: helpers.GetEnv("VIDEO_MAXRATE_720", "2500k"),
+		},
+		ScreenFrames: helpers.GetEnvInt("SCREEN_FRAMES", 4),
+	}
+}
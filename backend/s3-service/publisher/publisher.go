@@ -3,6 +3,7 @@ package publisher
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"strings"
 	"time"
 
@@ -97,6 +98,18 @@ func (p *Publisher) tryConfirm(doc persistence.MediaDoc) error {
 		return constants.ErrNotReady
 	}
 
+	// el Content-Type del HEAD es el que declaró el cliente: vale lo que dicen los bytes
+	sniffed, err := p.sniffInternal(ctx, doc.Key)
+	if err != nil {
+		mainLog.WithError(err).Warn("confirm: sniff failed. Retry follows")
+		return constants.ErrNotReady
+	}
+	if sniffed == "" || !p.service.AllowedMimes[sniffed] || helpers.ClassifyMime(sniffed) != doc.MediaType {
+		mainLog.WithFields(logrus.Fields{"declared": mime, "sniffed": sniffed}).Warn("confirm: content does not match declared type")
+		return p.reject(&doc)
+	}
+	mime = sniffed
+
 	// Transición atómica pending→uploaded
 	req := CreateTransitionRequest(&doc, "uploaded", nil, &bytes, &mime, &etag)
 	res, err := p.service.Coll.UpdateOne(p.service.Ctx, req.Filter, req.Update)
@@ -173,6 +186,58 @@ func (p *Publisher) PublishNotify(doc *persistence.MediaDoc) {
 	mainLog.Info("confirm: published")
 }
 
+// sniffInternal lee solo los primeros bytes del objeto (Range) para detectar el tipo real
+func (p *Publisher) sniffInternal(ctx context.Context, key string) (string, error) {
+	obj, err := p.service.S3c.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(p.service.S3Bucket),
+		Key:    aws.String(key),
+		Range:  aws.String("bytes=0-511"),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer obj.Body.Close()
+
+	head, err := io.ReadAll(io.LimitReader(obj.Body, 512))
+	if err != nil {
+		return "", err
+	}
+	return helpers.SniffMime(head), nil
+}
+
+// reject: el archivo no es lo que se declaró en el presign. Queda failed (pending→failed),
+// se borra del bucket y el dueño del scope se entera por media.process.failed.
+func (p *Publisher) reject(doc *persistence.MediaDoc) error {
+	req := CreateTransitionRequest(doc, "failed", nil, nil, nil, nil)
+	res, err := p.service.Coll.UpdateOne(p.service.Ctx, req.Filter, req.Update)
+	if err != nil {
+		return err // NAK
+	}
+	if res.ModifiedCount == 0 {
+		return nil
+	}
+
+	_, err = p.service.S3c.DeleteObject(p.service.Ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(p.service.S3Bucket),
+		Key:    aws.String(doc.Key),
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("key", doc.Key).Error("reject: unable to delete object")
+	}
+
+	data := core.MediaStateFromDocument(doc)
+	data.Status = "failed"
+	subject := doc.CreateMediaSubject("process.failed")
+	msgId := doc.CreateMessageId() + ":failed"
+	if err := p.service.EventStore.PublishJSON(constants.StreamMedia, subject, msgId, data, nil); err != nil {
+		logrus.WithError(err).WithField("subject", subject).Error("failed: publishing. Verify connection to NATS server")
+	}
+	if err := p.service.EventStore.PublishJSON(constants.StreamNotify, doc.CreateNotifySubject(), doc.CreateMessageId(), data, nil); err != nil {
+		logrus.WithError(err).Error("failed: publishing. Verify connection to NATS server")
+	}
+	return nil
+}
+
 func (p *Publisher) headOkInternal(ctx context.Context, key string) (bool, string, int64, string) {
 	head, err := p.service.S3c.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(p.service.S3Bucket),
@@ -184,18 +249,18 @@ func (p *Publisher) headOkInternal(ctx context.Context, key string) (bool, strin
 		return false, "", 0, ""
 	}
 
-	length := aws.ToInt64(head.ContentLength)
-	if length <= 0 || length > p.service.MaxUploadBytes {
-		logrus.WithFields(logrus.Fields{"key": key, "len": length}).Warn("HEAD reject: invalid length")
-		return false, "", 0, ""
-	}
-
 	ct := helpers.NormCT(aws.ToString(head.ContentType))
 	if !p.service.AllowedMimes[ct] {
 		logrus.WithFields(logrus.Fields{"key": key, "ct": ct}).Warn("HEAD reject: unsupported mime")
 		return false, "", 0, ""
 	}
 
+	length := aws.ToInt64(head.ContentLength)
+	if length <= 0 || length > p.service.MaxBytesFor(ct) {
+		logrus.WithFields(logrus.Fields{"key": key, "len": length}).Warn("HEAD reject: invalid length")
+		return false, "", 0, ""
+	}
+
 	etag := strings.Trim(aws.ToString(head.ETag), "\"")
 	return true, ct, length, etag
 }
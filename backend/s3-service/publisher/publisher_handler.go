@@ -1,12 +1,14 @@
 package publisher
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
+	"path"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -29,7 +31,8 @@ func (p *Publisher) publisherRoutes() *http.ServeMux {
 }
 
 func (p *Publisher) presign(mux *http.ServeMux) {
-	mux.HandleFunc("/media/presign", func(w http.ResponseWriter, r *http.Request) {
+	// por usuario: cada presign crea un doc pending y una policy de upload
+	perUploader := p.service.Limiter.Limit("media.presign", ownhttp.Per(30, time.Minute, keyByUploader), func(w http.ResponseWriter, r *http.Request) {
 		ownhttp.LogRequest(r)
 
 		if ownhttp.IsOptionsMethod(r, w) {
@@ -46,6 +49,14 @@ func (p *Publisher) presign(mux *http.ServeMux) {
 			return
 		}
 
+		// el Content-Type queda fijo en la policy; el tipo real se verifica por magic bytes
+		// cuando llega el archivo (HEAD del publisher y download del consumer)
+		req.Mime = helpers.NormCT(req.Mime)
+		if !p.service.AllowedMimes[req.Mime] {
+			ownhttp.WriteJSONError(w, http.StatusBadRequest, "UNSUPPORTED_MIME", "mime not allowed")
+			return
+		}
+
 		// create document under project collection
 		if req.EntityID == "" || req.ScopeID == "" {
 			col := persistence.MustGetCollection(constants.ProjectsCollectionName)
@@ -70,13 +81,15 @@ func (p *Publisher) presign(mux *http.ServeMux) {
 		key := req.MakeKeyWithExt(ext)
 
 		in := &s3.PutObjectInput{
-			Bucket:      aws.String(p.service.S3Bucket),
-			Key:         aws.String(key),
-			ContentType: aws.String(req.Mime),
+			Bucket: aws.String(p.service.S3Bucket),
+			Key:    aws.String(key),
 		}
 
-		ps, err := p.service.Presigner.PresignPutObject(p.service.Ctx, in, func(po *s3.PresignOptions) {
+		// presigned POST (no PUT): la policy limita tamaño, Content-Type y key en S3 mismo
+		maxBytes := p.service.MaxBytesFor(req.Mime)
+		ps, err := p.service.Presigner.PresignPostObject(p.service.Ctx, in, func(po *s3.PresignPostOptions) {
 			po.Expires = 15 * time.Minute
+			po.Conditions = helpers.UploadPolicy(key, path.Dir(key)+"/", req.Mime, maxBytes)
 		})
 
 		if err != nil {
@@ -87,12 +100,12 @@ func (p *Publisher) presign(mux *http.ServeMux) {
 
 		// 2) reescribe host para el cliente y arma el matrix (key, urls, plan)
 		// tu lógica de rewrite → base "https://s3.moonmap.io/"
-		matrix, _, uploadURL := rewriteForClient(ps, req)
+		matrix, _, uploadURL := rewriteForClient(ps.URL, req)
 
 		// 3) upsert (solo en insert) del documento completo (incluye urls/planned/mediaType/nextCheckAt)
 		now := time.Now()
 		doc := req.ToInsertDoc(matrix, now)
-		_, err = p.service.Coll.UpdateOne(
+		up, err := p.service.Coll.UpdateOne(
 			p.service.Ctx,
 			bson.M{"key": doc.Key},
 			bson.M{"$setOnInsert": doc},
@@ -100,11 +113,29 @@ func (p *Publisher) presign(mux *http.ServeMux) {
 		)
 		if err != nil {
 			logrus.Errorln(err)
+			ownhttp.WriteJSONError(w, http.StatusInternalServerError, "SERVER_INTERNAL", "media doc")
+			return
+		}
+
+		// mediaId = _id del documento (los servicios dueños del scope lo referencian)
+		if oid, ok := up.UpsertedID.(bson.ObjectID); ok {
+			doc.ID = oid
+		} else {
+			var existing persistence.MediaDoc
+			if err := p.service.Coll.FindOne(p.service.Ctx, bson.M{"key": doc.Key}).Decode(&existing); err != nil {
+				logrus.Errorln(err)
+				ownhttp.WriteJSONError(w, http.StatusInternalServerError, "SERVER_INTERNAL", "media doc")
+				return
+			}
+			doc.ID = existing.ID
 		}
 
 		// 4) respuesta con event
 		msgID := doc.CreateMessageId()
-		res := core.BuildPresignRes(matrix, uploadURL, now.Add(15*time.Minute))
+		res := core.BuildPresignRes(doc.ID.Hex(), matrix, uploadURL, now.Add(15*time.Minute))
+		res.UploadFields = ps.Values
+		res.UploadFields["Content-Type"] = req.Mime
+		res.MaxBytes = maxBytes
 		data := core.MediaStateFromDocument(&doc)
 		data.Status = "pending"
 		data.Mime = req.Mime
@@ -130,6 +161,34 @@ func (p *Publisher) presign(mux *http.ServeMux) {
 		}
 
 	})
+	// el userId del body no está autenticado: el tope por IP (el servicio que llama)
+	// acota a quien lo rota
+	mux.HandleFunc("/media/presign", p.service.Limiter.Limit("media.presign.ip", ownhttp.Per(300, time.Minute, ownhttp.KeyByIP), perUploader))
+}
+
+// keyByUploader: el publisher no tiene auth (lo llaman otros servicios), el usuario viene
+// en el body; se lee una copia y se deja el body intacto para el handler. La clave lleva
+// la IP: un userId inventado no comparte bucket con el de otro cliente.
+func keyByUploader(r *http.Request) string {
+	if r.Body == nil {
+		return ownhttp.KeyByIP(r)
+	}
+	head, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+	if err != nil {
+		return ownhttp.KeyByIP(r)
+	}
+
+	var req struct {
+		UserID string `json:"userId"`
+	}
+	if json.Unmarshal(head, &req) != nil || req.UserID == "" {
+		return ownhttp.KeyByIP(r)
+	}
+	return ownhttp.KeyByIP(r) + "|user:" + req.UserID
 }
 
 func (p *Publisher) process(mux *http.ServeMux) {
@@ -167,9 +226,9 @@ func (p *Publisher) process(mux *http.ServeMux) {
 	})
 }
 
-func rewriteForClient(ps *v4.PresignedHTTPRequest, req core.PresignReq) (matrix core.VariantsMatrix, clientBase, uploadURL string) {
+func rewriteForClient(rawURL string, req core.PresignReq) (matrix core.VariantsMatrix, clientBase, uploadURL string) {
 	// 2) reescribe host para el cliente y arma el matrix (key, urls, plan)
-	clientBase, uploadURL = helpers.RewriteForClient(ps)
+	clientBase, uploadURL = helpers.RewriteURLForClient(rawURL)
 	matrix = req.CreateVariantsMatrix(clientBase) // usa el mismo key normalizado y genera urls/plan
 
 	return
@@ -0,0 +1,83 @@
+package screening
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"moonmap.io/go-commons/persistence"
+)
+
+type blockEntry struct {
+	id     string
+	reason string
+	d, p   uint64
+	hasD   bool
+	hasP   bool
+}
+
+// Blocklist: hashes perceptuales bloqueados (media_hash_blocklist) en memoria.
+// Se recarga cada tanto; los moderadores agregan entradas al rechazar un asset.
+type Blocklist struct {
+	Coll        *mongo.Collection
+	MaxDistance int // bits distintos tolerados
+
+	mu      sync.RWMutex
+	entries []blockEntry
+}
+
+func (b *Blocklist) Load(ctx context.Context) error {
+	cur, err := b.Coll.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	var docs []persistence.MediaHashBlock
+	if err := cur.All(ctx, &docs); err != nil {
+		return err
+	}
+
+	entries := make([]blockEntry, 0, len(docs))
+	for _, d := range docs {
+		e := blockEntry{id: d.ID.Hex(), reason: d.Reason}
+		e.d, e.hasD = ParseHash(d.DHash)
+		e.p, e.hasP = ParseHash(d.PHash)
+		if e.hasD || e.hasP {
+			entries = append(entries, e)
+		}
+	}
+
+	b.mu.Lock()
+	b.entries = entries
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *Blocklist) Watch(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := b.Load(ctx); err != nil {
+				logrus.WithError(err).Warn("screening: blocklist reload failed")
+			}
+		}
+	}
+}
+
+// Match: alcanza con que uno de los dos hashes esté cerca
+func (b *Blocklist) Match(h Hashes) (id string, reason string, ok bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, e := range b.entries {
+		if (e.hasD && Distance(e.d, h.D) <= b.MaxDistance) || (e.hasP && Distance(e.p, h.P) <= b.MaxDistance) {
+			return e.id, e.reason, true
+		}
+	}
+	return "", "", false
+}
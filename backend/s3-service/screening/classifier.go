@@ -0,0 +1,58 @@
+package screening
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Classifier: clasificador externo (NSFW, violencia, etc). Recibe la imagen (o un frame
+// del video) y devuelve score 0..1 por label.
+type Classifier interface {
+	Classify(ctx context.Context, img []byte) (map[string]float64, error)
+}
+
+// HTTPClassifier: POST del archivo crudo a URL, respuesta {"labels": {"nsfw": 0.97, ...}}
+type HTTPClassifier struct {
+	URL   string
+	Token string
+	HTTP  *http.Client
+}
+
+func NewHTTPClassifier(url, token string, timeout time.Duration) *HTTPClassifier {
+	return &HTTPClassifier{URL: url, Token: token, HTTP: &http.Client{Timeout: timeout}}
+}
+
+func (c *HTTPClassifier) Classify(ctx context.Context, img []byte) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(img))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", http.DetectContentType(img))
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if res.StatusCode >= 300 {
+		return nil, fmt.Errorf("classifier: %s: %s", res.Status, bytes.TrimSpace(body))
+	}
+
+	var out struct {
+		Labels map[string]float64 `json:"labels"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("classifier: %w", err)
+	}
+	return out.Labels, nil
+}
@@ -0,0 +1,176 @@
+package screening
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"image/png"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+
+	"github.com/h2non/bimg"
+)
+
+// hashes perceptuales de 64 bits calculados acá mismo (sin servicios externos):
+// dHash = gradiente horizontal en 9x8, pHash = signo de la DCT 8x8 de baja frecuencia en 32x32.
+// Dos imágenes "iguales" (re-encode, resize, leve recorte de calidad) quedan a pocos bits.
+
+type Hashes struct {
+	D uint64
+	P uint64
+}
+
+func (h Hashes) DHex() string { return fmt.Sprintf("%016x", h.D) }
+func (h Hashes) PHex() string { return fmt.Sprintf("%016x", h.P) }
+
+func ParseHash(s string) (uint64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(s, 16, 64)
+	return v, err == nil
+}
+
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Compute decodifica una sola vez: un thumbnail gris de 32x32 del que salen los dos
+// hashes (el 9x8 del dHash se promedia desde ahí).
+func Compute(img []byte) (Hashes, error) {
+	px, err := gray(img, dctSize, dctSize)
+	if err != nil {
+		return Hashes{}, err
+	}
+	return Hashes{
+		D: dHash(shrink(px, dctSize, dctSize, 9, 8)),
+		P: pHash(px),
+	}, nil
+}
+
+// gray reduce la imagen a w x h en escala de grises (libvips) y devuelve los pixeles
+func gray(img []byte, w, h int) ([]float64, error) {
+	small, err := bimg.NewImage(img).Process(bimg.Options{
+		Width:          w,
+		Height:         h,
+		Force:          true,
+		Type:           bimg.PNG,
+		Interpretation: bimg.InterpretationBW,
+		StripMetadata:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	m, err := png.Decode(bytes.NewReader(small))
+	if err != nil {
+		return nil, err
+	}
+	b := m.Bounds()
+	if b.Dx() != w || b.Dy() != h {
+		return nil, fmt.Errorf("unexpected thumbnail size %dx%d", b.Dx(), b.Dy())
+	}
+
+	px := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			g := color.GrayModel.Convert(m.At(b.Min.X+x, b.Min.Y+y)).(color.Gray)
+			px[y*w+x] = float64(g.Y)
+		}
+	}
+	return px, nil
+}
+
+// shrink promedia por área los pixeles de sw x sh a dw x dh (dw <= sw, dh <= sh)
+func shrink(px []float64, sw, sh, dw, dh int) []float64 {
+	out := make([]float64, dw*dh)
+	fx, fy := float64(sw)/float64(dw), float64(sh)/float64(dh)
+	for y := 0; y < dh; y++ {
+		y0, y1 := float64(y)*fy, float64(y+1)*fy
+		for x := 0; x < dw; x++ {
+			x0, x1 := float64(x)*fx, float64(x+1)*fx
+			var sum, area float64
+			for sy := int(y0); sy < sh && float64(sy) < y1; sy++ {
+				cy := math.Min(y1, float64(sy+1)) - math.Max(y0, float64(sy))
+				for sx := int(x0); sx < sw && float64(sx) < x1; sx++ {
+					c := cy * (math.Min(x1, float64(sx+1)) - math.Max(x0, float64(sx)))
+					sum += px[sy*sw+sx] * c
+					area += c
+				}
+			}
+			out[y*dw+x] = sum / area
+		}
+	}
+	return out
+}
+
+// dHash sobre 9x8 pixeles
+func dHash(px []float64) uint64 {
+	var h uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			h <<= 1
+			if px[y*9+x] < px[y*9+x+1] {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+const (
+	dctSize = 32
+	dctKeep = 8
+)
+
+// cosenos de la DCT-II para las 8 frecuencias más bajas
+var dctCos = func() [dctKeep][dctSize]float64 {
+	var t [dctKeep][dctSize]float64
+	for u := 0; u < dctKeep; u++ {
+		for x := 0; x < dctSize; x++ {
+			t[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * dctSize))
+		}
+	}
+	return t
+}()
+
+// pHash sobre dctSize x dctSize pixeles
+func pHash(px []float64) uint64 {
+
+	// DCT separable: primero filas, después columnas (solo las 8x8 que se usan)
+	var rows [dctSize][dctKeep]float64
+	for y := 0; y < dctSize; y++ {
+		for u := 0; u < dctKeep; u++ {
+			var sum float64
+			for x := 0; x < dctSize; x++ {
+				sum += px[y*dctSize+x] * dctCos[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+	coef := make([]float64, 0, dctKeep*dctKeep)
+	for v := 0; v < dctKeep; v++ {
+		for u := 0; u < dctKeep; u++ {
+			var sum float64
+			for y := 0; y < dctSize; y++ {
+				sum += rows[y][u] * dctCos[v][y]
+			}
+			coef = append(coef, sum)
+		}
+	}
+
+	// mediana sin el término DC (brillo medio)
+	sorted := append([]float64(nil), coef[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var h uint64
+	for _, c := range coef {
+		h <<= 1
+		if c > median {
+			h |= 1
+		}
+	}
+	return h
+}
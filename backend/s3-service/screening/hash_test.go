@@ -0,0 +1,73 @@
+package screening
+
+import (
+	"math"
+	"testing"
+)
+
+func TestShrinkAveragesArea(t *testing.T) {
+	// 4x2 -> 2x1: cada pixel de salida es el promedio de un bloque 2x2
+	px := []float64{
+		0, 2, 10, 10,
+		4, 6, 20, 40,
+	}
+	got := shrink(px, 4, 2, 2, 1)
+	if got[0] != 3 || got[1] != 20 {
+		t.Fatalf("shrink = %v, want [3 20]", got)
+	}
+
+	// escala no entera: un plano sigue plano
+	flat := make([]float64, dctSize*dctSize)
+	for i := range flat {
+		flat[i] = 128
+	}
+	for _, v := range shrink(flat, dctSize, dctSize, 9, 8) {
+		if math.Abs(v-128) > 1e-9 {
+			t.Fatalf("flat image shrunk to %v", v)
+		}
+	}
+}
+
+func gradient(dx, dy float64) []float64 {
+	px := make([]float64, dctSize*dctSize)
+	for y := 0; y < dctSize; y++ {
+		for x := 0; x < dctSize; x++ {
+			px[y*dctSize+x] = 128 + dx*float64(x) + dy*float64(y)
+		}
+	}
+	return px
+}
+
+func TestDHashFromThumbnail(t *testing.T) {
+	// brillo creciente hacia la derecha: todos los bits en 1; al revés, todos en 0
+	if h := dHash(shrink(gradient(4, 0), dctSize, dctSize, 9, 8)); h != math.MaxUint64 {
+		t.Fatalf("dHash(increasing) = %016x", h)
+	}
+	if h := dHash(shrink(gradient(-4, 0), dctSize, dctSize, 9, 8)); h != 0 {
+		t.Fatalf("dHash(decreasing) = %016x", h)
+	}
+}
+
+func texture(sign float64) []float64 {
+	px := make([]float64, dctSize*dctSize)
+	for y := 0; y < dctSize; y++ {
+		for x := 0; x < dctSize; x++ {
+			px[y*dctSize+x] = 128 + sign*(40*math.Sin(float64(x)*0.3)*math.Cos(float64(y)*0.2)+float64(x+2*y))
+		}
+	}
+	return px
+}
+
+func TestPHashStableUnderBrightness(t *testing.T) {
+	a := texture(1)
+	b := make([]float64, len(a))
+	for i, v := range a {
+		b[i] = v*0.9 + 10
+	}
+	if d := Distance(pHash(a), pHash(b)); d > 2 {
+		t.Fatalf("pHash distance after brightness change = %d", d)
+	}
+	if d := Distance(pHash(a), pHash(texture(-1))); d < 16 {
+		t.Fatalf("pHash distance between inverted images = %d", d)
+	}
+}
@@ -0,0 +1,114 @@
+package screening
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"moonmap.io/go-commons/helpers"
+	"moonmap.io/go-commons/persistence"
+)
+
+const (
+	ActionAllow      = "allow"
+	ActionQuarantine = "quarantine"
+)
+
+// Screener corre antes de generar variantes: primero la blocklist de hashes (local,
+// barata, también para duplicados) y después el clasificador (solo para el que procesa).
+type Screener struct {
+	Blocklist  *Blocklist
+	Classifier Classifier         // nil = sin clasificador externo
+	Thresholds map[string]float64 // label -> score desde el que va a quarantined
+}
+
+// NewFromEnv: SCREEN_CLASSIFIER_URL vacío deja solo la blocklist.
+// SCREEN_THRESHOLDS="nsfw=0.85,violence=0.9"
+func NewFromEnv(blocklist *mongo.Collection) *Screener {
+	s := &Screener{
+		Blocklist: &Blocklist{
+			Coll:        blocklist,
+			MaxDistance: helpers.GetEnvInt("SCREEN_HASH_DISTANCE", 6),
+		},
+		Thresholds: parseThresholds(helpers.GetEnv("SCREEN_THRESHOLDS", "nsfw=0.85")),
+	}
+	if url := helpers.GetEnv("SCREEN_CLASSIFIER_URL", ""); url != "" {
+		s.Classifier = NewHTTPClassifier(url,
+			helpers.GetEnv("SCREEN_CLASSIFIER_TOKEN", ""),
+			helpers.GetEnvDur("SCREEN_CLASSIFIER_TIMEOUT", 10*time.Second))
+	}
+	return s
+}
+
+func (s *Screener) Start(ctx context.Context) {
+	if err := s.Blocklist.Load(ctx); err != nil {
+		logrus.WithError(err).Error("screening: blocklist load failed")
+	}
+	go s.Blocklist.Watch(ctx, helpers.GetEnvDur("SCREEN_BLOCKLIST_RELOAD", time.Minute))
+}
+
+// CheckHashes: hashes + blocklist. Siempre devuelve el resultado con los hashes
+// (se guardan en el doc para poder bloquearlo después).
+func (s *Screener) CheckHashes(img []byte) (*persistence.MediaScreening, error) {
+	h, err := Compute(img)
+	if err != nil {
+		return nil, err
+	}
+	res := &persistence.MediaScreening{Action: ActionAllow, DHash: h.DHex(), PHash: h.PHex()}
+	if id, reason, ok := s.Blocklist.Match(h); ok {
+		res.Action = ActionQuarantine
+		res.Reason = "blocklist"
+		res.Label = reason
+		logrus.WithField("blockId", id).Warn("screening: blocklist match")
+	}
+	return res, nil
+}
+
+// Classify completa res con el clasificador externo. Si el clasificador falla el asset
+// queda en quarantined: mejor revisarlo a mano que publicarlo sin mirar. Se puede llamar
+// una vez por frame: los labels se quedan con el score más alto.
+func (s *Screener) Classify(ctx context.Context, img []byte, res *persistence.MediaScreening) {
+	if s.Classifier == nil || res.Action != ActionAllow || res.Approved() {
+		return
+	}
+	labels, err := s.Classifier.Classify(ctx, img)
+	if err != nil {
+		logrus.WithError(err).Warn("screening: classifier failed")
+		res.Action = ActionQuarantine
+		res.Reason = "classifier_error"
+		return
+	}
+	if res.Labels == nil {
+		res.Labels = make(map[string]float64, len(labels))
+	}
+	for label, score := range labels {
+		res.Labels[label] = max(res.Labels[label], score)
+	}
+	for label, limit := range s.Thresholds {
+		if score, ok := labels[label]; ok && score >= limit && score > res.Score {
+			res.Action = ActionQuarantine
+			res.Reason = "classifier"
+			res.Label = label
+			res.Score = score
+		}
+	}
+}
+
+func parseThresholds(raw string) map[string]float64 {
+	out := map[string]float64{}
+	for _, part := range strings.Split(raw, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			continue
+		}
+		out[strings.ToLower(strings.TrimSpace(k))] = f
+	}
+	return out
+}
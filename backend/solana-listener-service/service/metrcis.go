@@ -26,10 +26,10 @@ func (s *Service) StartMetricsLogger() {
 				logCount := atomic.SwapUint64(&s.mintEvents, 0)
 
 				// estado actual de las colas
-				progQueueLen := len(s.programSocket.Messages)
-				progCapacity := cap(s.programSocket.Messages)
-				logQueueLen := len(s.logSocket.Messages)
-				logCapacity := cap(s.logSocket.Messages)
+				progQueueLen := len(s.programMessages)
+				progCapacity := cap(s.programMessages)
+				logQueueLen := len(s.logMessages)
+				logCapacity := cap(s.logMessages)
 
 				logrus.Infof(
 					"Processed %d account events | %d log events in last 10s | programQueue=%d/%d | logQueue=%d/%d",
@@ -2,7 +2,6 @@ package service
 
 import (
 	"context"
-	"math/rand"
 	"os"
 	"strconv"
 	"sync"
@@ -41,14 +40,14 @@ type Service struct {
 
 	seen *expirable.LRU[string, struct{}]
 
-	logIDBase     int
-	programIDBase int
-
 	wsUrl         string
 	rpcUrl        string
 	logSocket     *ownhttp.ManagedWS
 	programSocket *ownhttp.ManagedWS
 
+	logMessages     chan []byte
+	programMessages chan []byte
+
 	outFile *os.File
 
 	EventStore  *system.NatsEventStore
@@ -60,12 +59,10 @@ func New() *Service {
 	rpcUrl := helpers.GetEnvOrFail("HELIUS_RPC_URL")
 
 	s := &Service{
-		wsUrl:         wsUrl,
-		rpcUrl:        rpcUrl,
-		status:        "started",
-		outFile:       nil,
-		logIDBase:     rand.Intn(90000) + 10000,
-		programIDBase: rand.Intn(90000) + 10000,
+		wsUrl:   wsUrl,
+		rpcUrl:  rpcUrl,
+		status:  "started",
+		outFile: nil,
 	}
 
 	if s.startedAt.IsZero() {
@@ -157,8 +154,8 @@ func (s *Service) ReconnectHandler(nc *nats.Conn) {
 				}
 
 				clear := s.noPendingBacklogs() &&
-					len(s.logSocket.Messages) == 0 &&
-					len(s.programSocket.Messages) == 0
+					len(s.logMessages) == 0 &&
+					len(s.programMessages) == 0
 
 				if clear {
 					if sinceClear.IsZero() {
@@ -208,21 +205,19 @@ func (s *Service) Config(ctx context.Context, cancel context.CancelFunc) {
 	s.AlertClient = messages.NewAlertServiceClient(s.ctx, serviceName)
 
 	LogSubscribeChannelLength := helpers.GetEnvInt("LOG_SUBSCRIBE_CHANNEL_LENGTH", 10000)
+	s.logMessages = make(chan []byte, LogSubscribeChannelLength)
 	s.logSocket = &ownhttp.ManagedWS{
-		Name:      "LogSubscribe",
-		Url:       s.wsUrl,
-		OnStatus:  s.OnStatus,
-		OnConnect: s.SubscribeLogs,
-		Messages:  make(chan []byte, LogSubscribeChannelLength),
+		Name:     "LogSubscribe",
+		Url:      s.wsUrl,
+		OnStatus: s.OnStatus,
 	}
 
 	ProgramSubscribeChannelLength := helpers.GetEnvInt("PROGRAM_SUBSCRIBE_CHANNEL_LENGTH", 20000)
+	s.programMessages = make(chan []byte, ProgramSubscribeChannelLength)
 	s.programSocket = &ownhttp.ManagedWS{
-		Name:      "ProgramSubscribe",
-		Url:       s.wsUrl,
-		OnStatus:  s.OnStatus,
-		OnConnect: s.SubscribeProgram,
-		Messages:  make(chan []byte, ProgramSubscribeChannelLength),
+		Name:     "ProgramSubscribe",
+		Url:      s.wsUrl,
+		OnStatus: s.OnStatus,
 	}
 
 	var size100mb int64 = 100 * 1024 * 1024
@@ -239,8 +234,11 @@ func (s *Service) Start(sys *system.System) {
 		s.logSocket.Start(s.ctx)
 		s.programSocket.Start(s.ctx)
 
-		go s.wsProcessor(s.logSocket.Messages, s.handleLogsMessage)
-		go s.wsProcessor(s.programSocket.Messages, s.handleProgramMessage)
+		go s.wsProcessor(s.logMessages, s.handleLogsMessage)
+		go s.wsProcessor(s.programMessages, s.handleProgramMessage)
+
+		go s.SubscribeLogs()
+		go s.SubscribeProgram()
 
 		s.AlertClient.EnqueueInfo("Service running")
 		ownhttp.NewServer(ctx, constants.SolanaListenerServiceName, sys.Bind, s.routes(), nil)
@@ -295,8 +293,8 @@ func (s *Service) Stop() {
 		s.EventStore.Close()
 	}
 
-	close(s.logSocket.Messages)
-	close(s.programSocket.Messages)
+	close(s.logMessages)
+	close(s.programMessages)
 
 	prog := atomic.LoadUint64(&s.programEventsSeen)
 	logs := atomic.LoadUint64(&s.logEventsSeen)
@@ -6,11 +6,11 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 	"moonmap.io/go-commons/constants"
 	"moonmap.io/go-commons/helpers"
 	"moonmap.io/go-commons/messages"
+	"moonmap.io/go-commons/ownhttp"
 	"moonmap.io/go-commons/persistence"
 	"moonmap.io/go-commons/solana"
 )
@@ -28,32 +28,44 @@ func (s *Service) OnStatus(name, status string) {
 	}
 }
 
-type JSONRPCReq struct {
-	JSONRPC string      `json:"jsonrpc"`
-	ID      int         `json:"id"`
-	Method  string      `json:"method"`
-	Params  interface{} `json:"params"`
+// enqueue hands notifications from the socket reader to the processor channel.
+func (s *Service) enqueue(ch chan<- []byte) func(data []byte) {
+	return func(data []byte) {
+		select {
+		case ch <- data:
+		case <-s.ctx.Done():
+		}
+	}
 }
 
-func (s *Service) SubscribeLogs(conn *websocket.Conn) error {
+func (s *Service) SubscribeLogs() {
 	programs := []string{constants.TokenProgramID, constants.Token2022ProgramID, constants.MetadataProgramID}
 	commitment := helpers.GetEnv("COMMITMENT", "confirmed")
-	for i, prog := range programs {
-		id := s.logIDBase + i
-		params := []any{
-			map[string]any{"mentions": []string{prog}},
-			map[string]any{"commitment": commitment},
+	for _, prog := range programs {
+		sub := &ownhttp.RPCSubscription{
+			Name:        prog,
+			Method:      "logsSubscribe",
+			Unsubscribe: "logsUnsubscribe",
+			Params: []any{
+				map[string]any{"mentions": []string{prog}},
+				map[string]any{"commitment": commitment},
+			},
+			Handler: s.enqueue(s.logMessages),
 		}
 
-		req := JSONRPCReq{JSONRPC: "2.0", ID: id, Method: "logsSubscribe", Params: params}
-		s.logSocket.SendJSON(req)
-		msg := fmt.Sprintf("Subscribed to logsSubscribe program=%s with id %v commitment=%v", prog, id, commitment)
+		if err := s.logSocket.Subscribe(s.ctx, sub); err != nil {
+			msg := fmt.Sprintf("logsSubscribe program=%s rejected (retrying in background): %v", prog, err)
+			s.AlertClient.EnqueueError(msg)
+			logrus.WithError(err).Errorf("logsSubscribe program=%s failed", prog)
+			continue
+		}
+
+		msg := fmt.Sprintf("Registered logsSubscribe program=%s commitment=%v", prog, commitment)
 		s.AlertClient.EnqueueInfo(msg)
-		logrus.Infof("Subscribed logsSubscribe to program=%s id=%d commitment=%v", prog, id, commitment)
+		logrus.Infof("Registered logsSubscribe for program=%s commitment=%v", prog, commitment)
 	}
 
 	logrus.Info("Watching mint events")
-	return nil
 }
 
 func (s *Service) handleLogsMessage(data []byte) {
@@ -109,28 +121,37 @@ func (s *Service) handleLogsMessage(data []byte) {
 
 }
 
-func (s *Service) SubscribeProgram(conn *websocket.Conn) error {
+func (s *Service) SubscribeProgram() {
 	programs := []string{constants.TokenProgramID, constants.Token2022ProgramID}
 	commitment := helpers.GetEnv("COMMITMENT", "confirmed")
-	for i, prog := range programs {
-		id := s.programIDBase + i
-		params := []any{
-			prog,
-			map[string]interface{}{
-				"encoding":   "jsonParsed",
-				"commitment": commitment,
+	for _, prog := range programs {
+		sub := &ownhttp.RPCSubscription{
+			Name:        prog,
+			Method:      "programSubscribe",
+			Unsubscribe: "programUnsubscribe",
+			Params: []any{
+				prog,
+				map[string]interface{}{
+					"encoding":   "jsonParsed",
+					"commitment": commitment,
+				},
 			},
+			Handler: s.enqueue(s.programMessages),
+		}
+
+		if err := s.programSocket.Subscribe(s.ctx, sub); err != nil {
+			msg := fmt.Sprintf("programSubscribe program=%s rejected (retrying in background): %v", prog, err)
+			s.AlertClient.EnqueueError(msg)
+			logrus.WithError(err).Errorf("programSubscribe program=%s failed", prog)
+			continue
 		}
 
-		req := JSONRPCReq{JSONRPC: "2.0", ID: id, Method: "programSubscribe", Params: params}
-		s.programSocket.SendJSON(req)
-		msg := fmt.Sprintf("Subscribed to programSubscribe program=%s with id %v commitment=%v", prog, id, commitment)
+		msg := fmt.Sprintf("Registered programSubscribe program=%s commitment=%v", prog, commitment)
 		s.AlertClient.EnqueueInfo(msg)
-		logrus.Infof("Subscribed programSubscribe to program=%s id=%d commitment=%v", prog, id, commitment)
+		logrus.Infof("Registered programSubscribe for program=%s commitment=%v", prog, commitment)
 	}
 
 	logrus.Info("Watching program events")
-	return nil
 }
 
 func (s *Service) handleProgramMessage(data []byte) {
@@ -2,81 +2,199 @@ package core
 
 import (
 	"net/http"
-	"strings"
+	"time"
 
+	"moonmap.io/go-commons/constants"
 	"moonmap.io/go-commons/ownhttp"
 	"moonmap.io/spheres-service/routes"
 )
 
+// patrones de net/http (método + {wildcards}); los handlers leen r.PathValue
 func (s *Service) routes() *http.ServeMux {
 	mux := ownhttp.Routes()
 
+	// dependencias compartidas por contents y moderación
+	moderation := &routes.Moderation{
+		Spheres:    s.spheresColl,
+		Contents:   s.sphereContentsColl,
+		Reports:    s.reportsColl,
+		Sanctions:  s.sanctionsColl,
+		Audit:      s.auditColl,
+		EventStore: s.EventStore,
+	}
+
+	reactions := &routes.Reactions{
+		Spheres:    s.spheresColl,
+		Contents:   s.sphereContentsColl,
+		Reactions:  s.reactionsColl,
+		Sanctions:  s.sanctionsColl,
+		EventStore: s.EventStore,
+	}
+
+	spheres := &routes.Spheres{
+		Spheres:    s.spheresColl,
+		Members:    s.membersColl,
+		Assets:     s.assetsColl,
+		Mints:      s.mintsColl,
+		Projects:   s.projectsColl,
+		Waves:      s.wavesColl,
+		Sanctions:  s.sanctionsColl,
+		Audit:      s.auditColl,
+		EventStore: s.EventStore,
+	}
+
+	polls := &routes.Polls{
+		Contents:   s.sphereContentsColl,
+		Votes:      s.pollVotesColl,
+		Sanctions:  s.sanctionsColl,
+		EventStore: s.EventStore,
+	}
+
 	// spheres
-	mux.HandleFunc("/spheres", ownhttp.WithLogging("CreateSphere", routes.CreateSphere(s.spheresColl)))
+	mux.HandleFunc("POST /spheres", ownhttp.WithLogging("CreateSphere", s.auth(s.limit("spheres.create", 5, time.Hour, routes.CreateSphere(spheres)))))
+
+	// discovery (?sort=activity|members|new)
+	mux.HandleFunc("GET /spheres", ownhttp.WithLogging("ListSpheres", routes.ListSpheres(spheres)))
+
+	mux.HandleFunc("GET /spheres/{sphereId}", ownhttp.WithLogging("GetSphere", routes.GetSphere(spheres)))
+
+	mux.HandleFunc("PATCH /spheres/{sphereId}", ownhttp.WithLogging("UpdateSphere", s.auth(routes.UpdateSphere(spheres))))
+
+	// membership
+	mux.HandleFunc("POST /spheres/{sphereId}/join", ownhttp.WithLogging("JoinSphere",
+		s.auth(s.limit("spheres.join", 30, time.Minute, routes.JoinSphere(spheres)))))
+
+	mux.HandleFunc("POST /spheres/{sphereId}/leave", ownhttp.WithLogging("LeaveSphere",
+		s.auth(s.limit("spheres.join", 30, time.Minute, routes.LeaveSphere(spheres)))))
+
+	mux.HandleFunc("GET /spheres/{sphereId}/members", ownhttp.WithLogging("GetSphereMembers", routes.GetSphereMembers(spheres)))
+
+	mux.HandleFunc("GET /spheres/{sphereId}/membership", ownhttp.WithLogging("GetSphereMembership", s.auth(routes.GetSphereMembership(spheres))))
+
+	// moderators (owner only)
+	moderators := ownhttp.WithLogging("SetSphereModerator", s.auth(routes.SetSphereModerator(spheres)))
+	mux.HandleFunc("PUT /spheres/{sphereId}/moderators/{userId}", moderators)
+	mux.HandleFunc("DELETE /spheres/{sphereId}/moderators/{userId}", moderators)
+
+	// media (pipeline de s3-service: presign -> PUT -> complete -> ready)
+	mux.HandleFunc("POST /spheres/{sphereId}/media/presign", ownhttp.WithLogging("PresignSphereMedia",
+		s.auth(s.limit("spheres.media.presign", 30, time.Minute, routes.PresignSphereMedia(s.Media)))))
+
+	// mark media as completed
+	mux.HandleFunc("POST /spheres/{sphereId}/media/complete", ownhttp.WithLogging("CompleteSphereMedia",
+		s.auth(routes.CompleteSphereMedia(s.Media))))
+
+	mux.HandleFunc("DELETE /spheres/{sphereId}/media/{mediaId}", ownhttp.WithLogging("DeleteSphereMedia",
+		s.auth(routes.DeleteSphereMedia(s.Media))))
+
+	// quarantined por el screening de s3-service: revisión de moderadores
+	mux.HandleFunc("GET /spheres/{sphereId}/media/quarantine", ownhttp.WithLogging("ListQuarantinedMedia",
+		s.auth(routes.ListQuarantinedMedia(s.Media))))
+
+	mux.HandleFunc("POST /spheres/{sphereId}/media/{mediaId}/review", ownhttp.WithLogging("ReviewSphereMedia",
+		s.auth(routes.ReviewSphereMedia(s.Media))))
 
 	// contents
-	mux.HandleFunc("/spheres/", func(w http.ResponseWriter, r *http.Request) {
-		path := strings.TrimPrefix(r.URL.Path, "/spheres/")
-
-		switch {
-
-		case strings.Contains(path, "/media/presign"):
-			ownhttp.WithLogging("PresignSphereMedia",
-				routes.PresignSphereMedia(s.mediaColl, s.S3Cfg, s.Presigner),
-			)(w, r)
-
-		// mark media as completed
-		case strings.Contains(path, "/media/complete") && r.Method == http.MethodPost:
-			ownhttp.WithLogging("CompleteSphereMedia",
-				routes.CompleteSphereMedia(s.mediaColl, s.S3Cfg, s.S3c),
-			)(w, r)
-
-		case strings.Contains(path, "/media/") && r.Method == http.MethodDelete:
-			ownhttp.WithLogging("DeleteSphereMedia",
-				routes.DeleteSphereMedia(s.mediaColl, s.S3Cfg, s.S3c),
-			)(w, r)
-
-		// create content
-		case strings.HasSuffix(path, "/contents") && r.Method == http.MethodPost:
-			ownhttp.WithLogging("CreateSphereContent",
-				routes.CreateSphereContent(s.sphereContentsColl, s.mediaColl, s.EventStore),
-			)(w, r)
-
-			// list content (paginable, accept parentId y after)
-		// list posts (paginable, with preview of replies )
-		case strings.HasSuffix(path, "/contents") && r.Method == http.MethodGet:
-			ownhttp.WithLogging("GetSpherePosts",
-				routes.GetSpherePosts(s.sphereContentsColl),
-			)(w, r)
-
-		// list replies of a post
-		case strings.Contains(path, "/contents/") && strings.HasSuffix(path, "/replies") && r.Method == http.MethodGet:
-			ownhttp.WithLogging("GetSphereReplies",
-				routes.GetSphereReplies(s.sphereContentsColl),
-			)(w, r)
-
-		// update content
-		case strings.Contains(path, "/contents/") && r.Method == http.MethodPatch:
-			ownhttp.WithLogging("UpdateSphereContent",
-				routes.UpdateSphereContent(s.sphereContentsColl, s.EventStore),
-			)(w, r)
-
-		// remove content
-		case strings.Contains(path, "/contents/") && r.Method == http.MethodDelete && !strings.HasSuffix(path, "/reactions"):
-			ownhttp.WithLogging("DeleteSphereContent",
-				routes.DeleteSphereContent(s.sphereContentsColl, s.EventStore),
-			)(w, r)
-
-		// reactions (add/remove)
-		case strings.Contains(path, "/contents/") && strings.HasSuffix(path, "/reactions"):
-			ownhttp.WithLogging("ReactSphereContent",
-				routes.ReactSphereContent(s.sphereContentsColl, s.EventStore),
-			)(w, r)
-
-		default:
-			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "route")
-		}
-	})
+	mux.HandleFunc("POST /spheres/{sphereId}/contents", ownhttp.WithLogging("CreateSphereContent",
+		s.auth(s.limit("spheres.contents.create", 10, time.Minute, routes.CreateSphereContent(s.Media, moderation, s.Filters)))))
+
+	// list posts (cursor, reply stats and preview of replies)
+	mux.HandleFunc("GET /spheres/{sphereId}/contents", ownhttp.WithLogging("GetSpherePosts",
+		routes.GetSpherePosts(s.sphereContentsColl, s.assetsColl)))
+
+	// list replies of a post
+	mux.HandleFunc("GET /spheres/{sphereId}/contents/{contentId}/replies", ownhttp.WithLogging("GetSphereReplies",
+		routes.GetSphereReplies(s.sphereContentsColl, s.assetsColl)))
+
+	// full-text search (typesense)
+	mux.HandleFunc("GET /spheres/{sphereId}/search", ownhttp.WithLogging("SearchSphereContents",
+		s.Limiter.Limit("spheres.search", ownhttp.Per(60, time.Minute, ownhttp.KeyByIP),
+			routes.SearchSphereContents(s.Search, constants.TypesenseSphereContentsCollection, s.sphereContentsColl, s.assetsColl))))
+
+	// edit history
+	mux.HandleFunc("GET /spheres/{sphereId}/contents/{contentId}/history", ownhttp.WithLogging("GetSphereContentHistory",
+		s.optionalAuth(routes.GetSphereContentHistory(s.sphereContentsColl, s.spheresColl, s.sphereContentEditsColl))))
+
+	mux.HandleFunc("PATCH /spheres/{sphereId}/contents/{contentId}", ownhttp.WithLogging("UpdateSphereContent",
+		s.auth(routes.UpdateSphereContent(s.Media, moderation, s.Filters, s.sphereContentEditsColl))))
+
+	mux.HandleFunc("DELETE /spheres/{sphereId}/contents/{contentId}", ownhttp.WithLogging("DeleteSphereContent",
+		s.auth(routes.DeleteSphereContent(s.sphereContentsColl, s.spheresColl, s.auditColl, s.EventStore))))
+
+	// reactions (add/remove)
+	react := ownhttp.WithLogging("ReactSphereContent", s.auth(s.limit("spheres.contents.react", 60, time.Minute, routes.ReactSphereContent(reactions))))
+	mux.HandleFunc("POST /spheres/{sphereId}/contents/{contentId}/reactions", react)
+	mux.HandleFunc("PATCH /spheres/{sphereId}/contents/{contentId}/reactions", react)
+	mux.HandleFunc("DELETE /spheres/{sphereId}/contents/{contentId}/reactions", react)
+
+	// who reacted (paginado, ?symbol= opcional)
+	mux.HandleFunc("GET /spheres/{sphereId}/contents/{contentId}/reactions", ownhttp.WithLogging("GetSphereContentReactions",
+		routes.GetSphereContentReactions(reactions)))
+
+	// emojis permitidos por sphere (moderadores)
+	mux.HandleFunc("GET /spheres/{sphereId}/reactions", ownhttp.WithLogging("GetSphereReactions",
+		routes.GetSphereReactions(s.spheresColl)))
+
+	mux.HandleFunc("PUT /spheres/{sphereId}/reactions", ownhttp.WithLogging("SetSphereReactions",
+		s.auth(routes.SetSphereReactions(s.spheresColl, s.auditColl))))
+
+	// polls (un voto por usuario)
+	mux.HandleFunc("POST /spheres/{sphereId}/contents/{contentId}/votes", ownhttp.WithLogging("VotePoll",
+		s.auth(s.limit("spheres.polls.vote", 30, time.Minute, routes.VotePoll(polls)))))
+
+	mux.HandleFunc("GET /spheres/{sphereId}/contents/{contentId}/votes/me", ownhttp.WithLogging("GetMyPollVote",
+		s.auth(routes.GetMyPollVote(polls))))
+
+	// pinned posts (moderadores)
+	mux.HandleFunc("GET /spheres/{sphereId}/pins", ownhttp.WithLogging("GetSpherePins",
+		routes.GetSpherePins(s.sphereContentsColl, s.assetsColl)))
+
+	pin := ownhttp.WithLogging("PinSphereContent", s.auth(routes.PinSphereContent(moderation)))
+	mux.HandleFunc("PUT /spheres/{sphereId}/pins/{contentId}", pin)
+	mux.HandleFunc("DELETE /spheres/{sphereId}/pins/{contentId}", pin)
+
+	mux.HandleFunc("POST /spheres/{sphereId}/contents/{contentId}/reports", ownhttp.WithLogging("ReportSphereContent",
+		s.auth(s.limit("spheres.contents.report", 10, time.Minute, routes.ReportSphereContent(moderation)))))
+
+	mux.HandleFunc("GET /spheres/{sphereId}/moderation/queue", ownhttp.WithLogging("GetModerationQueue",
+		s.auth(routes.GetModerationQueue(moderation))))
+
+	mux.HandleFunc("POST /spheres/{sphereId}/moderation/actions", ownhttp.WithLogging("ModerateSphere",
+		s.auth(routes.ModerateSphere(moderation))))
+
+	// notifications (inbox del usuario autenticado)
+	mux.HandleFunc("GET /notifications", ownhttp.WithLogging("GetNotifications",
+		s.auth(routes.GetNotifications(s.notificationsColl))))
+
+	mux.HandleFunc("GET /notifications/unread-count", ownhttp.WithLogging("GetUnreadNotificationCount",
+		s.auth(routes.GetUnreadNotificationCount(s.notificationsColl))))
+
+	mux.HandleFunc("POST /notifications/read", ownhttp.WithLogging("MarkNotificationsRead",
+		s.auth(routes.MarkNotificationsRead(s.notificationsColl))))
+
+	mux.HandleFunc("PATCH /notifications/{notificationId}", ownhttp.WithLogging("SetNotificationRead",
+		s.auth(routes.SetNotificationRead(s.notificationsColl))))
+
+	mux.HandleFunc("GET /notifications/preferences", ownhttp.WithLogging("GetNotificationPrefs",
+		s.auth(routes.GetNotificationPrefs(s.notificationPrefsColl))))
+
+	mux.HandleFunc("PUT /notifications/preferences", ownhttp.WithLogging("PutNotificationPrefs",
+		s.auth(routes.PutNotificationPrefs(s.notificationPrefsColl))))
 
 	return mux
 }
+
+// auth: mutaciones requieren un Principal (Authorization: Bearer <jwt>)
+func (s *Service) auth(h http.HandlerFunc) http.HandlerFunc {
+	return ownhttp.WithAuth(s.Verifier, h)
+}
+
+// optionalAuth: el handler decide qué ve un anónimo
+func (s *Service) optionalAuth(h http.HandlerFunc) http.HandlerFunc {
+	return ownhttp.WithOptionalAuth(s.Verifier, h)
+}
+
+// limit: token bucket por usuario autenticado (ver RATE_LIMIT_<NAME> para pisarlo)
+func (s *Service) limit(name string, n int, period time.Duration, h http.HandlerFunc) http.HandlerFunc {
+	return s.Limiter.Limit(name, ownhttp.Per(n, period, ownhttp.KeyByUser), h)
+}
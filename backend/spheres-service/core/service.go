@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/sirupsen/logrus"
@@ -9,17 +10,48 @@ import (
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 	"moonmap.io/go-commons/constants"
+	"moonmap.io/go-commons/helpers"
 	"moonmap.io/go-commons/ownhttp"
 	"moonmap.io/go-commons/persistence"
 	"moonmap.io/go-commons/system"
+	"moonmap.io/go-commons/typesense"
+	"moonmap.io/spheres-service/filter"
+	"moonmap.io/spheres-service/media"
+	"moonmap.io/spheres-service/notifications"
+	"moonmap.io/spheres-service/routes"
+	"moonmap.io/spheres-service/search"
 )
 
 type Service struct {
 	ctx                context.Context
 	spheresColl        *mongo.Collection
-	mediaColl          *mongo.Collection
 	sphereContentsColl *mongo.Collection
 
+	sphereContentEditsColl *mongo.Collection
+	auditColl              *mongo.Collection
+	reportsColl            *mongo.Collection
+	sanctionsColl          *mongo.Collection
+	reactionsColl          *mongo.Collection
+	membersColl            *mongo.Collection
+	pollVotesColl          *mongo.Collection
+	notificationsColl      *mongo.Collection
+	notificationPrefsColl  *mongo.Collection
+
+	// de otros servicios (sin índices propios acá): solo lectura, salvo los de media
+	// (assets, blobs y blocklist) que escriben el borrado y la revisión de media
+	assetsColl   *mongo.Collection
+	blobsColl    *mongo.Collection
+	blockColl    *mongo.Collection
+	mintsColl    *mongo.Collection
+	projectsColl *mongo.Collection
+	wavesColl    *mongo.Collection
+
+	Verifier ownhttp.TokenVerifier
+	Filters  *filter.Pipeline
+	Limiter  *ownhttp.RateLimiter
+	Search   *typesense.Client
+	Media    *routes.Media
+
 	EventStore *system.NatsEventStore
 
 	S3Cfg     *system.S3Config
@@ -35,11 +67,43 @@ func NewService() *Service {
 func (s *Service) Config() {
 	s.spheresColl = persistence.MustGetCollection(constants.SpheresCollectionName)
 	s.sphereContentsColl = persistence.MustGetCollection(constants.SphereContentsCollectionName)
-	s.mediaColl = persistence.MustGetCollection(constants.SphereMediaCollectionName)
+	s.sphereContentEditsColl = persistence.MustGetCollection(constants.SphereContentEditsCollectionName)
+	s.auditColl = persistence.MustGetCollection(constants.SphereAuditCollectionName)
+	s.reportsColl = persistence.MustGetCollection(constants.SphereReportsCollectionName)
+	s.sanctionsColl = persistence.MustGetCollection(constants.SphereSanctionsCollectionName)
+	s.reactionsColl = persistence.MustGetCollection(constants.SphereReactionsCollectionName)
+	s.membersColl = persistence.MustGetCollection(constants.SphereMembersCollectionName)
+	s.pollVotesColl = persistence.MustGetCollection(constants.SpherePollVotesCollectionName)
+	s.assetsColl = persistence.MustGetCollection(constants.MediaAssetsCollectionName)
+	s.blobsColl = persistence.MustGetCollection(constants.MediaBlobsCollectionName)
+	s.blockColl = persistence.MustGetCollection(constants.MediaHashBlocklistCollectionName)
+	s.mintsColl = persistence.MustGetCollection(constants.MintsCollectionName)
+	s.projectsColl = persistence.MustGetCollection(constants.ProjectsCollectionName)
+	s.wavesColl = persistence.MustGetCollection("waves_sessions")
+	s.notificationsColl = persistence.MustGetCollection(constants.NotificationsCollectionName)
+	s.notificationPrefsColl = persistence.MustGetCollection(constants.NotificationPrefsCollectionName)
+
+	// membresías antes que el backfill (que inserta los owners)
+	_, err := s.membersColl.Indexes().CreateMany(s.ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "sphereId", Value: 1}, {Key: "userId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "sphereId", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "sphereId", Value: 1}, {Key: "role", Value: 1}, {Key: "_id", Value: -1}}},
+	})
+
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	// las spheres viejas no tienen mint (choca con el índice único)
+	routes.BackfillSpheres(s.ctx, s.spheresColl, s.membersColl)
 
 	// Indexes básicos
-	_, err := s.spheresColl.Indexes().CreateMany(s.ctx, []mongo.IndexModel{
+	_, err = s.spheresColl.Indexes().CreateMany(s.ctx, []mongo.IndexModel{
 		{Keys: bson.D{{Key: "mint", Value: 1}}, Options: options.Index().SetUnique(true)},
+		// mismos órdenes que ListSpheres (con _id de desempate)
+		{Keys: bson.D{{Key: "lastPostAt", Value: -1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "memberCount", Value: -1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: 1}}},
 	})
 
 	if err != nil {
@@ -49,15 +113,51 @@ func (s *Service) Config() {
 	_, err = s.sphereContentsColl.Indexes().CreateMany(s.ctx, []mongo.IndexModel{
 		{Keys: bson.D{{Key: "sphereId", Value: 1}, {Key: "createdAt", Value: -1}}},
 		{Keys: bson.D{{Key: "parentId", Value: 1}}},
+		{Keys: bson.D{{Key: "sphereId", Value: 1}, {Key: "parentId", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "sphereId", Value: 1}, {Key: "pinnedAt", Value: -1}}, Options: options.Index().
+			SetPartialFilterExpression(bson.D{{Key: "pinnedAt", Value: bson.D{{Key: "$exists", Value: true}}}})},
+		{Keys: bson.D{{Key: "poll.closesAt", Value: 1}}, Options: options.Index().
+			SetPartialFilterExpression(bson.D{{Key: "poll.closed", Value: false}})},
+		{Keys: bson.D{{Key: "mediaIds", Value: 1}}},
 	})
 
 	if err != nil {
 		logrus.Fatal(err)
 	}
 
-	_, err = s.mediaColl.Indexes().CreateMany(s.ctx, []mongo.IndexModel{
-		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
-		{Keys: bson.D{{Key: "sphereId", Value: 1}, {Key: "userId", Value: 1}, {Key: "status", Value: 1}}},
+	_, err = s.sphereContentEditsColl.Indexes().CreateMany(s.ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "contentId", Value: 1}, {Key: "_id", Value: -1}}},
+	})
+
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	_, err = s.auditColl.Indexes().CreateMany(s.ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "sphereId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	// un reporte abierto por usuario y contenido
+	_, err = s.reportsColl.Indexes().CreateMany(s.ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "contentId", Value: 1}, {Key: "reporterId", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.D{{Key: "status", Value: "open"}}),
+		},
+		{Keys: bson.D{{Key: "sphereId", Value: 1}, {Key: "status", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	// los mutes vencen solos por TTL
+	_, err = s.sanctionsColl.Indexes().CreateMany(s.ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "sphereId", Value: 1}, {Key: "userId", Value: 1}, {Key: "type", Value: 1}}, Options: options.Index().SetUnique(true)},
 		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
 	})
 
@@ -65,9 +165,95 @@ func (s *Service) Config() {
 		logrus.Fatal(err)
 	}
 
+	// una reacción por (contenido, usuario, símbolo); los contadores viven en el post
+	_, err = s.reactionsColl.Indexes().CreateMany(s.ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "contentId", Value: 1}, {Key: "userId", Value: 1}, {Key: "symbol", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "contentId", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "contentId", Value: 1}, {Key: "symbol", Value: 1}, {Key: "_id", Value: -1}}},
+	})
+
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	go routes.MigrateEmbeddedReactions(s.ctx, s.sphereContentsColl, s.reactionsColl, persistence.MustGetCollection(constants.SpheresMigrationsCollectionName))
+
+	// un voto por usuario y encuesta
+	_, err = s.pollVotesColl.Indexes().CreateMany(s.ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "contentId", Value: 1}, {Key: "userId", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	// inbox: la key hace idempotente el fan-out; las viejas vencen por TTL
+	_, err = s.notificationsColl.Indexes().CreateMany(s.ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "read", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "createdAt", Value: 1}}, Options: options.Index().
+			SetExpireAfterSeconds(int32(helpers.GetEnvDur("NOTIFICATIONS_TTL", 90*24*time.Hour).Seconds()))},
+	})
+
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
 	s.EventStore = system.NewEventStore(constants.SpheresServiceName)
 
+	s.Limiter = system.NewRateLimiter(s.ctx, s.EventStore)
+
+	// búsqueda: typesense indexado desde los eventos spheres.content.*
+	wallets := persistence.MustGetCollection("wallets")
+	s.Search = typesense.NewClientFromEnv()
+	indexer := &search.Indexer{
+		TS:         s.Search,
+		Collection: constants.TypesenseSphereContentsCollection,
+		Contents:   s.sphereContentsColl,
+		Wallets:    wallets,
+	}
+	indexer.Start(s.ctx, s.EventStore)
+
+	// notificaciones: respuestas, menciones y reacciones -> inbox + notify.user.<userId>
+	worker := &notifications.Worker{
+		Contents:   s.sphereContentsColl,
+		Wallets:    wallets,
+		Inbox:      s.notificationsColl,
+		Prefs:      s.notificationPrefsColl,
+		EventStore: s.EventStore,
+	}
+	worker.Start(s.ctx)
+
+	// cierre de encuestas vencidas (spheres.poll.closed.<sphereId>)
+	go routes.RunPollCloser(s.ctx, s.sphereContentsColl, s.EventStore, helpers.GetEnvDur("POLL_CLOSER_EVERY", 30*time.Second))
+
+	// mismo secreto HS256 que notify-service
+	s.Verifier = ownhttp.NewHMACVerifier(helpers.GetEnvOrFail(ownhttp.AuthSecretEnv))
+
+	// filtros de contenido; las reglas se recargan si cambia el archivo
+	s.Filters = filter.Default(nil)
+	if path := helpers.GetEnv("CONTENT_FILTER_RULES", ""); path != "" {
+		go s.Filters.Watch(s.ctx, path, helpers.GetEnvDur("CONTENT_FILTER_RELOAD", 30*time.Second))
+	}
+
 	s.S3Cfg, s.S3c, s.Presigner = system.LoadS3(s.ctx)
+
+	// media de posts/banners: presign en s3-service, los posts esperan media.process.* para verse
+	s.Media = &routes.Media{
+		Spheres:    s.spheresColl,
+		Contents:   s.sphereContentsColl,
+		Assets:     s.assetsColl,
+		Blobs:      s.blobsColl,
+		Sanctions:  s.sanctionsColl,
+		Audit:      s.auditColl,
+		Blocklist:  s.blockColl,
+		Pipeline:   media.NewClientFromEnv(),
+		S3Cfg:      s.S3Cfg,
+		S3c:        s.S3c,
+		Presigner:  s.Presigner,
+		EventStore: s.EventStore,
+	}
+	s.Media.Start(s.ctx)
 }
 
 func (s *Service) Start(sys *system.System) {
@@ -0,0 +1,124 @@
+package filter
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Outcome de una regla; el peor resultado del pipeline gana.
+type Outcome string
+
+const (
+	Allow  Outcome = "allow"
+	Flag   Outcome = "flag"
+	Reject Outcome = "reject"
+)
+
+func (o Outcome) rank() int {
+	switch o {
+	case Reject:
+		return 2
+	case Flag:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (o Outcome) valid() bool {
+	return o == Allow || o == Flag || o == Reject
+}
+
+// Input es el post que se va a insertar.
+type Input struct {
+	SphereID string
+	UserID   string
+	ParentID string
+	Text     string
+	At       time.Time
+	// edición de un post existente: no cuenta como mensaje nuevo para flood/duplicados
+	Edit bool
+}
+
+// Hit es una regla que no dio allow.
+type Hit struct {
+	Rule    string  `json:"rule" bson:"rule"`
+	Outcome Outcome `json:"outcome" bson:"outcome"`
+	Reason  string  `json:"reason" bson:"reason"`
+	Match   string  `json:"match,omitempty" bson:"match,omitempty"`
+}
+
+type Result struct {
+	Outcome Outcome `json:"outcome"`
+	Hits    []Hit   `json:"hits,omitempty"`
+}
+
+// Filter es un paso del pipeline. Check solo devuelve hits (allow = sin hits).
+type Filter interface {
+	Name() string
+	Check(ctx context.Context, in *Input, rules *Rules) []Hit
+}
+
+// Pipeline corre los filtros en orden con las reglas vigentes.
+type Pipeline struct {
+	filters []Filter
+	rules   atomic.Pointer[Rules]
+}
+
+func NewPipeline(rules *Rules, filters ...Filter) *Pipeline {
+	p := &Pipeline{filters: filters}
+	if rules == nil {
+		rules = DefaultRules()
+	}
+	p.rules.Store(rules)
+	return p
+}
+
+// Default arma el pipeline estándar de spheres.
+func Default(rules *Rules) *Pipeline {
+	return NewPipeline(rules,
+		&URLFilter{},
+		&SolanaAddressFilter{},
+		NewWindowFilter(),
+	)
+}
+
+func (p *Pipeline) Rules() *Rules {
+	return p.rules.Load()
+}
+
+// SetRules cambia las reglas en caliente.
+func (p *Pipeline) SetRules(r *Rules) {
+	if r != nil {
+		p.rules.Store(r)
+	}
+}
+
+// Evaluate corre todos los filtros; corta en el primer reject.
+func (p *Pipeline) Evaluate(ctx context.Context, in *Input) Result {
+	if in.At.IsZero() {
+		in.At = time.Now()
+	}
+
+	rules := p.rules.Load()
+	res := Result{Outcome: Allow}
+	for _, f := range p.filters {
+		for _, h := range f.Check(ctx, in, rules) {
+			if h.Outcome == Allow {
+				continue
+			}
+			if h.Rule == "" {
+				h.Rule = f.Name()
+			}
+			res.Hits = append(res.Hits, h)
+			if h.Outcome.rank() > res.Outcome.rank() {
+				res.Outcome = h.Outcome
+			}
+		}
+		if res.Outcome == Reject {
+			break
+		}
+	}
+	return res
+}
@@ -0,0 +1,206 @@
+package filter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+const (
+	mint  = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+	other = "So11111111111111111111111111111111111111112"
+)
+
+func TestExtractHosts(t *testing.T) {
+	got := ExtractHosts("claim at https://www.Claim-Airdrop.xyz/abc, or solscan.io/tx/1. v1.2 e.g. done.")
+	want := []string{"claim-airdrop.xyz", "solscan.io"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("ExtractHosts = %v, want %v", got, want)
+	}
+}
+
+func TestURLFilter(t *testing.T) {
+	rules := DefaultRules()
+	rules.DenyDomains = []string{"drainer.io"}
+	p := NewPipeline(rules, &URLFilter{})
+
+	cases := []struct {
+		text string
+		want Outcome
+	}{
+		{"chart https://dexscreener.com/solana/abc", Allow},
+		{"see app.x.com/status/1", Allow},
+		{"go to https://sub.drainer.io/connect", Reject},
+		{"free airdrop-sol.xyz now", Flag},
+		{"my blog https://example.org", Allow},
+	}
+	for _, c := range cases {
+		if got := p.Evaluate(context.Background(), &Input{Text: c.text}).Outcome; got != c.want {
+			t.Errorf("%q: outcome %s, want %s", c.text, got, c.want)
+		}
+	}
+}
+
+func TestSolanaAddressFilter(t *testing.T) {
+	p := NewPipeline(nil, &SolanaAddressFilter{})
+
+	res := p.Evaluate(context.Background(), &Input{SphereID: mint, Text: "CA: " + mint})
+	if res.Outcome != Allow {
+		t.Fatalf("sphere mint: outcome %s, want allow", res.Outcome)
+	}
+
+	res = p.Evaluate(context.Background(), &Input{SphereID: mint, Text: "new CA " + other + " hurry"})
+	if res.Outcome != Flag || len(res.Hits) != 1 || res.Hits[0].Match != other {
+		t.Fatalf("foreign address: %+v", res)
+	}
+
+	// no es base58 válido de 32 bytes
+	res = p.Evaluate(context.Background(), &Input{SphereID: mint, Text: "0OIl0OIl0OIl0OIl0OIl0OIl0OIl0OIl"})
+	if res.Outcome != Allow {
+		t.Fatalf("invalid address: outcome %s, want allow", res.Outcome)
+	}
+}
+
+func TestWindowFilterDuplicateAndFlood(t *testing.T) {
+	rules := DefaultRules()
+	rules.Duplicate = WindowRule{Window: Duration(10 * time.Minute), Max: 2, Outcome: Reject}
+	rules.Flood = WindowRule{Window: Duration(time.Minute), Max: 3, Outcome: Flag}
+	p := NewPipeline(rules, NewWindowFilter())
+
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	eval := func(text string, at time.Time) Outcome {
+		return p.Evaluate(context.Background(), &Input{SphereID: "s1", UserID: "u1", Text: text, At: at}).Outcome
+	}
+
+	if o := eval("GM!!", t0); o != Allow {
+		t.Fatalf("1st: %s", o)
+	}
+	if o := eval("gm", t0.Add(time.Second)); o != Allow {
+		t.Fatalf("2nd: %s", o)
+	}
+	if o := eval("g m", t0.Add(2*time.Second)); o != Reject {
+		t.Fatalf("3rd duplicate: %s, want reject", o)
+	}
+
+	// flood: el 4to mensaje distinto dentro del minuto
+	if o := eval("something else", t0.Add(3*time.Second)); o != Flag {
+		t.Fatalf("flood: %s, want flag", o)
+	}
+
+	// fuera de las dos ventanas vuelve a pasar
+	if o := eval("gm", t0.Add(11*time.Minute)); o != Allow {
+		t.Fatalf("after window: %s", o)
+	}
+
+	// otro usuario no comparte ventana
+	res := p.Evaluate(context.Background(), &Input{SphereID: "s1", UserID: "u2", Text: "gm", At: t0.Add(3 * time.Second)})
+	if res.Outcome != Allow {
+		t.Fatalf("other user: %s", res.Outcome)
+	}
+}
+
+func TestWindowFilterIgnoresEdits(t *testing.T) {
+	rules := DefaultRules()
+	rules.Flood = WindowRule{Window: Duration(time.Minute), Max: 1, Outcome: Reject}
+	p := NewPipeline(rules, NewWindowFilter())
+
+	at := time.Now()
+	in := func(edit bool) *Input {
+		return &Input{SphereID: "s1", UserID: "u1", Text: "hola", At: at, Edit: edit}
+	}
+	if o := p.Evaluate(context.Background(), in(false)).Outcome; o != Allow {
+		t.Fatalf("post: %s", o)
+	}
+	if o := p.Evaluate(context.Background(), in(true)).Outcome; o != Allow {
+		t.Fatalf("edit counted as flood: %s", o)
+	}
+}
+
+func TestEvaluateStopsOnReject(t *testing.T) {
+	rules := DefaultRules()
+	rules.DenyDomains = []string{"drainer.io"}
+	w := NewWindowFilter()
+	p := NewPipeline(rules, &URLFilter{}, w)
+
+	res := p.Evaluate(context.Background(), &Input{SphereID: "s1", UserID: "u1", Text: "drainer.io"})
+	if res.Outcome != Reject || len(res.Hits) != 1 || res.Hits[0].Rule != "url.deny" {
+		t.Fatalf("result: %+v", res)
+	}
+	if len(w.entries) != 0 {
+		t.Fatal("window filter ran after a reject")
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	r, err := ParseRules([]byte(`{"denyDomains":["WWW.Bad.io "],"flood":{"window":"30s","max":5,"outcome":"flag"},"duplicate":{"window":120,"max":1,"outcome":"reject"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(r.DenyDomains, []string{"bad.io"}) {
+		t.Fatalf("denyDomains = %v", r.DenyDomains)
+	}
+	if r.Flood.Window.Std() != 30*time.Second || r.Duplicate.Window.Std() != 2*time.Minute {
+		t.Fatalf("windows = %s / %s", r.Flood.Window.Std(), r.Duplicate.Window.Std())
+	}
+	// lo que no viene queda en default
+	if r.SuspiciousDomain != Flag || len(r.AllowDomains) == 0 {
+		t.Fatal("defaults not kept")
+	}
+
+	if _, err := ParseRules([]byte(`{"deniedDomain":"block"}`)); err == nil {
+		t.Fatal("invalid outcome accepted")
+	}
+}
+
+func TestWatchReloadsRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(`{"denyDomains":["one.io"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := Default(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Watch(ctx, path, 10*time.Millisecond)
+
+	waitFor(t, func() bool { return slices.Equal(p.Rules().DenyDomains, []string{"one.io"}) })
+
+	// un archivo inválido no pisa las reglas vigentes
+	if err := os.WriteFile(path, []byte(`{"deniedDomain":"nope"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bump(t, path, time.Second)
+	time.Sleep(50 * time.Millisecond)
+	if !slices.Equal(p.Rules().DenyDomains, []string{"one.io"}) {
+		t.Fatalf("invalid rules replaced the current ones: %v", p.Rules().DenyDomains)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"denyDomains":["two.io"]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bump(t, path, 2*time.Second)
+	waitFor(t, func() bool { return slices.Equal(p.Rules().DenyDomains, []string{"two.io"}) })
+}
+
+// bump adelanta el mtime: algunos filesystems tienen resolución de 1s
+func bump(t *testing.T, path string, d time.Duration) {
+	t.Helper()
+	at := time.Now().Add(d)
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,163 @@
+package filter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WindowRule: más de Max mensajes dentro de Window dispara Outcome.
+type WindowRule struct {
+	Window  Duration `json:"window"`
+	Max     int      `json:"max"`
+	Outcome Outcome  `json:"outcome"`
+}
+
+// Rules se cargan desde JSON y se pueden recargar sin reiniciar.
+type Rules struct {
+	// dominios (y subdominios) siempre permitidos / bloqueados
+	AllowDomains []string `json:"allowDomains"`
+	DenyDomains  []string `json:"denyDomains"`
+	// palabras en el dominio típicas de drainers ("airdrop", "claim"...)
+	SuspiciousDomainWords []string `json:"suspiciousDomainWords"`
+
+	DeniedDomain     Outcome `json:"deniedDomain"`
+	SuspiciousDomain Outcome `json:"suspiciousDomain"`
+	UnknownDomain    Outcome `json:"unknownDomain"`
+	// direcciones solana que no son el mint de la sphere
+	ForeignAddress Outcome `json:"foreignAddress"`
+	// direcciones siempre permitidas (programas conocidos, etc.)
+	AllowAddresses []string `json:"allowAddresses"`
+
+	Duplicate WindowRule `json:"duplicate"`
+	Flood     WindowRule `json:"flood"`
+}
+
+func DefaultRules() *Rules {
+	r := &Rules{
+		AllowDomains: []string{
+			"moonmap.io", "x.com", "twitter.com", "t.me", "solscan.io",
+			"dexscreener.com", "birdeye.so", "pump.fun", "jup.ag", "raydium.io",
+		},
+		SuspiciousDomainWords: []string{"airdrop", "claim", "drop", "giveaway", "reward", "connect-wallet", "walletconnect", "verify"},
+		DeniedDomain:          Reject,
+		SuspiciousDomain:      Flag,
+		UnknownDomain:         Allow,
+		ForeignAddress:        Flag,
+		Duplicate:             WindowRule{Window: Duration(10 * time.Minute), Max: 2, Outcome: Reject},
+		Flood:                 WindowRule{Window: Duration(time.Minute), Max: 10, Outcome: Reject},
+	}
+	r.normalize()
+	return r
+}
+
+// ParseRules completa con los defaults lo que no venga en el JSON.
+func ParseRules(b []byte) (*Rules, error) {
+	r := DefaultRules()
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, err
+	}
+	for name, o := range map[string]Outcome{
+		"deniedDomain":      r.DeniedDomain,
+		"suspiciousDomain":  r.SuspiciousDomain,
+		"unknownDomain":     r.UnknownDomain,
+		"foreignAddress":    r.ForeignAddress,
+		"duplicate.outcome": r.Duplicate.Outcome,
+		"flood.outcome":     r.Flood.Outcome,
+	} {
+		if !o.valid() {
+			return nil, fmt.Errorf("rules: invalid outcome %q for %s", o, name)
+		}
+	}
+	r.normalize()
+	return r, nil
+}
+
+func (r *Rules) normalize() {
+	norm := func(in []string) []string {
+		out := make([]string, 0, len(in))
+		for _, d := range in {
+			d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "www.")
+			if d != "" {
+				out = append(out, d)
+			}
+		}
+		return out
+	}
+	r.AllowDomains = norm(r.AllowDomains)
+	r.DenyDomains = norm(r.DenyDomains)
+	r.SuspiciousDomainWords = norm(r.SuspiciousDomainWords)
+}
+
+// Watch recarga el archivo de reglas cuando cambia (por mtime) hasta que ctx termine.
+func (p *Pipeline) Watch(ctx context.Context, path string, every time.Duration) {
+	var last time.Time
+	load := func() {
+		st, err := os.Stat(path)
+		if err != nil {
+			logrus.WithError(err).Warnf("content filter: cannot stat rules %s", path)
+			return
+		}
+		if !st.ModTime().After(last) {
+			return
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			logrus.WithError(err).Warnf("content filter: cannot read rules %s", path)
+			return
+		}
+		rules, err := ParseRules(b)
+		if err != nil {
+			// se quedan las reglas anteriores
+			logrus.WithError(err).Errorf("content filter: invalid rules %s", path)
+			return
+		}
+		last = st.ModTime()
+		p.SetRules(rules)
+		logrus.Infof("content filter: rules loaded from %s", path)
+	}
+
+	load()
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			load()
+		}
+	}
+}
+
+// Duration acepta "30s" o segundos en JSON.
+type Duration time.Duration
+
+func (d Duration) Std() time.Duration { return time.Duration(d) }
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = Duration(v)
+		return nil
+	}
+	var n float64
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+	*d = Duration(time.Duration(n * float64(time.Second)))
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
@@ -0,0 +1,40 @@
+package filter
+
+import (
+	"context"
+	"regexp"
+	"slices"
+
+	"moonmap.io/go-commons/helpers"
+)
+
+// candidatos base58 del largo de una pubkey; se confirman decodificando a 32 bytes
+var base58Re = regexp.MustCompile(`\b[1-9A-HJ-NP-Za-km-z]{32,44}\b`)
+
+// SolanaAddresses devuelve las pubkeys válidas que aparecen en el texto.
+func SolanaAddresses(text string) []string {
+	var out []string
+	for _, m := range base58Re.FindAllString(text, -1) {
+		if _, err := helpers.Base58ToPublicKey(m); err == nil && !slices.Contains(out, m) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// SolanaAddressFilter marca direcciones que no son el mint de la sphere
+// (típico "nuevo CA", "send SOL here").
+type SolanaAddressFilter struct{}
+
+func (f *SolanaAddressFilter) Name() string { return "solana" }
+
+func (f *SolanaAddressFilter) Check(_ context.Context, in *Input, rules *Rules) []Hit {
+	var hits []Hit
+	for _, addr := range SolanaAddresses(in.Text) {
+		if addr == in.SphereID || slices.Contains(rules.AllowAddresses, addr) {
+			continue
+		}
+		hits = append(hits, Hit{Rule: "solana.foreign", Outcome: rules.ForeignAddress, Reason: "address is not the sphere mint", Match: addr})
+	}
+	return hits
+}
@@ -0,0 +1,101 @@
+package filter
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// URLs con esquema y dominios sueltos ("claim-airdrop.xyz/abc")
+	urlRe      = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"']+`)
+	bareHostRe = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{1,62}\b(?:/[^\s<>"']*)?`)
+)
+
+// TLDs aceptados para dominios sin esquema, evita falsos positivos tipo "v1.2" o "e.g"
+var bareTLDs = map[string]struct{}{
+	"com": {}, "net": {}, "org": {}, "io": {}, "xyz": {}, "app": {}, "fun": {}, "so": {}, "ag": {},
+	"me": {}, "co": {}, "finance": {}, "site": {}, "online": {}, "live": {}, "top": {}, "click": {},
+	"link": {}, "info": {}, "pro": {}, "gg": {}, "cc": {}, "ru": {}, "claims": {}, "dev": {},
+	"network": {}, "exchange": {}, "money": {}, "cash": {}, "world": {}, "vip": {}, "lol": {},
+}
+
+// ExtractHosts devuelve los hosts (en minúscula, sin www.) de las URLs del texto.
+func ExtractHosts(text string) []string {
+	seen := map[string]struct{}{}
+	var out []string
+	add := func(h string) {
+		h = strings.TrimPrefix(strings.ToLower(strings.TrimSuffix(h, ".")), "www.")
+		if h == "" {
+			return
+		}
+		if _, ok := seen[h]; !ok {
+			seen[h] = struct{}{}
+			out = append(out, h)
+		}
+	}
+
+	for _, raw := range urlRe.FindAllString(text, -1) {
+		if u, err := url.Parse(strings.TrimRight(raw, ".,;:!?)]}")); err == nil {
+			add(u.Hostname())
+		}
+	}
+
+	rest := urlRe.ReplaceAllString(text, " ")
+	for _, m := range bareHostRe.FindAllString(rest, -1) {
+		host := m
+		if i := strings.IndexByte(host, '/'); i >= 0 {
+			host = host[:i]
+		}
+		host = strings.ToLower(host)
+		tld := host[strings.LastIndexByte(host, '.')+1:]
+		if _, ok := bareTLDs[tld]; ok {
+			add(host)
+		}
+	}
+	return out
+}
+
+// domainMatches: "example.com" cubre "example.com" y "*.example.com"
+func domainMatches(host string, domains []string) (string, bool) {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return d, true
+		}
+	}
+	return "", false
+}
+
+// URLFilter aplica las listas de dominios a cada link del post.
+type URLFilter struct{}
+
+func (f *URLFilter) Name() string { return "url" }
+
+func (f *URLFilter) Check(_ context.Context, in *Input, rules *Rules) []Hit {
+	var hits []Hit
+	for _, host := range ExtractHosts(in.Text) {
+		if _, ok := domainMatches(host, rules.AllowDomains); ok {
+			continue
+		}
+		if d, ok := domainMatches(host, rules.DenyDomains); ok {
+			hits = append(hits, Hit{Rule: "url.deny", Outcome: rules.DeniedDomain, Reason: "denied domain " + d, Match: host})
+			continue
+		}
+		if w := suspiciousWord(host, rules.SuspiciousDomainWords); w != "" {
+			hits = append(hits, Hit{Rule: "url.suspicious", Outcome: rules.SuspiciousDomain, Reason: "suspicious domain (" + w + ")", Match: host})
+			continue
+		}
+		hits = append(hits, Hit{Rule: "url.unknown", Outcome: rules.UnknownDomain, Reason: "unknown domain", Match: host})
+	}
+	return hits
+}
+
+func suspiciousWord(host string, words []string) string {
+	for _, w := range words {
+		if strings.Contains(host, w) {
+			return w
+		}
+	}
+	return ""
+}
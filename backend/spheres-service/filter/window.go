@@ -0,0 +1,114 @@
+package filter
+
+import (
+	"context"
+	"crypto/sha256"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+type windowEntry struct {
+	at   time.Time
+	hash [32]byte
+}
+
+// WindowFilter detecta mensajes repetidos y flood por usuario y sphere
+// con una ventana deslizante en memoria (por réplica).
+type WindowFilter struct {
+	mu      sync.Mutex
+	entries map[string][]windowEntry
+	sweeps  int
+}
+
+func NewWindowFilter() *WindowFilter {
+	return &WindowFilter{entries: map[string][]windowEntry{}}
+}
+
+func (f *WindowFilter) Name() string { return "window" }
+
+func (f *WindowFilter) Check(_ context.Context, in *Input, rules *Rules) []Hit {
+	if in.UserID == "" || in.Edit {
+		return nil
+	}
+	keep := max(rules.Duplicate.Window.Std(), rules.Flood.Window.Std())
+	if keep <= 0 {
+		return nil
+	}
+
+	key := in.SphereID + "|" + in.UserID
+	norm := normalizeText(in.Text)
+	if norm == "" {
+		// solo emojis/símbolos: se compara el texto tal cual
+		norm = strings.TrimSpace(in.Text)
+	}
+	h := sha256.Sum256([]byte(norm))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sweeps++
+	if f.sweeps%1024 == 0 {
+		f.sweep(in.At.Add(-keep))
+	}
+
+	prev := f.entries[key]
+	cut := 0
+	for cut < len(prev) && prev[cut].at.Before(in.At.Add(-keep)) {
+		cut++
+	}
+	prev = prev[cut:]
+
+	var hits []Hit
+	if r := rules.Flood; r.Max > 0 && r.Window > 0 {
+		n := countSince(prev, in.At.Add(-r.Window.Std()), nil)
+		if n >= r.Max {
+			hits = append(hits, Hit{Rule: "window.flood", Outcome: r.Outcome, Reason: "more than " + strconv.Itoa(r.Max) + " messages in " + r.Window.Std().String()})
+		}
+	}
+	if r := rules.Duplicate; r.Max > 0 && r.Window > 0 && strings.TrimSpace(in.Text) != "" {
+		n := countSince(prev, in.At.Add(-r.Window.Std()), &h)
+		if n >= r.Max {
+			hits = append(hits, Hit{Rule: "window.duplicate", Outcome: r.Outcome, Reason: "same message repeated " + strconv.Itoa(n+1) + " times in " + r.Window.Std().String()})
+		}
+	}
+
+	// los intentos rechazados también cuentan, así el spam no se resetea
+	f.entries[key] = append(prev, windowEntry{at: in.At, hash: h})
+	return hits
+}
+
+func countSince(entries []windowEntry, since time.Time, hash *[32]byte) int {
+	n := 0
+	for _, e := range entries {
+		if e.at.Before(since) {
+			continue
+		}
+		if hash == nil || e.hash == *hash {
+			n++
+		}
+	}
+	return n
+}
+
+// sweep borra usuarios inactivos
+func (f *WindowFilter) sweep(before time.Time) {
+	for k, es := range f.entries {
+		if len(es) == 0 || es[len(es)-1].at.Before(before) {
+			delete(f.entries, k)
+		}
+	}
+}
+
+// normalizeText: minúsculas, sin espacios ni puntuación, para que "GM!!" == "gm"
+func normalizeText(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,90 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"moonmap.io/go-commons/helpers"
+)
+
+// Client habla con el publisher de s3-service (POST /media/presign): keys, variantes
+// planeadas y el documento en media_assets salen de ahí, no de spheres.
+type Client struct {
+	BaseURL    string
+	httpClient *http.Client
+}
+
+func NewClientFromEnv() *Client {
+	return &Client{
+		BaseURL:    helpers.GetEnvOrFail("S3_SERVICE_URL"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// mismo contrato que core.PresignReq de s3-service
+type PresignReq struct {
+	Namespace string `json:"namespace"`
+	ScopeID   string `json:"scopeId"`
+	Profile   string `json:"profile"`
+	EntityID  string `json:"entityId"`
+	ScopeType string `json:"scopeType"`
+	UserID    string `json:"userId"`
+	Ext       string `json:"ext"`
+	Mime      string `json:"mime"`
+}
+
+type PresignRes struct {
+	MediaID      string            `json:"mediaId"`
+	Key          string            `json:"key"`
+	UploadURL    string            `json:"uploadUrl"`
+	UploadMethod string            `json:"uploadMethod"`
+	UploadFields map[string]string `json:"uploadFields"`
+	MaxBytes     int64             `json:"maxBytes"`
+	ExpiresAt    time.Time         `json:"expiresAt"`
+	Status       string            `json:"status"`
+}
+
+// ErrPresignRejected: s3-service rechazó el pedido (ej: mime no permitido), no es una caída
+var ErrPresignRejected = errors.New("presign rejected")
+
+// ErrPresignRateLimited: el usuario pasó el límite de presigns de s3-service
+var ErrPresignRateLimited = errors.New("presign rate limited")
+
+func (c *Client) Presign(ctx context.Context, in PresignReq) (*PresignRes, error) {
+	body, _ := json.Marshal(in)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/media/presign", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusBadRequest {
+		return nil, fmt.Errorf("%w: s3-service returned %d", ErrPresignRejected, resp.StatusCode)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, ErrPresignRateLimited
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("s3-service presign returned %d", resp.StatusCode)
+	}
+
+	var out PresignRes
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	if out.MediaID == "" {
+		return nil, fmt.Errorf("s3-service presign returned no mediaId")
+	}
+	return &out, nil
+}
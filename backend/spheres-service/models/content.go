@@ -14,17 +14,21 @@ type SphereContentEmbeddedUser struct {
 }
 
 type SphereContentCreated struct {
-	ID        bson.ObjectID             `bson:"_id" json:"_id"`
-	ParentID  *bson.ObjectID            `bson:"parentId,omitempty" json:"parentId"`
-	SphereID  string                    `bson:"sphereId" json:"sphereId"`
-	User      SphereContentEmbeddedUser `bson:"user" json:"user"`
-	Reactions map[string][]string       `bson:"reactions" json:"reactions"`
-	Type      string                    `bson:"type" json:"type"`
-	Text      string                    `bson:"text" json:"text"`
-	MediaUrls []string                  `bson:"mediaUrls" json:"mediaUrls"`
-	CreatedAt time.Time                 `bson:"createdAt" json:"createdAt"`
-	UpdatedAt time.Time                 `bson:"updatedAt" json:"updatedAt"`
-	Deleted   bool                      `bson:"deleted" json:"deleted"`
+	ID             bson.ObjectID             `bson:"_id" json:"_id"`
+	ParentID       *bson.ObjectID            `bson:"parentId,omitempty" json:"parentId"`
+	SphereID       string                    `bson:"sphereId" json:"sphereId"`
+	User           SphereContentEmbeddedUser `bson:"user" json:"user"`
+	ReactionCounts map[string]int            `bson:"reactionCounts" json:"reactionCounts"`
+	Type           string                    `bson:"type" json:"type"`
+	Text           string                    `bson:"text" json:"text"`
+	MediaUrls      []string                  `bson:"mediaUrls" json:"mediaUrls"` // posts viejos, antes del pipeline
+	MediaIDs       []bson.ObjectID           `bson:"mediaIds,omitempty" json:"-"`
+	Media          []*MediaAsset             `bson:"-" json:"media"`
+	MediaPending   bool                      `bson:"mediaPending,omitempty" json:"mediaPending,omitempty"`
+	Poll           *Poll                     `bson:"poll,omitempty" json:"poll,omitempty"`
+	CreatedAt      time.Time                 `bson:"createdAt" json:"createdAt"`
+	UpdatedAt      time.Time                 `bson:"updatedAt" json:"updatedAt"`
+	Deleted        bool                      `bson:"deleted" json:"deleted"`
 }
 
 type SphereContentUpdated struct {
@@ -40,3 +44,34 @@ type SphereContentDeleted struct {
 	Deleted   bool      `json:"deleted"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
+
+// documento guardado en sphere_contents (solo los campos que se leen)
+type SphereContent struct {
+	ID           bson.ObjectID   `bson:"_id" json:"_id"`
+	ParentID     *bson.ObjectID  `bson:"parentId,omitempty" json:"parentId"`
+	SphereID     string          `bson:"sphereId" json:"sphereId"`
+	UserID       bson.ObjectID   `bson:"userId" json:"userId"`
+	Type         string          `bson:"type" json:"type"`
+	Text         string          `bson:"text" json:"text"`
+	MediaUrls    []string        `bson:"mediaUrls" json:"mediaUrls"`
+	MediaIDs     []bson.ObjectID `bson:"mediaIds,omitempty" json:"mediaIds,omitempty"`
+	MediaPending bool            `bson:"mediaPending,omitempty" json:"mediaPending,omitempty"` // invisible hasta que sus media estén ready
+	EditCount    int             `bson:"editCount,omitempty" json:"editCount,omitempty"`
+	Poll         *Poll           `bson:"poll,omitempty" json:"poll,omitempty"`
+	PinnedAt     *time.Time      `bson:"pinnedAt,omitempty" json:"pinnedAt,omitempty"`
+	Deleted      bool            `bson:"deleted" json:"deleted"`
+	Hidden       bool            `bson:"hidden,omitempty" json:"-"` // oculto por moderación
+	CreatedAt    time.Time       `bson:"createdAt" json:"createdAt"`
+}
+
+// versión anterior de un contenido editado
+type SphereContentEdit struct {
+	ID        bson.ObjectID   `bson:"_id,omitempty" json:"_id"`
+	ContentID bson.ObjectID   `bson:"contentId" json:"contentId"`
+	SphereID  string          `bson:"sphereId" json:"sphereId"`
+	Version   int             `bson:"version" json:"version"`
+	Text      string          `bson:"text" json:"text"`
+	MediaUrls []string        `bson:"mediaUrls" json:"mediaUrls"`
+	MediaIDs  []bson.ObjectID `bson:"mediaIds,omitempty" json:"mediaIds,omitempty"`
+	EditedAt  time.Time       `bson:"editedAt" json:"editedAt"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "go.mongodb.org/mongo-driver/v2/bson"
+
+// media de spheres: sube por el pipeline de s3-service (media_assets)
+const (
+	MediaNamespace     = "spheres"
+	MediaScopeType     = "sphere"
+	MediaProfilePost   = "post_image"
+	MediaProfileBanner = "banner"
+
+	MediaReady       = "ready"
+	MediaFailed      = "failed"
+	MediaQuarantined = "quarantined" // screening lo frenó: espera revisión de un moderador
+)
+
+// estado de un asset del pipeline de s3-service (media_assets)
+type MediaAsset struct {
+	ID         bson.ObjectID     `bson:"_id" json:"mediaId"`
+	Key        string            `bson:"key" json:"-"`
+	Namespace  string            `bson:"namespace" json:"-"`
+	ScopeID    string            `bson:"scopeId" json:"-"`
+	Profile    string            `bson:"profile" json:"-"`
+	UploaderID string            `bson:"uploaderId" json:"-"`
+	MediaType  string            `bson:"mediaType" json:"mediaType,omitempty"`
+	Status     string            `bson:"status" json:"status"`
+	Urls       map[string]string `bson:"urls" json:"urls,omitempty"`
+	Width      int               `bson:"width,omitempty" json:"width,omitempty"`
+	Height     int               `bson:"height,omitempty" json:"height,omitempty"`
+}
+
+// View deja solo las variantes procesadas: las urls existen recién en ready y el
+// original (privado, con EXIF) nunca sale.
+func (a *MediaAsset) View() *MediaAsset {
+	v := *a
+	v.Urls = nil
+	if a.Status == MediaReady {
+		v.Urls = make(map[string]string, len(a.Urls))
+		for k, u := range a.Urls {
+			if k != "original" {
+				v.Urls[k] = u
+			}
+		}
+	}
+	return &v
+}
+
+// IsSphereUpload: subido para esta sphere, con ese profile y por ese usuario
+func (a *MediaAsset) IsSphereUpload(sphereId, profile, userId string) bool {
+	return a.Namespace == MediaNamespace && a.ScopeID == sphereId && a.Profile == profile && a.UploaderID == userId
+}
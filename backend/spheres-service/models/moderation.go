@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+
+	SanctionMute = "mute"
+	SanctionBan  = "ban"
+)
+
+// motivos aceptados al reportar
+var ReportReasons = []string{"spam", "scam", "abuse", "nsfw", "impersonation", "other"}
+
+type ContentReport struct {
+	ID         bson.ObjectID `bson:"_id,omitempty" json:"_id"`
+	SphereID   string        `bson:"sphereId" json:"sphereId"`
+	ContentID  bson.ObjectID `bson:"contentId" json:"contentId"`
+	ReporterID bson.ObjectID `bson:"reporterId" json:"reporterId"`
+	Reason     string        `bson:"reason" json:"reason"`
+	Note       string        `bson:"note,omitempty" json:"note,omitempty"`
+	Status     string        `bson:"status" json:"status"`
+	ResolvedBy string        `bson:"resolvedBy,omitempty" json:"resolvedBy,omitempty"`
+	ResolvedAt *time.Time    `bson:"resolvedAt,omitempty" json:"resolvedAt,omitempty"`
+	CreatedAt  time.Time     `bson:"createdAt" json:"createdAt"`
+}
+
+// mute o ban de un usuario en una sphere; sin expiresAt es permanente
+type Sanction struct {
+	SphereID  string        `bson:"sphereId" json:"sphereId"`
+	UserID    bson.ObjectID `bson:"userId" json:"userId"`
+	Type      string        `bson:"type" json:"type"`
+	Reason    string        `bson:"reason,omitempty" json:"reason,omitempty"`
+	By        string        `bson:"by" json:"by"`
+	ExpiresAt *time.Time    `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	CreatedAt time.Time     `bson:"createdAt" json:"createdAt"`
+}
+
+// evento publicado en spheres.moderation.<action>.<sphereId>
+type ModerationEvent struct {
+	SphereID  string     `json:"sphereId"`
+	Action    string     `json:"action"`
+	ContentID string     `json:"contentId,omitempty"`
+	UserID    string     `json:"userId,omitempty"`
+	ActorID   string     `json:"actorId,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
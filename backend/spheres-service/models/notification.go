@@ -0,0 +1,52 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	NotificationReply    = "reply"
+	NotificationMention  = "mention"
+	NotificationReaction = "reaction"
+)
+
+var NotificationTypes = []string{NotificationReply, NotificationMention, NotificationReaction}
+
+// entrada del inbox de un usuario (colección notifications)
+type Notification struct {
+	ID        bson.ObjectID  `bson:"_id" json:"_id"`
+	UserID    bson.ObjectID  `bson:"userId" json:"userId"`
+	Type      string         `bson:"type" json:"type"`
+	SphereID  string         `bson:"sphereId" json:"sphereId"`
+	ContentID bson.ObjectID  `bson:"contentId" json:"contentId"`
+	ParentID  *bson.ObjectID `bson:"parentId,omitempty" json:"parentId,omitempty"`
+	ActorID   bson.ObjectID  `bson:"actorId" json:"actorId"`
+	Symbol    string         `bson:"symbol,omitempty" json:"symbol,omitempty"`
+	Preview   string         `bson:"preview,omitempty" json:"preview,omitempty"`
+	Read      bool           `bson:"read" json:"read"`
+	ReadAt    *time.Time     `bson:"readAt,omitempty" json:"readAt,omitempty"`
+	// evita duplicados si el evento se entrega más de una vez
+	Key       string    `bson:"key" json:"-"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+// preferencias por usuario (_id = userId)
+type NotificationPrefs struct {
+	UserID       bson.ObjectID   `bson:"_id" json:"userId"`
+	MutedSpheres []string        `bson:"mutedSpheres" json:"mutedSpheres"`
+	MutedTypes   []string        `bson:"mutedTypes" json:"mutedTypes"`
+	MutedUsers   []bson.ObjectID `bson:"mutedUsers" json:"mutedUsers"`
+	UpdatedAt    time.Time       `bson:"updatedAt" json:"updatedAt"`
+}
+
+func (p *NotificationPrefs) Mutes(n *Notification) bool {
+	if p == nil {
+		return false
+	}
+	return slices.Contains(p.MutedSpheres, n.SphereID) ||
+		slices.Contains(p.MutedTypes, n.Type) ||
+		slices.Contains(p.MutedUsers, n.ActorID)
+}
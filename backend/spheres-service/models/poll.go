@@ -0,0 +1,55 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// tipos de sphere_contents
+const (
+	ContentText         = "text"
+	ContentAnnouncement = "announcement" // solo moderadores
+	ContentPoll         = "poll"
+)
+
+var ContentTypes = []string{ContentText, ContentAnnouncement, ContentPoll}
+
+func IsContentType(t string) bool {
+	return slices.Contains(ContentTypes, t)
+}
+
+// encuesta embebida en el contenido (type poll); el texto es la pregunta
+type Poll struct {
+	Options    []PollOption `bson:"options" json:"options"`
+	ClosesAt   time.Time    `bson:"closesAt" json:"closesAt"`
+	Closed     bool         `bson:"closed" json:"closed"`
+	TotalVotes int          `bson:"totalVotes" json:"totalVotes"`
+}
+
+type PollOption struct {
+	ID    int    `bson:"id" json:"id"`
+	Text  string `bson:"text" json:"text"`
+	Votes int    `bson:"votes" json:"votes"`
+}
+
+// voto en sphere_poll_votes, único por (contentId, userId)
+type PollVote struct {
+	ID        bson.ObjectID `bson:"_id" json:"_id"`
+	ContentID bson.ObjectID `bson:"contentId" json:"contentId"`
+	SphereID  string        `bson:"sphereId" json:"sphereId"`
+	UserID    bson.ObjectID `bson:"userId" json:"userId"`
+	Option    int           `bson:"option" json:"option"`
+	CreatedAt time.Time     `bson:"createdAt" json:"createdAt"`
+}
+
+// evento spheres.poll.<voted|closed>.<sphereId>
+type PollEvent struct {
+	ContentID  bson.ObjectID `json:"contentId"`
+	SphereID   string        `json:"sphereId"`
+	Options    []PollOption  `json:"options"`
+	TotalVotes int           `json:"totalVotes"`
+	Closed     bool          `json:"closed"`
+	ClosesAt   time.Time     `json:"closesAt"`
+}
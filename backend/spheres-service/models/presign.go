@@ -2,8 +2,6 @@ package models
 
 import (
 	"time"
-
-	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 type PresignItemReq struct {
@@ -12,30 +10,33 @@ type PresignItemReq struct {
 }
 
 type PresignBatchReq struct {
-	Items  []PresignItemReq `json:"items"`
-	UserID string           `json:"userId"`
+	Profile string           `json:"profile,omitempty"` // post_image (default) | banner
+	Items   []PresignItemReq `json:"items"`
 }
 
+// el upload es un POST multipart a uploadUrl: todos los uploadFields y el archivo ("file")
+// al final. S3 rechaza lo que pase de maxBytes o no tenga el Content-Type pedido.
 type PresignItemRes struct {
-	ID        string    `json:"id"`
-	Key       string    `json:"key"`
-	UploadURL string    `json:"uploadUrl"`
-	PublicURL string    `json:"publicUrl"`
-	ExpiresAt time.Time `json:"expiresAt"`
+	ID           string            `json:"id"` // mediaId para POST .../contents
+	Key          string            `json:"key"`
+	UploadURL    string            `json:"uploadUrl"`
+	UploadMethod string            `json:"uploadMethod"`
+	UploadFields map[string]string `json:"uploadFields"`
+	MaxBytes     int64             `json:"maxBytes"`
+	Status       string            `json:"status"`
+	ExpiresAt    time.Time         `json:"expiresAt"`
 }
 
 type PresignBatchRes struct {
 	Items []PresignItemRes `json:"items"`
 }
 
-type UploadPendingDoc struct {
-	ID        bson.ObjectID `bson:"_id,omitempty"`
-	SphereID  string        `bson:"sphereId"`
-	UserID    bson.ObjectID `bson:"userId"`
-	Key       string        `bson:"key"`
-	Mime      string        `bson:"mime"`
-	Status    string        `bson:"status"`
-	ExpiresAt time.Time     `bson:"expiresAt"`
-	CreatedAt time.Time     `bson:"createdAt"`
-	UpdatedAt time.Time     `bson:"updatedAt"`
+// evento media.pending (mismo contrato que core.MediaState de s3-service)
+type MediaPendingEvent struct {
+	MediaID    string    `json:"mediaId"`
+	Key        string    `json:"key"`
+	Mime       string    `json:"mime"`
+	UploaderID string    `json:"uploaderId"`
+	Status     string    `json:"status"`
+	UpdatedAt  time.Time `json:"updatedAt"`
 }
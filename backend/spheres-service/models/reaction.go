@@ -1,7 +1,26 @@
 package models
 
-import "go.mongodb.org/mongo-driver/v2/bson"
+import (
+	"slices"
+	"time"
 
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// set por defecto si la sphere no configuró el suyo
+var DefaultReactions = []string{"👍", "❤️", "😂", "🔥", "🚀", "👀", "😢", "👎"}
+
+// documento de sphere_reactions, único por (contentId, userId, symbol)
+type Reaction struct {
+	ID        bson.ObjectID `bson:"_id" json:"_id"`
+	ContentID bson.ObjectID `bson:"contentId" json:"contentId"`
+	SphereID  string        `bson:"sphereId" json:"sphereId"`
+	UserID    bson.ObjectID `bson:"userId" json:"userId"`
+	Symbol    string        `bson:"symbol" json:"symbol"`
+	CreatedAt time.Time     `bson:"createdAt" json:"createdAt"`
+}
+
+// evento spheres.content.reacted.<sphereId>; _id es el contenido
 type SphereContentReaction struct {
 	ID       bson.ObjectID  `bson:"_id" json:"_id"`
 	SphereID string         `bson:"sphereId" json:"sphereId"`
@@ -9,4 +28,17 @@ type SphereContentReaction struct {
 	ParentID *bson.ObjectID `bson:"parentId,omitempty" json:"parentId"`
 	Symbol   string         `bson:"symbol" json:"symbol"`
 	Action   string         `bson:"-" json:"action"` // "added" o "removed"
+	Counts   map[string]int `bson:"reactionCounts" json:"counts"`
+}
+
+// AllowsReaction: símbolos configurados en la sphere o DefaultReactions
+func (s *Sphere) AllowsReaction(symbol string) bool {
+	return slices.Contains(s.Reactions(), symbol)
+}
+
+func (s *Sphere) Reactions() []string {
+	if s == nil || len(s.AllowedReactions) == 0 {
+		return DefaultReactions
+	}
+	return s.AllowedReactions
 }
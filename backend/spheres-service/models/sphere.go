@@ -0,0 +1,90 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	PostingOpen     = "open"
+	PostingReadOnly = "read_only" // solo moderadores publican
+)
+
+const (
+	RoleOwner  = "owner"
+	RoleMod    = "mod"
+	RoleMember = "member"
+)
+
+// documento de spheres; _id es el mint (y se repite en mint para el índice único)
+type Sphere struct {
+	ID          string   `bson:"_id" json:"_id"`
+	Mint        string   `bson:"mint" json:"mint"`
+	CreatedBy   string   `bson:"createdBy" json:"createdBy"`
+	Moderators  []string `bson:"moderators,omitempty" json:"moderators"`
+	Title       string   `bson:"title,omitempty" json:"title"`
+	Description string   `bson:"description,omitempty" json:"description"`
+	Rules       []string `bson:"rules,omitempty" json:"rules"`
+	// media_assets (s3-service, namespace spheres, profile banner)
+	BannerMediaID *bson.ObjectID `bson:"bannerMediaId,omitempty" json:"bannerMediaId,omitempty"`
+	PostingMode   string         `bson:"postingMode,omitempty" json:"postingMode"`
+	// emojis permitidos para reaccionar (vacío = DefaultReactions)
+	AllowedReactions []string   `bson:"allowedReactions,omitempty" json:"allowedReactions,omitempty"`
+	MemberCount      int64      `bson:"memberCount" json:"memberCount"`
+	LastPostAt       *time.Time `bson:"lastPostAt,omitempty" json:"lastPostAt,omitempty"`
+	CreatedAt        time.Time  `bson:"createdAt" json:"createdAt"`
+	LastUpdated      time.Time  `bson:"lastUpdated" json:"lastUpdated"`
+
+	// posts fijados; el límite se controla con un update atómico sobre este array
+	PinnedIDs []bson.ObjectID `bson:"pinnedIds,omitempty" json:"-"`
+}
+
+// membresía en sphere_members, única por (sphereId, userId)
+type SphereMember struct {
+	ID       bson.ObjectID `bson:"_id" json:"_id"`
+	SphereID string        `bson:"sphereId" json:"sphereId"`
+	UserID   bson.ObjectID `bson:"userId" json:"userId"`
+	Role     string        `bson:"role" json:"role"`
+	JoinedAt time.Time     `bson:"joinedAt" json:"joinedAt"`
+}
+
+// CanPost: en read_only solo publican los moderadores
+func (s *Sphere) CanPost(userId string) bool {
+	if s == nil || s.PostingMode != PostingReadOnly {
+		return true
+	}
+	return s.IsModerator(userId)
+}
+
+// RoleOf deriva el rol de createdBy/moderators (la fuente de verdad para authz)
+func (s *Sphere) RoleOf(userId string) string {
+	switch {
+	case s.CreatedBy == userId:
+		return RoleOwner
+	case slices.Contains(s.Moderators, userId):
+		return RoleMod
+	}
+	return RoleMember
+}
+
+// el creador de la sphere siempre modera
+func (s *Sphere) IsModerator(userId string) bool {
+	if s == nil || userId == "" {
+		return false
+	}
+	return s.CreatedBy == userId || slices.Contains(s.Moderators, userId)
+}
+
+// registro de acciones de moderación
+type AuditEntry struct {
+	SphereID     string         `bson:"sphereId" json:"sphereId"`
+	ActorID      string         `bson:"actorId" json:"actorId"`
+	Action       string         `bson:"action" json:"action"`
+	TargetID     string         `bson:"targetId,omitempty" json:"targetId,omitempty"`
+	TargetUserID string         `bson:"targetUserId,omitempty" json:"targetUserId,omitempty"`
+	Reason       string         `bson:"reason,omitempty" json:"reason,omitempty"`
+	Meta         map[string]any `bson:"meta,omitempty" json:"meta,omitempty"`
+	CreatedAt    time.Time      `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,232 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"moonmap.io/go-commons/constants"
+	"moonmap.io/go-commons/system"
+	"moonmap.io/spheres-service/models"
+	"moonmap.io/spheres-service/search"
+)
+
+const (
+	workerConsumer = "spheres-notifications"
+	// tope de @menciones que generan notificación por post
+	maxMentions  = 10
+	previewRunes = 140
+)
+
+// Worker arma las notificaciones por usuario a partir de los eventos de spheres.
+type Worker struct {
+	Contents   *mongo.Collection
+	Wallets    *mongo.Collection
+	Inbox      *mongo.Collection
+	Prefs      *mongo.Collection
+	EventStore *system.NatsEventStore
+}
+
+// Start crea el consumer durable (compartido entre réplicas).
+func (wk *Worker) Start(ctx context.Context) {
+	wk.EventStore.CreateConsumer(constants.StreamSpheres, workerConsumer, []string{
+		"spheres.content.added.*",
+		"spheres.content.reacted.*",
+	}, func(msg jetstream.Msg) error {
+		return wk.handle(ctx, msg)
+	})
+}
+
+func (wk *Worker) handle(ctx context.Context, msg jetstream.Msg) error {
+	// spheres.content.<action>.<sphereId>
+	toks := strings.Split(msg.Subject(), ".")
+	if len(toks) < 4 {
+		return nil
+	}
+
+	var evt struct {
+		ID     string `json:"_id"`
+		UserID string `json:"userId"`
+		Symbol string `json:"symbol"`
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal(msg.Data(), &evt); err != nil || evt.ID == "" {
+		logrus.WithError(err).Warnf("notifications: bad payload on %s", msg.Subject())
+		return nil
+	}
+
+	switch toks[2] {
+	case "added":
+		return wk.contentAdded(ctx, evt.ID)
+	case "reacted":
+		if evt.Action != "added" {
+			return nil
+		}
+		actor, err := bson.ObjectIDFromHex(evt.UserID)
+		if err != nil {
+			return nil
+		}
+		return wk.reacted(ctx, evt.ID, actor, evt.Symbol)
+	}
+	return nil
+}
+
+// el evento no trae siempre el userId plano, se relee el contenido
+func (wk *Worker) content(ctx context.Context, id string) (*models.SphereContent, error) {
+	oid, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+	var c struct {
+		models.SphereContent `bson:",inline"`
+		Hidden               bool `bson:"hidden"`
+	}
+	err = wk.Contents.FindOne(ctx, bson.M{"_id": oid}).Decode(&c)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && (c.Deleted || c.Hidden || c.MediaPending)) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c.SphereContent, nil
+}
+
+func (wk *Worker) contentAdded(ctx context.Context, id string) error {
+	c, err := wk.content(ctx, id)
+	if err != nil || c == nil {
+		return err
+	}
+
+	base := models.Notification{
+		SphereID:  c.SphereID,
+		ContentID: c.ID,
+		ParentID:  c.ParentID,
+		ActorID:   c.UserID,
+		Preview:   preview(c.Text),
+	}
+	notified := map[bson.ObjectID]bool{c.UserID: true}
+
+	// respuesta: al autor del post padre
+	if c.ParentID != nil {
+		var parent models.SphereContent
+		err := wk.Contents.FindOne(ctx, bson.M{"_id": *c.ParentID, "deleted": false}).Decode(&parent)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+		if err == nil && !notified[parent.UserID] {
+			n := base
+			n.UserID, n.Type = parent.UserID, models.NotificationReply
+			n.Key = "reply:" + c.ID.Hex() + ":" + parent.UserID.Hex()
+			if err := wk.deliver(ctx, &n); err != nil {
+				return err
+			}
+			notified[parent.UserID] = true
+		}
+	}
+
+	// @menciones (quien ya recibió la respuesta no recibe también la mención)
+	users, err := wk.resolveMentions(ctx, search.Mentions(c.Text))
+	if err != nil {
+		return err
+	}
+	for _, uid := range users {
+		if notified[uid] {
+			continue
+		}
+		n := base
+		n.UserID, n.Type = uid, models.NotificationMention
+		n.Key = "mention:" + c.ID.Hex() + ":" + uid.Hex()
+		if err := wk.deliver(ctx, &n); err != nil {
+			return err
+		}
+		notified[uid] = true
+	}
+	return nil
+}
+
+func (wk *Worker) reacted(ctx context.Context, id string, actor bson.ObjectID, symbol string) error {
+	c, err := wk.content(ctx, id)
+	if err != nil || c == nil || c.UserID == actor {
+		return err
+	}
+	n := models.Notification{
+		UserID:    c.UserID,
+		Type:      models.NotificationReaction,
+		SphereID:  c.SphereID,
+		ContentID: c.ID,
+		ParentID:  c.ParentID,
+		ActorID:   actor,
+		Symbol:    symbol,
+		Preview:   preview(c.Text),
+		// sacar y volver a poner la reacción no vuelve a notificar
+		Key: "reaction:" + c.ID.Hex() + ":" + actor.Hex() + ":" + symbol,
+	}
+	return wk.deliver(ctx, &n)
+}
+
+// resolveMentions: solo @<userId hex>. wallets no tiene un handle único y fullName puede
+// tener espacios (el regex de menciones no los captura), así que un @nombre no notifica.
+func (wk *Worker) resolveMentions(ctx context.Context, handles []string) ([]bson.ObjectID, error) {
+	if len(handles) > maxMentions {
+		handles = handles[:maxMentions]
+	}
+	var out []bson.ObjectID
+	for _, h := range handles {
+		oid, err := bson.ObjectIDFromHex(h)
+		if err != nil || slices.Contains(out, oid) {
+			continue
+		}
+		err = wk.Wallets.FindOne(ctx, bson.M{"_id": oid}, options.FindOne().SetProjection(bson.M{"_id": 1})).Err()
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, oid)
+	}
+	return out, nil
+}
+
+// deliver respeta los mutes, guarda en el inbox y publica notify.user.<userId>.
+func (wk *Worker) deliver(ctx context.Context, n *models.Notification) error {
+	var prefs models.NotificationPrefs
+	err := wk.Prefs.FindOne(ctx, bson.M{"_id": n.UserID}).Decode(&prefs)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	if err == nil && prefs.Mutes(n) {
+		return nil
+	}
+
+	n.ID = bson.NewObjectID()
+	n.CreatedAt = time.Now().UTC()
+	if _, err := wk.Inbox.InsertOne(ctx, n); err != nil {
+		// ya entregada (redelivery del mismo evento)
+		if mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		return err
+	}
+
+	subject := constants.StreamNotify + ".user." + n.UserID.Hex()
+	payload := map[string]any{"kind": "notification", "notification": n}
+	_ = wk.EventStore.PublishJSON(constants.StreamNotify, subject, n.ID.Hex(), payload, nil)
+	return nil
+}
+
+func preview(text string) string {
+	r := []rune(strings.TrimSpace(text))
+	if len(r) <= previewRunes {
+		return string(r)
+	}
+	return string(r[:previewRunes]) + "…"
+}
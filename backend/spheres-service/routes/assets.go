@@ -0,0 +1,79 @@
+package routes
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"moonmap.io/spheres-service/models"
+)
+
+// loadAssets trae los assets por id (los que falten no vienen en el mapa).
+func loadAssets(ctx context.Context, assets *mongo.Collection, ids []bson.ObjectID) (map[bson.ObjectID]*models.MediaAsset, error) {
+	out := make(map[bson.ObjectID]*models.MediaAsset, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	opts := options.Find().SetProjection(bson.M{
+		"key": 1, "namespace": 1, "scopeId": 1, "profile": 1, "uploaderId": 1,
+		"mediaType": 1, "status": 1, "urls": 1, "width": 1, "height": 1,
+	})
+	cur, err := assets.Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var found []models.MediaAsset
+	if err := cur.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	for i := range found {
+		out[found[i].ID] = &found[i]
+	}
+	return out, nil
+}
+
+// mediaViews arma la lista de media de un post en el orden de mediaIds
+func mediaViews(ids []bson.ObjectID, assets map[bson.ObjectID]*models.MediaAsset) []*models.MediaAsset {
+	out := make([]*models.MediaAsset, 0, len(ids))
+	for _, id := range ids {
+		if a, ok := assets[id]; ok {
+			out = append(out, a.View())
+		}
+	}
+	return out
+}
+
+// attachMedia resuelve mediaIds -> media (urls de variantes ready) en resultados de aggregate.
+func attachMedia(ctx context.Context, assets *mongo.Collection, groups ...[]bson.M) error {
+	ids := []bson.ObjectID{}
+	for _, items := range groups {
+		for _, it := range items {
+			ids = append(ids, itemMediaIDs(it)...)
+		}
+	}
+	found, err := loadAssets(ctx, assets, ids)
+	if err != nil {
+		return err
+	}
+	for _, items := range groups {
+		for _, it := range items {
+			it["media"] = mediaViews(itemMediaIDs(it), found)
+			delete(it, "mediaIds")
+		}
+	}
+	return nil
+}
+
+func itemMediaIDs(it bson.M) []bson.ObjectID {
+	raw, _ := it["mediaIds"].(bson.A)
+	ids := make([]bson.ObjectID, 0, len(raw))
+	for _, v := range raw {
+		if id, ok := v.(bson.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"moonmap.io/go-commons/ownhttp"
+	"moonmap.io/spheres-service/models"
+)
+
+// rol de plataforma que puede moderar cualquier sphere
+const adminRole = "admin"
+
+// principalUser devuelve el usuario autenticado (puesto por ownhttp.WithAuth).
+func principalUser(w http.ResponseWriter, r *http.Request) (*ownhttp.Principal, bson.ObjectID, bool) {
+	p := ownhttp.PrincipalFrom(r.Context())
+	if p.IsAnonymous() {
+		ownhttp.WriteJSONError(w, 401, "UNAUTHORIZED", "authentication required")
+		return nil, bson.NilObjectID, false
+	}
+	uid, err := bson.ObjectIDFromHex(p.UserID)
+	if err != nil {
+		ownhttp.WriteJSONError(w, 401, "UNAUTHORIZED", "invalid subject")
+		return nil, bson.NilObjectID, false
+	}
+	return p, uid, true
+}
+
+func findSphere(ctx context.Context, spheres *mongo.Collection, sphereId string) (*models.Sphere, error) {
+	var sphere models.Sphere
+	if err := spheres.FindOne(ctx, bson.M{"_id": sphereId}).Decode(&sphere); err != nil {
+		return nil, err
+	}
+	return &sphere, nil
+}
+
+func canModerate(sphere *models.Sphere, p *ownhttp.Principal) bool {
+	return p.HasRole(adminRole) || sphere.IsModerator(p.UserID)
+}
+
+// findContentInSphere carga el contenido y verifica que pertenezca a la sphere del path.
+func findContentInSphere(w http.ResponseWriter, r *http.Request, contents *mongo.Collection, sphereId string, contentId bson.ObjectID) (*models.SphereContent, bool) {
+	var content models.SphereContent
+	err := contents.FindOne(r.Context(), bson.M{"_id": contentId}).Decode(&content)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "content not found")
+		return nil, false
+	}
+	if err != nil {
+		ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+		return nil, false
+	}
+	if content.SphereID != sphereId {
+		ownhttp.WriteJSONError(w, 400, "SPHERE_MISMATCH", "content does not belong to sphere")
+		return nil, false
+	}
+	return &content, true
+}
+
+func writeAudit(ctx context.Context, audit *mongo.Collection, entry models.AuditEntry) {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	if _, err := audit.InsertOne(ctx, entry); err != nil {
+		logrus.WithError(err).Errorf("sphere %s: failed to write audit entry %s", entry.SphereID, entry.Action)
+	}
+}
@@ -1,10 +1,12 @@
 package routes
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
+	"slices"
 	"strings"
 	"time"
 
@@ -12,9 +14,11 @@ import (
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 	"moonmap.io/go-commons/constants"
 	"moonmap.io/go-commons/ownhttp"
 	"moonmap.io/go-commons/system"
+	"moonmap.io/spheres-service/filter"
 	"moonmap.io/spheres-service/models"
 )
 
@@ -42,85 +46,135 @@ var endProjection = bson.D{
 	{Key: "type", Value: 1},
 	{Key: "text", Value: 1},
 	{Key: "mediaUrls", Value: 1},
-	{Key: "reactions", Value: 1},
-	// {
-	// 	Key: "reactions", Value: bson.D{
-	// 		{Key: "$arrayToObject", Value: bson.D{
-	// 			{Key: "$map", Value: bson.D{
-	// 				{Key: "input", Value: bson.D{{Key: "$objectToArray", Value: "$reactions"}}},
-	// 				{Key: "as", Value: "r"},
-	// 				{Key: "in", Value: bson.D{
-	// 					{Key: "k", Value: "$$r.k"},
-	// 					{Key: "v", Value: bson.D{{Key: "$size", Value: "$$r.v"}}},
-	// 				}},
-	// 			}},
-	// 		}},
-	// 	},
-	// },
+	// se resuelven a media (urls de variantes ready) al leer
+	{Key: "mediaIds", Value: 1},
+	{Key: "mediaPending", Value: 1},
+	// solo los contadores; quién reaccionó va por GET .../reactions
+	{Key: "reactionCounts", Value: 1},
+	{Key: "poll", Value: 1},
+	{Key: "pinnedAt", Value: 1},
 	{Key: "parentId", Value: 1},
 	{Key: "createdAt", Value: 1},
 	{Key: "updatedAt", Value: 1},
 	{Key: "deleted", Value: 1},
 	{Key: "user", Value: 1},
+	{Key: "replyCount", Value: 1},
+	{Key: "lastReplyAt", Value: 1},
+	{Key: "editCount", Value: 1},
+	{Key: "editedAt", Value: 1},
+	{Key: "tombstone", Value: 1},
 }
 
-// 1. Posts raíz con preview de replies
-// GET /spheres/{sphereId}/contents?limit=20&after=hexId
-func GetSpherePosts(collection *mongo.Collection) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			ownhttp.WriteJSONError(w, 405, "NOT_ALLOWED", "method")
-			return
-		}
+// cantidad de replies visibles y fecha de la última, por post
+var replyStatsStages = mongo.Pipeline{
+	{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: constants.SphereContentsCollectionName},
+		{Key: "let", Value: bson.D{{Key: "pid", Value: "$_id"}}},
+		{Key: "pipeline", Value: mongo.Pipeline{
+			{{Key: "$match", Value: bson.D{
+				{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$parentId", "$$pid"}}}},
+				{Key: "deleted", Value: false},
+				{Key: "hidden", Value: bson.D{{Key: "$ne", Value: true}}},
+				mediaSettled,
+			}}},
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: nil},
+				{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+				{Key: "lastReplyAt", Value: bson.D{{Key: "$max", Value: "$createdAt"}}},
+			}}},
+		}},
+		{Key: "as", Value: "replyStats"},
+	}}},
+	{{Key: "$set", Value: bson.D{
+		{Key: "replyCount", Value: bson.D{{Key: "$ifNull", Value: bson.A{bson.D{{Key: "$first", Value: "$replyStats.count"}}, 0}}}},
+		{Key: "lastReplyAt", Value: bson.D{{Key: "$first", Value: "$replyStats.lastReplyAt"}}},
+	}}},
+}
 
-		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/spheres/"), "/")
-		if len(parts) < 2 || parts[1] != "contents" {
-			ownhttp.WriteJSONError(w, 400, "BAD_PATH", "invalid path")
-			return
-		}
-		sphereId := parts[0]
+// posts con media todavía en el pipeline no existen para los lectores
+var mediaSettled = bson.E{Key: "mediaPending", Value: bson.D{{Key: "$ne", Value: true}}}
+
+// borrado por el autor u oculto por moderación
+var removedExpr = bson.D{{Key: "$or", Value: bson.A{"$deleted", bson.D{{Key: "$eq", Value: bson.A{"$hidden", true}}}}}}
+
+// tombstone: se mantiene el lugar en el hilo pero sin contenido
+var tombstoneStage = bson.D{{Key: "$set", Value: bson.D{
+	{Key: "tombstone", Value: removedExpr},
+	{Key: "text", Value: bson.D{{Key: "$cond", Value: bson.A{removedExpr, "", "$text"}}}},
+	{Key: "mediaUrls", Value: bson.D{{Key: "$cond", Value: bson.A{removedExpr, bson.A{}, "$mediaUrls"}}}},
+	{Key: "mediaIds", Value: bson.D{{Key: "$cond", Value: bson.A{removedExpr, bson.A{}, "$mediaIds"}}}},
+	{Key: "reactionCounts", Value: bson.D{{Key: "$cond", Value: bson.A{removedExpr, bson.D{{Key: "$literal", Value: bson.D{}}}, "$reactionCounts"}}}},
+	{Key: "poll", Value: bson.D{{Key: "$cond", Value: bson.A{removedExpr, "$$REMOVE", "$poll"}}}},
+}}}
+
+// threadPipeline: página de contenidos (posts o replies) con stats, autor y tombstones.
+// Se pagina antes de los $lookup: las stats de replies se calculan solo para la página.
+func threadPipeline(match bson.D, p page) mongo.Pipeline {
+	match = append(match, mediaSettled)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: p.apply(match)}},
+		{{Key: "$sort", Value: p.sort()}},
+		{{Key: "$limit", Value: p.fetch()}},
+	}
+	pipeline = append(pipeline, replyStatsStages...)
+	return append(pipeline,
+		bson.D{{Key: "$lookup", Value: usersLookup}},
+		bson.D{{Key: "$unwind", Value: bson.D{
+			{Key: "path", Value: "$user"},
+			{Key: "preserveNullAndEmptyArrays", Value: true},
+		}}},
+		tombstoneStage,
+		bson.D{{Key: "$project", Value: endProjection}},
+	)
+}
 
-		limit := int64(10)
-		if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 10 {
-			limit = int64(l)
-		}
+// dropEmptyTombstones saca los borrados u ocultos sin replies; los que tienen replies
+// quedan como tombstone. Va después de page.finish para que los cursores sigan
+// avanzando aunque la página quede más corta.
+func dropEmptyTombstones(items []bson.M) []bson.M {
+	return slices.DeleteFunc(items, func(it bson.M) bool {
+		gone, _ := it["tombstone"].(bool)
+		return gone && !hasReplies(it["replyCount"])
+	})
+}
 
-		afterStr := r.URL.Query().Get("after")
-		var afterID bson.ObjectID
-		if afterStr != "" {
-			var err error
-			afterID, err = bson.ObjectIDFromHex(afterStr)
-			if err != nil {
-				ownhttp.WriteJSONError(w, 400, "BAD_AFTER", "invalid after id")
-				return
-			}
+func hasReplies(v any) bool {
+	switch n := v.(type) {
+	case int32:
+		return n > 0
+	case int64:
+		return n > 0
+	case float64:
+		return n > 0
+	}
+	return false
+}
+
+// 1. Posts raíz con stats de replies y preview
+// GET /spheres/{sphereId}/contents?limit=20&cursor=opaque&type=
+func GetSpherePosts(collection, assets *mongo.Collection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sphereId := r.PathValue("sphereId")
+
+		pg, err := parsePage(r, 20, 50)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_CURSOR", err.Error())
+			return
 		}
 
 		match := bson.D{
 			{Key: "sphereId", Value: sphereId},
-			{Key: "deleted", Value: false},
 			{Key: "parentId", Value: nil},
 		}
-		if afterStr != "" {
-			match = append(match, bson.E{
-				Key:   "_id",
-				Value: bson.D{{Key: "$lt", Value: afterID}},
-			})
-		}
-
-		parentsPipeline := mongo.Pipeline{
-			{{Key: "$match", Value: match}},
-			{{Key: "$sort", Value: bson.D{{Key: "_id", Value: -1}}}},
-			{{Key: "$limit", Value: limit}},
-			{{Key: "$lookup", Value: usersLookup}},
-			{{Key: "$unwind", Value: bson.D{
-				{Key: "path", Value: "$user"},
-				{Key: "preserveNullAndEmptyArrays", Value: true},
-			}}},
-			{{Key: "$project", Value: endProjection}},
+		if t := r.URL.Query().Get("type"); t != "" {
+			if !models.IsContentType(t) {
+				ownhttp.WriteJSONError(w, 400, "BAD_TYPE", "type must be text, announcement or poll")
+				return
+			}
+			match = append(match, bson.E{Key: "type", Value: t})
 		}
 
-		cur, err := collection.Aggregate(r.Context(), parentsPipeline)
+		cur, err := collection.Aggregate(r.Context(), threadPipeline(match, pg))
 		if err != nil {
 			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
 			return
@@ -133,10 +187,14 @@ func GetSpherePosts(collection *mongo.Collection) http.HandlerFunc {
 			return
 		}
 
+		parents, nextCursor, prevCursor := pg.finish(parents)
+		parents = dropEmptyTombstones(parents)
 		if len(parents) == 0 {
 			ownhttp.WriteJSON(w, 200, bson.M{
-				"parents":   []bson.M{},
-				"childrens": bson.M{},
+				"parents":    []bson.M{},
+				"childrens":  bson.M{},
+				"nextCursor": nextCursor,
+				"prevCursor": prevCursor,
 			})
 			return
 		}
@@ -152,6 +210,8 @@ func GetSpherePosts(collection *mongo.Collection) http.HandlerFunc {
 			{{Key: "$match", Value: bson.D{
 				{Key: "sphereId", Value: sphereId},
 				{Key: "deleted", Value: false},
+				{Key: "hidden", Value: bson.D{{Key: "$ne", Value: true}}},
+				mediaSettled,
 				{Key: "parentId", Value: bson.D{{Key: "$in", Value: parentIDs}}},
 			}}},
 			{{Key: "$sort", Value: bson.D{{Key: "_id", Value: -1}}}},
@@ -189,6 +249,16 @@ func GetSpherePosts(collection *mongo.Collection) http.HandlerFunc {
 			return
 		}
 
+		// un solo lookup de media para posts y previews de replies
+		groups := [][]bson.M{parents}
+		for _, g := range groupedRows {
+			groups = append(groups, g.Children)
+		}
+		if err := attachMedia(r.Context(), assets, groups...); err != nil {
+			ownhttp.WriteJSONError(w, 500, "MEDIA_FAIL", err.Error())
+			return
+		}
+
 		childrens := bson.M{}
 		for _, g := range groupedRows {
 			switch v := g.ParentID.(type) {
@@ -202,71 +272,38 @@ func GetSpherePosts(collection *mongo.Collection) http.HandlerFunc {
 		}
 
 		ownhttp.WriteJSON(w, 200, bson.M{
-			"parents":   parents,
-			"childrens": childrens,
+			"parents":    parents,
+			"childrens":  childrens,
+			"nextCursor": nextCursor,
+			"prevCursor": prevCursor,
 		})
 
 	}
 }
 
 // 2. Replies of a specific post
-// /spheres/{sphereId}/contents/{contentId}/replies?limit=20&after=hexId
-func GetSphereReplies(collection *mongo.Collection) http.HandlerFunc {
+// GET /spheres/{sphereId}/contents/{contentId}/replies?limit=20&cursor=opaque
+func GetSphereReplies(collection, assets *mongo.Collection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			ownhttp.WriteJSONError(w, 405, "NOT_ALLOWED", "method")
-			return
-		}
-
-		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/spheres/"), "/")
-		if len(parts) < 4 || parts[1] != "contents" || parts[2] == "" || parts[3] != "replies" {
-			ownhttp.WriteJSONError(w, 400, "BAD_PATH", "invalid path")
-			return
-		}
-		sphereId := parts[0]
-		parentId, err := bson.ObjectIDFromHex(parts[2]) // contentId of parent post
+		sphereId := r.PathValue("sphereId")
+		parentId, err := bson.ObjectIDFromHex(r.PathValue("contentId")) // contentId of parent post
 		if err != nil {
 			ownhttp.WriteJSONError(w, 400, "BAD_PARENT_ID", "invalid parent id")
 			return
 		}
 
-		limit := int64(20)
-		if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
-			limit = int64(l)
-		}
-
-		afterStr := r.URL.Query().Get("after")
-		var afterID bson.ObjectID
-		if afterStr != "" {
-			var err error
-			afterID, err = bson.ObjectIDFromHex(afterStr)
-			if err != nil {
-				ownhttp.WriteJSONError(w, 400, "BAD_AFTER", "invalid after id")
-				return
-			}
+		pg, err := parsePage(r, 20, 100)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_CURSOR", err.Error())
+			return
 		}
 
 		match := bson.D{
 			{Key: "sphereId", Value: sphereId},
-			{Key: "deleted", Value: false},
 			{Key: "parentId", Value: parentId},
 		}
-		if afterStr != "" {
-			match = append(match, bson.E{Key: "_id", Value: bson.D{{Key: "$lt", Value: afterID}}})
-		}
-
-		pipeline := mongo.Pipeline{
-			{{Key: "$match", Value: match}},
-			{{Key: "$sort", Value: bson.D{{Key: "_id", Value: -1}}}},
-			{{Key: "$limit", Value: limit}},
-			{{Key: "$lookup", Value: usersLookup}},
-			{{Key: "$unwind", Value: bson.D{
-				{Key: "path", Value: "$user"},
-				{Key: "preserveNullAndEmptyArrays", Value: true},
-			}}},
-		}
 
-		cur, err := collection.Aggregate(r.Context(), pipeline)
+		cur, err := collection.Aggregate(r.Context(), threadPipeline(match, pg))
 		if err != nil {
 			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
 			return
@@ -279,7 +316,17 @@ func GetSphereReplies(collection *mongo.Collection) http.HandlerFunc {
 			return
 		}
 
-		ownhttp.WriteJSON(w, 200, docs)
+		docs, nextCursor, prevCursor := pg.finish(docs)
+		docs = dropEmptyTombstones(docs)
+		if err := attachMedia(r.Context(), assets, docs); err != nil {
+			ownhttp.WriteJSONError(w, 500, "MEDIA_FAIL", err.Error())
+			return
+		}
+		ownhttp.WriteJSON(w, 200, bson.M{
+			"items":      docs,
+			"nextCursor": nextCursor,
+			"prevCursor": prevCursor,
+		})
 	}
 }
 
@@ -291,41 +338,81 @@ func GetSphereReplies(collection *mongo.Collection) http.HandlerFunc {
 //	  "type": "text",
 //	  "text": "mensaje",
 //	  "mediaUrls": [],
+//	  "media": [{"mediaId": "...", "status": "ready", "urls": {"720": "...", "1080": "..."}}],
+//	  "mediaPending": false,
 //	  "createdAt": "2025-09-10T12:00:00Z",
 //	  "updatedAt": "2025-09-10T12:00:00Z",
 //	  "deleted": false
 //	}
 //
 // POST /spheres/{sphereId}/contents
-func CreateSphereContent(collection *mongo.Collection, mediaCollection *mongo.Collection, eventStore *system.NatsEventStore) http.HandlerFunc {
+func CreateSphereContent(md *Media, mod *Moderation, filters *filter.Pipeline) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		if r.Method != http.MethodPost {
-			ownhttp.WriteJSONError(w, 405, "NOT_ALLOWED", "method")
+		idHex := r.PathValue("sphereId")
+		sid := idHex // mintId - sphereId
+
+		_, userId, ok := principalUser(w, r)
+		if !ok {
+			return
+		}
+		if !enforceSanctions(w, r, mod.Sanctions, sid, userId) {
 			return
 		}
 
-		idHex := strings.TrimPrefix(r.URL.Path, "/spheres/")
-		idHex = strings.TrimSuffix(idHex, "/contents")
-		sid := idHex // mintId - sphereId
+		sphere, err := findSphere(r.Context(), md.Spheres, sid)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "sphere not found")
+			return
+		}
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		if !sphere.CanPost(userId.Hex()) {
+			ownhttp.WriteJSONError(w, 403, "READ_ONLY", "only moderators can post in this sphere")
+			return
+		}
 
 		var req struct {
-			UserID   string   `json:"userId"`
 			ParentID *string  `json:"parentId"`
 			Type     string   `json:"type"`
 			Text     string   `json:"text"`
 			MediaIDs []string `json:"mediaIds"`
+			Poll     *pollReq `json:"poll"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			ownhttp.WriteJSONError(w, 400, "BAD_REQUEST", "invalid json")
 			return
 		}
 
-		userId, err := bson.ObjectIDFromHex(req.UserID)
-		if err != nil {
-			ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid objectid userId")
+		if req.Type == "" {
+			req.Type = models.ContentText
+		}
+		if !models.IsContentType(req.Type) {
+			ownhttp.WriteJSONError(w, 400, "BAD_TYPE", "type must be text, announcement or poll")
 			return
 		}
+		if req.Type != models.ContentText && req.ParentID != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_TYPE", "replies can only be text")
+			return
+		}
+		if req.Type == models.ContentAnnouncement && !sphere.IsModerator(userId.Hex()) {
+			ownhttp.WriteJSONError(w, 403, "FORBIDDEN", "only moderators can post announcements")
+			return
+		}
+		var poll *models.Poll
+		if req.Type == models.ContentPoll {
+			var code, msg string
+			if poll, code, msg = req.Poll.build(time.Now()); poll == nil {
+				ownhttp.WriteJSONError(w, 400, code, msg)
+				return
+			}
+			if strings.TrimSpace(req.Text) == "" {
+				ownhttp.WriteJSONError(w, 400, "BAD_POLL", "the poll question goes in text")
+				return
+			}
+		}
 
 		var parentId *bson.ObjectID
 		if req.ParentID != nil {
@@ -334,119 +421,102 @@ func CreateSphereContent(collection *mongo.Collection, mediaCollection *mongo.Co
 				ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid objectid for parentId")
 				return
 			}
-			parentId = &oid
-		}
-
-		mediaOids := make([]bson.ObjectID, 0, len(req.MediaIDs))
-		for _, mid := range req.MediaIDs {
-			oid, err := bson.ObjectIDFromHex(mid)
+			// los hilos son de un nivel: solo se responde a posts raíz visibles de la misma esfera
+			var parent models.SphereContent
+			err = md.Contents.FindOne(r.Context(), bson.M{"_id": oid}).Decode(&parent)
+			if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && (parent.Deleted || parent.Hidden || parent.MediaPending)) {
+				ownhttp.WriteJSONError(w, 404, "PARENT_NOT_FOUND", "parent content not found")
+				return
+			}
 			if err != nil {
-				ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid objectid for mediaId")
+				ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
 				return
 			}
-			mediaOids = append(mediaOids, oid)
+			if parent.SphereID != sid {
+				ownhttp.WriteJSONError(w, 400, "SPHERE_MISMATCH", "parent content does not belong to sphere")
+				return
+			}
+			if parent.ParentID != nil {
+				ownhttp.WriteJSONError(w, 400, "NESTED_REPLY", "replies can only target top-level posts")
+				return
+			}
+			parentId = &oid
 		}
 
-		cur, err := mediaCollection.Find(r.Context(), bson.M{
-			"_id":    bson.M{"$in": mediaOids},
-			"status": "uploaded",
-		})
-		if err != nil {
-			ownhttp.WriteJSONError(w, 500, "DB_ERROR", err.Error())
-			return
+		// filtros de spam/scam antes de insertar (mismo input que en la edición)
+		in := &filter.Input{
+			SphereID: sid,
+			UserID:   userId.Hex(),
+			Text:     req.Text,
 		}
-		defer cur.Close(r.Context())
-
-		type mediaDoc struct {
-			ID  bson.ObjectID `bson:"_id"`
-			Key string        `bson:"key"`
+		if parentId != nil {
+			in.ParentID = parentId.Hex()
 		}
-		var found []mediaDoc
-		if err := cur.All(r.Context(), &found); err != nil {
-			ownhttp.WriteJSONError(w, 500, "CURSOR_FAIL", err.Error())
+		verdict := filters.Evaluate(r.Context(), in)
+		if verdict.Outcome == filter.Reject {
+			ownhttp.WriteJSONError(w, 422, "CONTENT_REJECTED", verdict.Hits[len(verdict.Hits)-1].Reason)
 			return
 		}
 
-		if len(found) != len(mediaOids) {
-			ownhttp.WriteJSONError(w, 400, "MEDIA_INVALID", "some media not uploaded or expired")
+		mediaIds, mediaPending, ok := checkPostMedia(w, r, md.Assets, sid, userId, req.MediaIDs)
+		if !ok {
 			return
 		}
 
-		mediaUrls := make([]string, 0, len(found))
-		for _, m := range found {
-			publicUrl := fmt.Sprintf("https://%s/%s", "s3.moonmap.io", m.Key) // ajusta host base
-			mediaUrls = append(mediaUrls, publicUrl)
-		}
-
 		now := time.Now()
 		doc := bson.M{
-			"sphereId":  sid,
-			"userId":    userId,
-			"type":      req.Type,
-			"text":      req.Text,
-			"mediaUrls": mediaUrls,
-			"reactions": bson.M{},
-			"createdAt": now,
-			"updatedAt": now,
-			"deleted":   false,
-			"parentId":  parentId,
-		}
-
-		inserted, err := collection.InsertOne(r.Context(), doc)
+			"sphereId":       sid,
+			"userId":         userId,
+			"type":           req.Type,
+			"text":           req.Text,
+			"mediaUrls":      []string{},
+			"mediaIds":       mediaIds,
+			"reactionCounts": bson.M{},
+			"createdAt":      now,
+			"updatedAt":      now,
+			"deleted":        false,
+			"parentId":       parentId,
+		}
+		if poll != nil {
+			doc["poll"] = poll
+		}
+		// no se ve hasta que el pipeline termine sus variantes
+		if mediaPending {
+			doc["mediaPending"] = true
+		}
+		if verdict.Outcome == filter.Flag {
+			doc["flagged"] = true
+			doc["flags"] = verdict.Hits
+		}
+
+		inserted, err := md.Contents.InsertOne(r.Context(), doc)
 		if err != nil {
 			ownhttp.WriteJSONError(w, 500, "INSERT_FAIL", err.Error())
 			return
 		}
 		oid := inserted.InsertedID.(bson.ObjectID)
 
-		if len(mediaOids) > 0 {
-			// mark all as attached
-			_, err = mediaCollection.UpdateMany(r.Context(),
-				bson.M{"_id": bson.M{"$in": mediaOids}},
-				bson.M{"$set": bson.M{"status": "attached", "updatedAt": time.Now()}},
-			)
-			if err != nil {
-				logrus.Warnf("failed to update media to attached: %v", err)
-			}
+		if verdict.Outcome == filter.Flag {
+			flagForReview(r.Context(), mod, sid, oid, verdict)
 		}
 
-		pipeline := mongo.Pipeline{
-			{{Key: "$match", Value: bson.D{
-				{Key: "_id", Value: oid},
-				{Key: "deleted", Value: false},
-			}}},
-			{{Key: "$lookup", Value: usersLookup}},
-			{{Key: "$unwind", Value: bson.D{
-				{Key: "path", Value: "$user"},
-				{Key: "preserveNullAndEmptyArrays", Value: true},
-			}}},
-			{{Key: "$project", Value: endProjection}},
+		if mediaPending {
+			// el media pudo quedar ready entre la validación y el insert
+			if err := md.settleContent(r.Context(), oid); err != nil {
+				logrus.WithError(err).Warnf("content %s: failed to settle media", oid.Hex())
+			}
 		}
 
-		cur, err = collection.Aggregate(r.Context(), pipeline)
+		insertedWithUser, err := md.contentView(r.Context(), oid)
 		if err != nil {
-			ownhttp.WriteJSONError(w, 500, "INSERT_FAIL", err.Error())
+			ownhttp.WriteJSONError(w, 500, "NOT_INSERTED", err.Error())
 			return
 		}
-		defer cur.Close(r.Context())
-
-		docs := []models.SphereContentCreated{}
-		if err := cur.All(r.Context(), &docs); err != nil {
-			ownhttp.WriteJSONError(w, 500, "CURSOR_FAILED", err.Error())
-			return
+		if !mediaPending {
+			// los anuncios flaggeados esperan revisión
+			md.publishAdded(r.Context(), insertedWithUser, req.Type == models.ContentAnnouncement && verdict.Outcome != filter.Flag)
 		}
 
-		if len(docs) == 0 {
-			ownhttp.WriteJSONError(w, 404, "NOT_INSERTED", "not found after insert")
-			return
-		}
-
-		insertedWithUser := docs[0]
-
-		messageId := ksuid.New()
-		subject := "spheres.content.added." + idHex
-		_ = eventStore.PublishJSON(constants.StreamSpheres, subject, messageId.String(), insertedWithUser, nil)
-
 		ownhttp.WriteJSON(w, 201, insertedWithUser)
 
 	}
@@ -460,43 +530,132 @@ func CreateSphereContent(collection *mongo.Collection, mediaCollection *mongo.Co
 //	}
 //
 // PATCH /spheres/{sphereId}/contents/{contentId}
-func UpdateSphereContent(collection *mongo.Collection, eventStore *system.NatsEventStore) http.HandlerFunc {
+func UpdateSphereContent(md *Media, mod *Moderation, filters *filter.Pipeline, editsCollection *mongo.Collection) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPatch {
-			ownhttp.WriteJSONError(w, 405, "NOT_ALLOWED", "method")
+		_, userId, ok := principalUser(w, r)
+		if !ok {
+			return
+		}
+
+		sphereIdHex := r.PathValue("sphereId")
+		contentId, err := bson.ObjectIDFromHex(r.PathValue("contentId"))
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_CONTENT_ID", "invalid content id")
 			return
 		}
 
-		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/spheres/"), "/")
-		if len(parts) < 3 || parts[1] != "contents" {
-			ownhttp.WriteJSONError(w, 400, "BAD_PATH", "invalid path")
+		content, ok := findContentInSphere(w, r, md.Contents, sphereIdHex, contentId)
+		if !ok {
+			return
+		}
+		// solo el autor edita
+		if content.UserID != userId {
+			ownhttp.WriteJSONError(w, 403, "FORBIDDEN", "only the author can edit this content")
 			return
 		}
-		sphereIdHex := parts[0]
-		contentId, _ := bson.ObjectIDFromHex(parts[2])
 
 		var req struct {
-			Text      *string   `json:"text"`
-			MediaUrls *[]string `json:"mediaUrls"`
+			Text     *string   `json:"text"`
+			MediaIDs *[]string `json:"mediaIds"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_JSON", "invalid json body")
+			return
+		}
+		if req.Text == nil && req.MediaIDs == nil {
+			ownhttp.WriteJSONError(w, 400, "NO_CHANGES", "nothing to update")
+			return
+		}
+
+		// mismos filtros que al crear: editar no sirve para colar un link después
+		var verdict filter.Result
+		if req.Text != nil {
+			var parentId string
+			if content.ParentID != nil {
+				parentId = content.ParentID.Hex()
+			}
+			verdict = filters.Evaluate(r.Context(), &filter.Input{
+				SphereID: sphereIdHex,
+				UserID:   userId.Hex(),
+				ParentID: parentId,
+				Text:     *req.Text,
+				Edit:     true,
+			})
+			if verdict.Outcome == filter.Reject {
+				ownhttp.WriteJSONError(w, 422, "CONTENT_REJECTED", verdict.Hits[len(verdict.Hits)-1].Reason)
+				return
+			}
 		}
-		_ = json.NewDecoder(r.Body).Decode(&req)
 
 		now := time.Now()
 		updates := make(map[string]interface{})
 		updates["updatedAt"] = now
+		updates["editedAt"] = now
 		if req.Text != nil {
 			updates["text"] = *req.Text
 		}
-		if req.MediaUrls != nil {
-			updates["mediaUrls"] = *req.MediaUrls
+		set := bson.M{}
+		if verdict.Outcome == filter.Flag {
+			set["flagged"] = true
+			set["flags"] = verdict.Hits
+		}
+		var mediaIds []bson.ObjectID
+		if req.MediaIDs != nil {
+			var pending bool
+			if mediaIds, pending, ok = checkPostMedia(w, r, md.Assets, sphereIdHex, userId, *req.MediaIDs); !ok {
+				return
+			}
+			// una edición no vuelve invisible un post publicado
+			if pending {
+				ownhttp.WriteJSONError(w, 409, "MEDIA_NOT_READY", "wait until media processing finishes")
+				return
+			}
+			updates["mediaIds"] = mediaIds
+		}
+
+		// se guarda la versión anterior en el historial
+		var prev models.SphereContent
+		err = md.Contents.FindOneAndUpdate(r.Context(),
+			bson.M{"_id": contentId, "sphereId": sphereIdHex, "userId": userId, "deleted": false, "hidden": bson.M{"$ne": true}},
+			bson.M{"$set": mergeSet(updates, set), "$inc": bson.M{"editCount": 1}},
+			options.FindOneAndUpdate().SetReturnDocument(options.Before),
+		).Decode(&prev)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "content not found")
+			return
 		}
-
-		_, err := collection.UpdateByID(r.Context(), contentId, bson.M{"$set": updates})
 		if err != nil {
 			ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
 			return
 		}
 
+		edit := models.SphereContentEdit{
+			ContentID: contentId,
+			SphereID:  sphereIdHex,
+			Version:   prev.EditCount,
+			Text:      prev.Text,
+			MediaUrls: prev.MediaUrls,
+			MediaIDs:  prev.MediaIDs,
+			EditedAt:  now,
+		}
+		if _, err := editsCollection.InsertOne(r.Context(), edit); err != nil {
+			logrus.WithError(err).Warnf("content %s: failed to store edit history", contentId.Hex())
+		}
+
+		if verdict.Outcome == filter.Flag {
+			flagForReview(r.Context(), mod, sphereIdHex, contentId, verdict)
+		}
+
+		// el evento lleva el media ya resuelto
+		if req.MediaIDs != nil {
+			assets, err := loadAssets(r.Context(), md.Assets, mediaIds)
+			if err != nil {
+				logrus.WithError(err).Warnf("content %s: failed to resolve media", contentId.Hex())
+			}
+			delete(updates, "mediaIds")
+			updates["media"] = mediaViews(mediaIds, assets)
+		}
+
 		evt := models.SphereContentUpdated{
 			ID:        contentId.Hex(),
 			SphereID:  sphereIdHex,
@@ -506,12 +665,78 @@ func UpdateSphereContent(collection *mongo.Collection, eventStore *system.NatsEv
 
 		messageId := ksuid.New()
 		subject := "spheres.content.updated." + sphereIdHex
-		_ = eventStore.PublishJSON(constants.StreamSpheres, subject, messageId.String(), evt, nil)
+		_ = md.EventStore.PublishJSON(constants.StreamSpheres, subject, messageId.String(), evt, nil)
 
 		ownhttp.WriteJSON(w, 200, evt)
 	}
 }
 
+// versiones anteriores de un contenido, de la más nueva a la más vieja. Si el contenido
+// no está visible (borrado, oculto o esperando media) solo lo ven el autor y los moderadores.
+// GET /spheres/{sphereId}/contents/{contentId}/history?limit=20&cursor=opaque
+func GetSphereContentHistory(collection, spheres, editsCollection *mongo.Collection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sphereIdHex := r.PathValue("sphereId")
+		contentId, err := bson.ObjectIDFromHex(r.PathValue("contentId"))
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_CONTENT_ID", "invalid content id")
+			return
+		}
+
+		content, ok := findContentInSphere(w, r, collection, sphereIdHex, contentId)
+		if !ok {
+			return
+		}
+		if content.Deleted || content.Hidden || content.MediaPending {
+			p := ownhttp.PrincipalFrom(r.Context())
+			allowed := !p.IsAnonymous() && p.UserID == content.UserID.Hex()
+			if !allowed && !p.IsAnonymous() {
+				sphere, err := findSphere(r.Context(), spheres, sphereIdHex)
+				if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+					ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+					return
+				}
+				allowed = err == nil && canModerate(sphere, p)
+			}
+			if !allowed {
+				ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "content not found")
+				return
+			}
+		}
+
+		pg, err := parsePage(r, 20, 50)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_CURSOR", err.Error())
+			return
+		}
+
+		match := bson.D{
+			{Key: "contentId", Value: contentId},
+			{Key: "sphereId", Value: sphereIdHex},
+		}
+		opts := options.Find().SetSort(pg.sort()).SetLimit(pg.fetch())
+		cur, err := editsCollection.Find(r.Context(), pg.apply(match), opts)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		defer cur.Close(r.Context())
+
+		docs := []bson.M{}
+		if err := cur.All(r.Context(), &docs); err != nil {
+			ownhttp.WriteJSONError(w, 500, "CURSOR_FAIL", err.Error())
+			return
+		}
+
+		docs, nextCursor, prevCursor := pg.finish(docs)
+		ownhttp.WriteJSON(w, 200, bson.M{
+			"items":      docs,
+			"nextCursor": nextCursor,
+			"prevCursor": prevCursor,
+		})
+	}
+}
+
 //	{
 //	  "id": "contentId",
 //	  "sphereId": "sphereId",
@@ -520,45 +745,111 @@ func UpdateSphereContent(collection *mongo.Collection, eventStore *system.NatsEv
 //	}
 //
 // DELETE /spheres/{sphereId}/contents/{contentId}
-func DeleteSphereContent(collection *mongo.Collection, eventStore *system.NatsEventStore) http.HandlerFunc {
+func DeleteSphereContent(collection, spheres, audit *mongo.Collection, eventStore *system.NatsEventStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodDelete {
-			ownhttp.WriteJSONError(w, 405, "NOT_ALLOWED", "method")
+		p, userId, ok := principalUser(w, r)
+		if !ok {
 			return
 		}
 
-		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/spheres/"), "/")
-		if len(parts) < 3 || parts[1] != "contents" {
-			ownhttp.WriteJSONError(w, 400, "BAD_PATH", "invalid path")
+		sphereIdHex := r.PathValue("sphereId")
+		contentId, err := bson.ObjectIDFromHex(r.PathValue("contentId"))
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_CONTENT_ID", "invalid content id")
 			return
 		}
-		sphereIdHex := parts[0]
-		contentId, _ := bson.ObjectIDFromHex(parts[2])
 
-		now := time.Now()
-		update := bson.M{
-			"$set": bson.M{
-				"deleted":   true,
-				"updatedAt": now,
-			},
+		content, ok := findContentInSphere(w, r, collection, sphereIdHex, contentId)
+		if !ok {
+			return
+		}
+
+		// autor o moderador de la sphere
+		byModerator := false
+		if content.UserID != userId {
+			sphere, err := findSphere(r.Context(), spheres, sphereIdHex)
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+				return
+			}
+			if !canModerate(sphere, p) {
+				ownhttp.WriteJSONError(w, 403, "FORBIDDEN", "only the author or a moderator can delete this content")
+				return
+			}
+			byModerator = true
+		}
+
+		evt, err := softDeleteContent(r.Context(), collection, spheres, eventStore, sphereIdHex, contentId, p.UserID)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "content not found")
+			return
 		}
-		_, err := collection.UpdateByID(r.Context(), contentId, update)
 		if err != nil {
 			ownhttp.WriteJSONError(w, 500, "DELETE_FAIL", err.Error())
 			return
 		}
 
-		evt := models.SphereContentDeleted{
-			ID:        contentId.Hex(),
-			SphereID:  sphereIdHex,
-			Deleted:   true,
-			UpdatedAt: now,
+		if byModerator {
+			writeAudit(r.Context(), audit, models.AuditEntry{
+				SphereID:     sphereIdHex,
+				ActorID:      p.UserID,
+				Action:       "content.delete",
+				TargetID:     contentId.Hex(),
+				TargetUserID: content.UserID.Hex(),
+				Reason:       r.URL.Query().Get("reason"),
+				CreatedAt:    evt.UpdatedAt,
+			})
 		}
 
-		messageId := ksuid.New()
-		subject := "spheres.content.deleted." + sphereIdHex
-		_ = eventStore.PublishJSON(constants.StreamSpheres, subject, messageId.String(), evt, nil)
-
 		ownhttp.WriteJSON(w, 200, evt)
 	}
 }
+
+// softDeleteContent marca el contenido como borrado y publica spheres.content.deleted.
+func softDeleteContent(ctx context.Context, collection, spheres *mongo.Collection, eventStore *system.NatsEventStore, sphereId string, contentId bson.ObjectID, by string) (models.SphereContentDeleted, error) {
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"deleted":   true,
+			"deletedBy": by,
+			"updatedAt": now,
+		},
+		// un borrado no sigue ocupando un lugar de pin
+		"$unset": bson.M{"pinnedAt": ""},
+	}
+	res, err := collection.UpdateOne(ctx, bson.M{"_id": contentId, "sphereId": sphereId}, update)
+	if err != nil {
+		return models.SphereContentDeleted{}, err
+	}
+	if res.MatchedCount == 0 {
+		return models.SphereContentDeleted{}, mongo.ErrNoDocuments
+	}
+	if _, err := spheres.UpdateOne(ctx, bson.M{"_id": sphereId}, bson.M{"$pull": bson.M{"pinnedIds": contentId}}); err != nil {
+		logrus.WithError(err).Warnf("content %s: failed to release pin", contentId.Hex())
+	}
+
+	evt := models.SphereContentDeleted{
+		ID:        contentId.Hex(),
+		SphereID:  sphereId,
+		Deleted:   true,
+		UpdatedAt: now,
+	}
+
+	messageId := ksuid.New()
+	subject := "spheres.content.deleted." + sphereId
+	_ = eventStore.PublishJSON(constants.StreamSpheres, subject, messageId.String(), evt, nil)
+
+	return evt, nil
+}
+
+// mergeSet: los campos del evento más los que solo van a la base (flags)
+func mergeSet(updates map[string]interface{}, extra bson.M) bson.M {
+	out := bson.M{}
+	for k, v := range updates {
+		out[k] = v
+	}
+	for k, v := range extra {
+		out[k] = v
+	}
+	return out
+}
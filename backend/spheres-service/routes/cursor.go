@@ -0,0 +1,124 @@
+package routes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	cursorOlder = "older"
+	cursorNewer = "newer"
+)
+
+var errBadCursor = errors.New("invalid cursor")
+
+// cursor opaco: base64url de {"id":<hex>,"dir":"older|newer"}
+type pageCursor struct {
+	ID  string `json:"id"`
+	Dir string `json:"dir"`
+}
+
+func encodeCursor(id bson.ObjectID, dir string) string {
+	b, _ := json.Marshal(pageCursor{ID: id.Hex(), Dir: dir})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// page pagina por _id (orden de creación) en las dos direcciones.
+type page struct {
+	after *bson.ObjectID
+	dir   string
+	limit int64
+}
+
+// parsePage lee ?cursor=&limit=; ?after=<hex> se mantiene como cursor "older".
+func parsePage(r *http.Request, def, max int64) (page, error) {
+	q := r.URL.Query()
+	p := page{dir: cursorOlder, limit: def}
+	if l, err := strconv.ParseInt(q.Get("limit"), 10, 64); err == nil && l > 0 {
+		p.limit = min(l, max)
+	}
+
+	if raw := q.Get("cursor"); raw != "" {
+		b, err := base64.RawURLEncoding.DecodeString(raw)
+		if err != nil {
+			return p, errBadCursor
+		}
+		var c pageCursor
+		if err := json.Unmarshal(b, &c); err != nil || (c.Dir != cursorOlder && c.Dir != cursorNewer) {
+			return p, errBadCursor
+		}
+		oid, err := bson.ObjectIDFromHex(c.ID)
+		if err != nil {
+			return p, errBadCursor
+		}
+		p.after, p.dir = &oid, c.Dir
+		return p, nil
+	}
+
+	if after := q.Get("after"); after != "" {
+		oid, err := bson.ObjectIDFromHex(after)
+		if err != nil {
+			return p, errBadCursor
+		}
+		p.after = &oid
+	}
+	return p, nil
+}
+
+// apply agrega el rango de _id al match
+func (p page) apply(match bson.D) bson.D {
+	if p.after == nil {
+		return match
+	}
+	op := "$lt"
+	if p.dir == cursorNewer {
+		op = "$gt"
+	}
+	return append(match, bson.E{Key: "_id", Value: bson.D{{Key: op, Value: *p.after}}})
+}
+
+func (p page) sort() bson.D {
+	if p.dir == cursorNewer {
+		return bson.D{{Key: "_id", Value: 1}}
+	}
+	return bson.D{{Key: "_id", Value: -1}}
+}
+
+// fetch: se pide uno de más para saber si hay otra página
+func (p page) fetch() int64 {
+	return p.limit + 1
+}
+
+// finish recorta, deja los items del más nuevo al más viejo y arma los cursores.
+func (p page) finish(items []bson.M) ([]bson.M, string, string) {
+	hasMore := int64(len(items)) > p.limit
+	if hasMore {
+		items = items[:p.limit]
+	}
+	if p.dir == cursorNewer {
+		slices.Reverse(items)
+	}
+	if len(items) == 0 {
+		return items, "", ""
+	}
+
+	hasOlder, hasNewer := hasMore, p.after != nil
+	if p.dir == cursorNewer {
+		hasOlder, hasNewer = p.after != nil, hasMore
+	}
+
+	var next, prev string
+	if id, ok := items[len(items)-1]["_id"].(bson.ObjectID); ok && hasOlder {
+		next = encodeCursor(id, cursorOlder)
+	}
+	if id, ok := items[0]["_id"].(bson.ObjectID); ok && hasNewer {
+		prev = encodeCursor(id, cursorNewer)
+	}
+	return items, next, prev
+}
@@ -1,39 +1,63 @@
 package routes
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/segmentio/ksuid"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"moonmap.io/go-commons/constants"
 	"moonmap.io/go-commons/helpers"
 	"moonmap.io/go-commons/ownhttp"
+	"moonmap.io/go-commons/persistence"
 	"moonmap.io/go-commons/system"
+	"moonmap.io/spheres-service/media"
 	"moonmap.io/spheres-service/models"
 )
 
-func MakeSphereMediaKey(sphereId, userId, ext string) string {
-	return "spheres/" + sphereId + "/" + userId + "/" + ksuid.New().String() + ext
+const (
+	maxPostMedia  = 4
+	mediaConsumer = "spheres-media"
+)
+
+// media de spheres sobre el pipeline de s3-service (media_assets, variantes, EXIF fuera)
+type Media struct {
+	Spheres   *mongo.Collection
+	Contents  *mongo.Collection
+	Assets    *mongo.Collection
+	Blobs     *mongo.Collection // media_blobs: variantes compartidas (dedupe)
+	Sanctions *mongo.Collection
+	Audit     *mongo.Collection
+	Blocklist *mongo.Collection // media_hash_blocklist (lo lee el screening de s3-service)
+
+	Pipeline   *media.Client
+	S3Cfg      *system.S3Config
+	S3c        *s3.Client
+	Presigner  *s3.PresignClient
+	EventStore *system.NatsEventStore
 }
 
-// POST /spheres/{sphereId}/media/presign
-func PresignSphereMedia(collection *mongo.Collection, s3Config *system.S3Config, S3Presigner *s3.PresignClient) http.HandlerFunc {
+// POST /spheres/{sphereId}/media/presign  {"profile": "post_image", "items": [{"mime": "image/png"}]}
+func PresignSphereMedia(md *Media) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			ownhttp.WriteJSONError(w, 405, "NOT_ALLOWED", "method")
+		_, userId, ok := principalUser(w, r)
+		if !ok {
+			return
+		}
+		sid := r.PathValue("sphereId")
+		if !enforceSanctions(w, r, md.Sanctions, sid, userId) {
 			return
 		}
-
-		idHex := strings.TrimPrefix(r.URL.Path, "/spheres/")
-		idHex = strings.TrimSuffix(idHex, "/media/presign")
-		sid := idHex
 
 		var req models.PresignBatchReq
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -44,84 +68,133 @@ func PresignSphereMedia(collection *mongo.Collection, s3Config *system.S3Config,
 			ownhttp.WriteJSONError(w, 400, "EMPTY", "items")
 			return
 		}
+		if len(req.Items) > maxPostMedia {
+			ownhttp.WriteJSONError(w, 400, "TOO_MANY_MEDIA", "at most 4 items per request")
+			return
+		}
 
-		userId, err := bson.ObjectIDFromHex(req.UserID)
+		sphere, err := findSphere(r.Context(), md.Spheres, sid)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "sphere not found")
+			return
+		}
 		if err != nil {
-			ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid userId")
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
 			return
 		}
 
-		now := time.Now()
-		exp := now.Add(15 * time.Minute)
+		switch req.Profile {
+		case "", models.MediaProfilePost:
+			req.Profile = models.MediaProfilePost
+			if !sphere.CanPost(userId.Hex()) {
+				ownhttp.WriteJSONError(w, 403, "READ_ONLY", "only moderators can post in this sphere")
+				return
+			}
+		case models.MediaProfileBanner:
+			if !sphere.IsModerator(userId.Hex()) {
+				ownhttp.WriteJSONError(w, 403, "FORBIDDEN", "only moderators can upload a banner")
+				return
+			}
+		default:
+			ownhttp.WriteJSONError(w, 400, "BAD_PROFILE", "profile must be post_image or banner")
+			return
+		}
 
-		var res models.PresignBatchRes
-		var docs []interface{}
+		// el tipo declarado solo elige el plan de variantes: s3-service lo fija en la
+		// policy del upload y después verifica los bytes reales
+		for i := range req.Items {
+			req.Items[i].Mime = helpers.NormCT(req.Items[i].Mime)
+			if !mediaTypeAllowed(req.Profile, helpers.ClassifyMime(req.Items[i].Mime)) {
+				ownhttp.WriteJSONError(w, 400, "UNSUPPORTED_MIME", "unsupported mime for "+req.Profile)
+				return
+			}
+		}
 
+		res := models.PresignBatchRes{Items: []models.PresignItemRes{}}
 		for _, it := range req.Items {
-			ext := helpers.SafeExtFromMime(it.Mime, it.Ext)
-			key := MakeSphereMediaKey(sid, userId.Hex(), "."+ext)
-
-			in := &s3.PutObjectInput{
-				Bucket:      aws.String(s3Config.S3Bucket),
-				Key:         aws.String(key),
-				ContentType: aws.String(it.Mime),
-				// ACL:         types.ObjectCannedACLPublicRead,
-				// CacheControl: aws.String("public, max-age=31536000, immutable"),
-			}
-			ps, err := S3Presigner.PresignPutObject(r.Context(), in, func(po *s3.PresignOptions) {
-				po.Expires = 15 * time.Minute
+			ps, err := md.Pipeline.Presign(r.Context(), media.PresignReq{
+				Namespace: models.MediaNamespace,
+				ScopeType: models.MediaScopeType,
+				ScopeID:   sid,
+				Profile:   req.Profile,
+				EntityID:  bson.NewObjectID().Hex(),
+				UserID:    userId.Hex(),
+				Ext:       it.Ext,
+				Mime:      it.Mime,
 			})
-			if err != nil {
-				ownhttp.WriteJSONError(w, 500, "PRESIGN_FAIL", err.Error())
+			if errors.Is(err, media.ErrPresignRejected) {
+				ownhttp.WriteJSONError(w, 400, "UNSUPPORTED_MIME", "mime not allowed")
 				return
 			}
-
-			doc := models.UploadPendingDoc{
-				SphereID:  sid,
-				UserID:    userId,
-				Key:       key,
-				Mime:      it.Mime,
-				Status:    "pending",
-				ExpiresAt: exp,
-				CreatedAt: now,
-				UpdatedAt: now,
+			if errors.Is(err, media.ErrPresignRateLimited) {
+				ownhttp.WriteJSONError(w, 429, "RATE_LIMITED", "too many uploads, try again later")
+				return
+			}
+			if err != nil {
+				logrus.WithError(err).Warnf("sphere %s: presign failed", sid)
+				ownhttp.WriteJSONError(w, 502, "PRESIGN_FAIL", "media service unavailable")
+				return
 			}
-			docs = append(docs, doc)
-
-			_, uploadURL := helpers.RewriteForClient(ps)
-
-			publicURL := "https://s3.moonmap.io" + "/" + key
-
 			res.Items = append(res.Items, models.PresignItemRes{
-				ID:        "", // fill out after insert
-				Key:       key,
-				UploadURL: uploadURL,
-				PublicURL: publicURL,
-				ExpiresAt: exp,
+				ID:           ps.MediaID,
+				Key:          ps.Key,
+				UploadURL:    ps.UploadURL,
+				UploadMethod: ps.UploadMethod,
+				UploadFields: ps.UploadFields,
+				MaxBytes:     ps.MaxBytes,
+				Status:       ps.Status,
+				ExpiresAt:    ps.ExpiresAt,
 			})
 		}
 
-		insert, err := collection.InsertMany(r.Context(), docs)
-		if err != nil {
-			ownhttp.WriteJSONError(w, 500, "UPSERT_FAIL", err.Error())
-			return
-		}
+		ownhttp.WriteJSON(w, 200, res)
+	}
+}
 
-		for i, id := range insert.InsertedIDs {
-			oid := id.(bson.ObjectID)
-			res.Items[i].ID = oid.Hex()
-		}
+// mediaTypeAllowed: los banners son solo imagen; los posts aceptan también gif y video
+func mediaTypeAllowed(profile, mediaType string) bool {
+	switch mediaType {
+	case "IMAGE":
+		return true
+	case "GIF", "VIDEO":
+		return profile == models.MediaProfilePost
+	default:
+		return false
+	}
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(res)
+// findSphereAsset: asset de media_assets subido a esta sphere por el usuario
+func findSphereAsset(w http.ResponseWriter, r *http.Request, assets *mongo.Collection, sphereId string, userId bson.ObjectID, raw string) (*persistence.MediaDoc, bool) {
+	oid, err := bson.ObjectIDFromHex(raw)
+	if err != nil {
+		ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid objectid for mediaId")
+		return nil, false
 	}
+	var doc persistence.MediaDoc
+	err = assets.FindOne(r.Context(), bson.M{
+		"_id":        oid,
+		"namespace":  models.MediaNamespace,
+		"scopeId":    sphereId,
+		"uploaderId": userId.Hex(),
+	}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "media not found")
+		return nil, false
+	}
+	if err != nil {
+		ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+		return nil, false
+	}
+	return &doc, true
 }
 
-func CompleteSphereMedia(coll *mongo.Collection, s3Config *system.S3Config, s3client *s3.Client) http.HandlerFunc {
+// el cliente avisa que terminó el PUT: media.pending arranca el pipeline
+// (HEAD con reintentos -> uploaded -> variantes -> ready)
+// POST /spheres/{sphereId}/media/complete  {"mediaId": "..."}
+func CompleteSphereMedia(md *Media) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-
-		if r.Method != http.MethodPost {
-			ownhttp.WriteJSONError(w, 405, "NOT_ALLOWED", "method not allowed")
+		_, userId, ok := principalUser(w, r)
+		if !ok {
 			return
 		}
 
@@ -137,137 +210,293 @@ func CompleteSphereMedia(coll *mongo.Collection, s3Config *system.S3Config, s3cl
 			return
 		}
 
-		oid, err := bson.ObjectIDFromHex(req.MediaID)
-		if err != nil {
-			ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid objectid for mediaId")
+		doc, ok := findSphereAsset(w, r, md.Assets, r.PathValue("sphereId"), userId, req.MediaID)
+		if !ok {
 			return
 		}
 
-		// 1. serach pending doc
-		var doc struct {
-			ID        bson.ObjectID `bson:"_id"`
-			Key       string        `bson:"key"`
-			PublicUrl string        `bson:"publicUrl"`
-			Status    string        `bson:"status"`
-		}
-
-		err = coll.FindOne(r.Context(), bson.M{"_id": oid, "status": "pending"}).Decode(&doc)
-		if err != nil {
-			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "pending media not found")
+		// ya avanzó: idempotente
+		if doc.Status != "pending" {
+			ownhttp.WriteJSON(w, 200, bson.M{"mediaId": doc.ID, "status": doc.Status})
 			return
 		}
 
-		// 2. validate on S3 object existence
-		_, err = s3client.HeadObject(r.Context(), &s3.HeadObjectInput{
-			Bucket: aws.String(s3Config.S3Bucket),
-			Key:    aws.String(doc.Key),
-		})
-		if err != nil {
-			ownhttp.WriteJSONError(w, 400, "NOT_UPLOADED", "file not uploaded yet")
-			return
+		evt := models.MediaPendingEvent{
+			MediaID:    doc.ID.Hex(),
+			Key:        doc.Key,
+			Mime:       doc.Mime,
+			UploaderID: doc.UploaderID,
+			Status:     "pending",
+			UpdatedAt:  time.Now(),
 		}
-
-		// 3. (Opcional) set ACL public + headers
-		_, err = s3client.PutObjectAcl(r.Context(), &s3.PutObjectAclInput{
-			Bucket: aws.String(s3Config.S3Bucket),
-			Key:    aws.String(doc.Key),
-			ACL:    types.ObjectCannedACLPublicRead,
-		})
-		if err != nil {
-			logrus.Warnf("failed to set ACL public for %s: %v", doc.Key, err)
-		}
-
-		// cache-control copied meta data:
-		// _, err = s3client.CopyObject(r.Context(), &s3.CopyObjectInput{
-		//     Bucket:            aws.String(bucketName),
-		//     CopySource:        aws.String(bucketName + "/" + doc.Key),
-		//     Key:               aws.String(doc.Key),
-		//     MetadataDirective: types.MetadataDirectiveReplace,
-		//     CacheControl:      aws.String("public, max-age=31536000, immutable"),
-		//     ACL:               types.ObjectCannedACLPublicRead,
-		// })
-
-		// 4. update doc on db
-		_, err = coll.UpdateByID(r.Context(), doc.ID, bson.M{
-			"$set": bson.M{
-				"status":    "uploaded",
-				"updatedAt": time.Now(),
-			},
-			"$unset": bson.M{"pending": ""},
-		})
-		if err != nil {
-			ownhttp.WriteJSONError(w, 500, "DB_ERROR", err.Error())
+		if err := md.EventStore.PublishJSON(constants.StreamMedia, "media.pending", doc.CreateMessageId(), evt, nil); err != nil {
+			ownhttp.WriteJSONError(w, 502, "PUBLISH_FAIL", "media pipeline unavailable")
 			return
 		}
 
-		publicUrl := helpers.GetEnv("S3_ENDPOINT_REWRITE", "") + "/" + doc.Key
-
-		// 5. respond to the client
-		ownhttp.WriteJSON(w, 200, map[string]any{
-			"mediaId":   doc.ID,
-			"publicUrl": publicUrl,
-			"status":    "uploaded",
-		})
-
+		ownhttp.WriteJSON(w, 202, bson.M{"mediaId": doc.ID, "status": "pending"})
 	}
 }
 
 // DELETE /spheres/{sphereId}/media/{mediaId}
-func DeleteSphereMedia(coll *mongo.Collection, s3Config *system.S3Config, s3client *s3.Client) http.HandlerFunc {
+func DeleteSphereMedia(md *Media) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-
-		if r.Method != http.MethodDelete {
-			ownhttp.WriteJSONError(w, 405, "NOT_ALLOWED", "method not allowed")
+		_, userId, ok := principalUser(w, r)
+		if !ok {
 			return
 		}
 
-		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/spheres/"), "/")
-		if len(parts) < 3 || parts[1] != "media" {
-			ownhttp.WriteJSONError(w, 400, "BAD_PATH", "expected /spheres/{sphereId}/media/{mediaId}")
+		sphereId := r.PathValue("sphereId")
+		doc, ok := findSphereAsset(w, r, md.Assets, sphereId, userId, r.PathValue("mediaId"))
+		if !ok {
 			return
 		}
-		sphereId := parts[0]
-		mediaId := parts[2]
 
-		oid, err := bson.ObjectIDFromHex(mediaId)
+		// lo que ya está en un post se va con el post
+		n, err := md.Contents.CountDocuments(r.Context(), bson.M{"mediaIds": doc.ID, "deleted": false})
 		if err != nil {
-			ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid mediaId")
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
 			return
 		}
-
-		// search document
-		var doc struct {
-			ID  bson.ObjectID `bson:"_id"`
-			Key string        `bson:"key"`
-		}
-		err = coll.FindOne(r.Context(), bson.M{"_id": oid, "sphereId": sphereId}).Decode(&doc)
-		if err != nil {
-			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "media not found")
+		if n > 0 {
+			ownhttp.WriteJSONError(w, 409, "MEDIA_IN_USE", "media is attached to a post")
 			return
 		}
 
-		// remove from S3
-		_, err = s3client.DeleteObject(r.Context(), &s3.DeleteObjectInput{
-			Bucket: aws.String(s3Config.S3Bucket),
-			Key:    aws.String(doc.Key),
-		})
+		// original + variantes; las compartidas con otros uploads se quedan
+		rel, err := persistence.ReleaseMedia(r.Context(), md.Blobs, doc)
 		if err != nil {
-			ownhttp.WriteJSONError(w, 500, "S3_DELETE_FAIL", err.Error())
+			ownhttp.WriteJSONError(w, 500, "DB_UPDATE_FAIL", err.Error())
 			return
 		}
+		for _, k := range rel.Delete {
+			_, err = md.S3c.DeleteObject(r.Context(), &s3.DeleteObjectInput{
+				Bucket: aws.String(md.S3Cfg.S3Bucket),
+				Key:    aws.String(k),
+			})
+			if err != nil {
+				ownhttp.WriteJSONError(w, 500, "S3_DELETE_FAIL", err.Error())
+				return
+			}
+		}
 
-		// remove form DB
-		_, err = coll.DeleteOne(r.Context(), bson.M{"_id": oid})
-		if err != nil {
+		if _, err = md.Assets.DeleteOne(r.Context(), bson.M{"_id": doc.ID}); err != nil {
 			ownhttp.WriteJSONError(w, 500, "DB_DELETE_FAIL", err.Error())
 			return
 		}
 
 		ownhttp.WriteJSON(w, 200, map[string]any{
-			"mediaId":  mediaId,
+			"mediaId":  doc.ID,
 			"sphereId": sphereId,
 			"status":   "deleted",
 		})
+	}
+}
 
+// checkPostMedia valida los mediaIds de un post: uploads post_image del autor en esta
+// sphere que no fallaron. pending = alguno todavía no está ready.
+func checkPostMedia(w http.ResponseWriter, r *http.Request, assets *mongo.Collection, sphereId string, userId bson.ObjectID, raw []string) (ids []bson.ObjectID, pending bool, ok bool) {
+	if len(raw) > maxPostMedia {
+		ownhttp.WriteJSONError(w, 400, "TOO_MANY_MEDIA", "at most 4 media per post")
+		return nil, false, false
+	}
+	ids = make([]bson.ObjectID, 0, len(raw))
+	seen := map[bson.ObjectID]bool{}
+	for _, mid := range raw {
+		oid, err := bson.ObjectIDFromHex(mid)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid objectid for mediaId")
+			return nil, false, false
+		}
+		if seen[oid] {
+			ownhttp.WriteJSONError(w, 400, "MEDIA_INVALID", "duplicated mediaId")
+			return nil, false, false
+		}
+		seen[oid] = true
+		ids = append(ids, oid)
+	}
+
+	found, err := loadAssets(r.Context(), assets, ids)
+	if err != nil {
+		ownhttp.WriteJSONError(w, 500, "DB_ERROR", err.Error())
+		return nil, false, false
+	}
+	for _, id := range ids {
+		a, exists := found[id]
+		if !exists || !a.IsSphereUpload(sphereId, models.MediaProfilePost, userId.Hex()) {
+			ownhttp.WriteJSONError(w, 400, "MEDIA_INVALID", "media must be a post_image upload of yours in this sphere")
+			return nil, false, false
+		}
+		if a.Status == models.MediaFailed {
+			ownhttp.WriteJSONError(w, 400, "MEDIA_INVALID", "media processing failed")
+			return nil, false, false
+		}
+		if a.Status != models.MediaReady {
+			pending = true
+		}
+	}
+	return ids, pending, true
+}
+
+// contentView: el contenido como sale en spheres.content.added (autor y media resueltos)
+func (md *Media) contentView(ctx context.Context, contentId bson.ObjectID) (*models.SphereContentCreated, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: "_id", Value: contentId},
+			{Key: "deleted", Value: false},
+		}}},
+		{{Key: "$lookup", Value: usersLookup}},
+		{{Key: "$unwind", Value: bson.D{
+			{Key: "path", Value: "$user"},
+			{Key: "preserveNullAndEmptyArrays", Value: true},
+		}}},
+		{{Key: "$project", Value: endProjection}},
+	}
+	cur, err := md.Contents.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	docs := []models.SphereContentCreated{}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	view := &docs[0]
+	assets, err := loadAssets(ctx, md.Assets, view.MediaIDs)
+	if err != nil {
+		return nil, err
+	}
+	view.Media = mediaViews(view.MediaIDs, assets)
+	return view, nil
+}
+
+// publishAdded: el post ya es visible -> actividad de la sphere y spheres.content.added
+// (los anuncios van también al scope de la sphere)
+func (md *Media) publishAdded(ctx context.Context, view *models.SphereContentCreated, announce bool) {
+	_, err := md.Spheres.UpdateByID(ctx, view.SphereID, bson.M{"$max": bson.M{"lastPostAt": time.Now()}})
+	if err != nil {
+		logrus.Warnf("sphere %s: failed to update lastPostAt: %v", view.SphereID, err)
+	}
+
+	subject := "spheres.content.added." + view.SphereID
+	_ = md.EventStore.PublishJSON(constants.StreamSpheres, subject, ksuid.New().String(), view, nil)
+
+	if announce {
+		subject := constants.StreamNotify + ".scope.sphere." + view.SphereID + ".announcement"
+		_ = md.EventStore.PublishJSON(constants.StreamNotify, subject, view.ID.Hex(), view, nil)
+	}
+}
+
+// settleContent publica un post con media pendiente cuando todos sus assets terminaron.
+// Los que fallaron se sacan del post; el update condicional (mediaPending: true) hace
+// que una sola llamada lo publique aunque lleguen eventos en paralelo.
+func (md *Media) settleContent(ctx context.Context, contentId bson.ObjectID) error {
+	var c struct {
+		models.SphereContent `bson:",inline"`
+		Flagged              bool `bson:"flagged"`
+	}
+	err := md.Contents.FindOne(ctx, bson.M{"_id": contentId, "mediaPending": true}).Decode(&c)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	assets, err := loadAssets(ctx, md.Assets, c.MediaIDs)
+	if err != nil {
+		return err
+	}
+	failed := []bson.ObjectID{}
+	for _, id := range c.MediaIDs {
+		a, ok := assets[id]
+		if !ok || a.Status == models.MediaFailed {
+			failed = append(failed, id)
+			continue
+		}
+		if a.Status != models.MediaReady {
+			return nil // todavía procesando
+		}
+	}
+
+	update := bson.M{"$unset": bson.M{"mediaPending": ""}}
+	if len(failed) > 0 {
+		update["$pull"] = bson.M{"mediaIds": bson.M{"$in": failed}}
+	}
+	// sin media, texto ni encuesta no queda nada que mostrar
+	empty := len(failed) == len(c.MediaIDs) && strings.TrimSpace(c.Text) == "" && c.Poll == nil
+	if empty {
+		update["$set"] = bson.M{"deleted": true, "deletedBy": "media", "updatedAt": time.Now()}
+	}
+
+	res, err := md.Contents.UpdateOne(ctx, bson.M{"_id": contentId, "mediaPending": true}, update)
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount == 0 || empty {
+		return nil
+	}
+
+	view, err := md.contentView(ctx, contentId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	md.publishAdded(ctx, view, c.Type == models.ContentAnnouncement && !c.Flagged)
+	return nil
+}
+
+// Start: consumer durable de media.process.* para los assets de spheres.
+func (md *Media) Start(ctx context.Context) {
+	md.EventStore.CreateConsumer(constants.StreamMedia, mediaConsumer, []string{
+		// media.process.<completed|failed|quarantined>.<mediaType>.sphere.<sphereId>.<profile>.<entityId>
+		"media.process.*.*." + models.MediaScopeType + ".>",
+	}, func(msg jetstream.Msg) error {
+		return md.handle(ctx, msg)
+	})
+}
+
+func (md *Media) handle(ctx context.Context, msg jetstream.Msg) error {
+	var evt struct {
+		MediaID string `json:"mediaId"`
+	}
+	if err := json.Unmarshal(msg.Data(), &evt); err != nil {
+		logrus.WithError(err).Warnf("spheres media: bad payload on %s", msg.Subject())
+		return nil
+	}
+	oid, err := bson.ObjectIDFromHex(evt.MediaID)
+	if err != nil {
+		return nil
+	}
+	return md.settleByMedia(ctx, oid)
+}
+
+// settleByMedia: posts con media pendiente que usan este asset
+func (md *Media) settleByMedia(ctx context.Context, oid bson.ObjectID) error {
+	cur, err := md.Contents.Find(ctx, bson.M{"mediaIds": oid, "mediaPending": true},
+		options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	var pending []struct {
+		ID bson.ObjectID `bson:"_id"`
+	}
+	if err := cur.All(ctx, &pending); err != nil {
+		return err
+	}
+	for _, p := range pending {
+		if err := md.settleContent(ctx, p.ID); err != nil {
+			return err
+		}
 	}
+	return nil
 }
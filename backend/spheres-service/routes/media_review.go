@@ -0,0 +1,263 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"moonmap.io/go-commons/constants"
+	"moonmap.io/go-commons/ownhttp"
+	"moonmap.io/go-commons/persistence"
+	"moonmap.io/spheres-service/models"
+)
+
+// el original en quarantined es privado: el moderador lo ve por un GET firmado corto
+const previewTTL = 5 * time.Minute
+
+type QuarantinedMedia struct {
+	ID         bson.ObjectID               `json:"mediaId"`
+	UploaderID string                      `json:"uploaderId"`
+	Profile    string                      `json:"profile"`
+	MediaType  string                      `json:"mediaType,omitempty"`
+	Screening  *persistence.MediaScreening `json:"screening,omitempty"`
+	PreviewURL string                      `json:"previewUrl,omitempty"`
+	CreatedAt  time.Time                   `json:"createdAt"`
+}
+
+// GET /spheres/{sphereId}/media/quarantine?limit=50
+func ListQuarantinedMedia(md *Media) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sphereId := r.PathValue("sphereId")
+		if _, ok := requireModerator(w, r, md.Spheres, sphereId); !ok {
+			return
+		}
+
+		limit := int64(50)
+		if l, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64); err == nil && l > 0 {
+			limit = min(l, 200)
+		}
+		cur, err := md.Assets.Find(r.Context(), bson.M{
+			"namespace": models.MediaNamespace,
+			"scopeId":   sphereId,
+			"status":    models.MediaQuarantined,
+		}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit))
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		defer cur.Close(r.Context())
+
+		var docs []persistence.MediaDoc
+		if err := cur.All(r.Context(), &docs); err != nil {
+			ownhttp.WriteJSONError(w, 500, "DECODE_FAIL", err.Error())
+			return
+		}
+
+		items := make([]QuarantinedMedia, 0, len(docs))
+		for _, d := range docs {
+			it := QuarantinedMedia{
+				ID:         d.ID,
+				UploaderID: d.UploaderID,
+				Profile:    d.Profile,
+				MediaType:  d.MediaType,
+				Screening:  d.Screening,
+				CreatedAt:  d.CreatedAt,
+			}
+			if it.Screening != nil {
+				// los hashes no le sirven al moderador
+				scr := *it.Screening
+				scr.DHash, scr.PHash = "", ""
+				it.Screening = &scr
+			}
+			req, err := md.Presigner.PresignGetObject(r.Context(), &s3.GetObjectInput{
+				Bucket: aws.String(md.S3Cfg.S3Bucket),
+				Key:    aws.String(d.Key),
+			}, s3.WithPresignExpires(previewTTL))
+			if err != nil {
+				logrus.WithError(err).Warnf("sphere %s: preview presign failed for %s", sphereId, d.ID.Hex())
+			} else {
+				it.PreviewURL = req.URL
+			}
+			items = append(items, it)
+		}
+
+		ownhttp.WriteJSON(w, 200, map[string]any{"items": items})
+	}
+}
+
+// POST /spheres/{sphereId}/media/{mediaId}/review  {"decision": "approve|reject", "block": true, "note": "..."}
+// approve vuelve a mandar el asset al pipeline (ya sin screening); reject lo deja en
+// failed, borra el original y opcionalmente agrega sus hashes a la blocklist.
+// El uploader no puede revisar lo suyo; aprobar o agregar a la blocklist (block) es
+// solo de admins.
+func ReviewSphereMedia(md *Media) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sphereId := r.PathValue("sphereId")
+		if _, ok := requireModerator(w, r, md.Spheres, sphereId); !ok {
+			return
+		}
+		p, _, _ := principalUser(w, r)
+
+		oid, err := bson.ObjectIDFromHex(r.PathValue("mediaId"))
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid objectid for mediaId")
+			return
+		}
+
+		var req struct {
+			Decision string `json:"decision"`
+			Block    bool   `json:"block"`
+			Note     string `json:"note"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_REQUEST", "invalid json")
+			return
+		}
+
+		now := time.Now()
+		var status, review string
+		switch req.Decision {
+		case "approve":
+			status, review = "uploaded", "approved"
+		case "reject":
+			status, review = models.MediaFailed, "rejected"
+		default:
+			ownhttp.WriteJSONError(w, 400, "BAD_DECISION", "decision must be approve or reject")
+			return
+		}
+
+		filter := bson.M{
+			"_id":       oid,
+			"namespace": models.MediaNamespace,
+			"scopeId":   sphereId,
+			"status":    models.MediaQuarantined,
+		}
+		var current persistence.MediaDoc
+		err = md.Assets.FindOne(r.Context(), filter).Decode(&current)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "no quarantined media with that id")
+			return
+		}
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		// nadie revisa lo que subió. Aprobar es solo de admins: el asset aprobado queda como
+		// blob ready y los uploads con los mismos bytes (en cualquier namespace) lo reusan
+		// sin pasar por el clasificador; los moderadores de la sphere solo rechazan.
+		if current.UploaderID == p.UserID {
+			ownhttp.WriteJSONError(w, 403, "SELF_REVIEW", "cannot review your own upload")
+			return
+		}
+		if review == "approved" && !p.HasRole(adminRole) {
+			ownhttp.WriteJSONError(w, 403, "ADMIN_REQUIRED", "quarantined media can only be approved by an admin")
+			return
+		}
+		// la blocklist es de toda la plataforma (s3-service la usa en todos los namespaces)
+		if req.Block && review == "rejected" && !p.HasRole(adminRole) {
+			ownhttp.WriteJSONError(w, 403, "ADMIN_REQUIRED", "only an admin can add media to the blocklist")
+			return
+		}
+
+		set := bson.M{
+			"status":               status,
+			"screening.review":     review,
+			"screening.reviewedBy": p.UserID,
+			"screening.reviewedAt": now,
+			"updatedAt":            now,
+		}
+		if review == "approved" {
+			set["screening.action"] = "allow"
+		}
+
+		// condicional: dos moderadores a la vez => uno solo decide
+		var doc persistence.MediaDoc
+		err = md.Assets.FindOneAndUpdate(r.Context(), filter, bson.M{"$set": set},
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&doc)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "no quarantined media with that id")
+			return
+		}
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "DB_UPDATE_FAIL", err.Error())
+			return
+		}
+
+		if review == "approved" {
+			// mismo evento que el publisher de s3-service; msgId distinto al del upload
+			// original para que el dedupe de JetStream no lo descarte
+			evt := models.MediaPendingEvent{
+				MediaID:    doc.ID.Hex(),
+				Key:        doc.Key,
+				Mime:       doc.Mime,
+				UploaderID: doc.UploaderID,
+				Status:     "uploaded",
+				UpdatedAt:  now,
+			}
+			err := md.EventStore.PublishJSON(constants.StreamMedia, doc.CreateMediaSubject("uploaded"), doc.CreateMessageId()+":approved", evt, nil)
+			if err != nil {
+				logrus.WithError(err).Errorf("sphere %s: failed to requeue approved media %s", sphereId, doc.ID.Hex())
+				ownhttp.WriteJSONError(w, 502, "PUBLISH_FAIL", "media pipeline unavailable")
+				return
+			}
+		} else {
+			_, err := md.S3c.DeleteObject(r.Context(), &s3.DeleteObjectInput{
+				Bucket: aws.String(md.S3Cfg.S3Bucket),
+				Key:    aws.String(doc.Key),
+			})
+			if err != nil {
+				logrus.WithError(err).Warnf("sphere %s: failed to delete rejected media %s", sphereId, doc.Key)
+			}
+
+			if req.Block && doc.Screening != nil && (doc.Screening.DHash != "" || doc.Screening.PHash != "") {
+				_, err := md.Blocklist.InsertOne(r.Context(), persistence.MediaHashBlock{
+					DHash:     doc.Screening.DHash,
+					PHash:     doc.Screening.PHash,
+					Reason:    req.Note,
+					MediaID:   doc.ID.Hex(),
+					CreatedBy: p.UserID,
+					CreatedAt: now,
+				})
+				if err != nil {
+					ownhttp.WriteJSONError(w, 500, "DB_INSERT_FAIL", err.Error())
+					return
+				}
+			}
+
+			// los posts que esperaban este asset se publican sin él (o se borran si quedan vacíos)
+			if err := md.settleByMedia(r.Context(), doc.ID); err != nil {
+				logrus.WithError(err).Warnf("sphere %s: failed to settle posts for rejected media %s", sphereId, doc.ID.Hex())
+			}
+		}
+
+		meta := map[string]any{"blocked": req.Block && review == "rejected"}
+		if doc.Screening != nil {
+			meta["screeningReason"] = doc.Screening.Reason
+			meta["label"] = doc.Screening.Label
+		}
+		writeAudit(r.Context(), md.Audit, models.AuditEntry{
+			SphereID:     sphereId,
+			ActorID:      p.UserID,
+			Action:       "media." + req.Decision,
+			TargetID:     doc.ID.Hex(),
+			TargetUserID: doc.UploaderID,
+			Reason:       req.Note,
+			Meta:         meta,
+			CreatedAt:    now,
+		})
+
+		ownhttp.WriteJSON(w, 200, map[string]any{
+			"mediaId": doc.ID,
+			"status":  status,
+			"review":  review,
+		})
+	}
+}
@@ -0,0 +1,504 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"moonmap.io/go-commons/constants"
+	"moonmap.io/go-commons/ownhttp"
+	"moonmap.io/go-commons/system"
+	"moonmap.io/spheres-service/filter"
+	"moonmap.io/spheres-service/models"
+)
+
+// colecciones que usa la moderación
+type Moderation struct {
+	Spheres   *mongo.Collection
+	Contents  *mongo.Collection
+	Reports   *mongo.Collection
+	Sanctions *mongo.Collection
+	Audit     *mongo.Collection
+
+	EventStore *system.NatsEventStore
+}
+
+// tope de mute/ban temporal; más largo que esto es un ban sin expiración
+const maxSanctionSec = 365 * 24 * 60 * 60
+
+func publishModeration(eventStore *system.NatsEventStore, evt models.ModerationEvent) {
+	subject := "spheres.moderation." + evt.Action + "." + evt.SphereID
+	_ = eventStore.PublishJSON(constants.StreamSpheres, subject, ksuid.New().String(), evt, nil)
+}
+
+// activeSanction devuelve el ban o mute vigente (el ban tiene prioridad).
+func activeSanction(ctx context.Context, sanctions *mongo.Collection, sphereId string, userId bson.ObjectID) (*models.Sanction, error) {
+	// el TTL de mongo tarda hasta un minuto, se filtra igual por expiresAt
+	cur, err := sanctions.Find(ctx, bson.M{
+		"sphereId": sphereId,
+		"userId":   userId,
+		"$or": bson.A{
+			bson.M{"expiresAt": bson.M{"$exists": false}},
+			bson.M{"expiresAt": bson.M{"$gt": time.Now()}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var found []models.Sanction
+	if err := cur.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	var active *models.Sanction
+	for i := range found {
+		if active == nil || found[i].Type == models.SanctionBan {
+			active = &found[i]
+		}
+	}
+	return active, nil
+}
+
+// enforceSanctions corta el request si el usuario está baneado o muteado en la sphere.
+func enforceSanctions(w http.ResponseWriter, r *http.Request, sanctions *mongo.Collection, sphereId string, userId bson.ObjectID) bool {
+	s, err := activeSanction(r.Context(), sanctions, sphereId, userId)
+	if err != nil {
+		ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+		return false
+	}
+	if s == nil {
+		return true
+	}
+
+	msg := "you are banned from this sphere"
+	code := "BANNED"
+	if s.Type == models.SanctionMute {
+		code = "MUTED"
+		msg = "you are muted in this sphere"
+	}
+	if s.ExpiresAt != nil {
+		msg += " until " + s.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	ownhttp.WriteJSONError(w, 403, code, msg)
+	return false
+}
+
+// POST /spheres/{sphereId}/contents/{contentId}/reports
+func ReportSphereContent(m *Moderation) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, userId, ok := principalUser(w, r)
+		if !ok {
+			return
+		}
+
+		sphereId := r.PathValue("sphereId")
+		contentId, err := bson.ObjectIDFromHex(r.PathValue("contentId"))
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_CONTENT_ID", "invalid content id")
+			return
+		}
+
+		var req struct {
+			Reason string `json:"reason"`
+			Note   string `json:"note"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_JSON", "invalid json body")
+			return
+		}
+		if !slices.Contains(models.ReportReasons, req.Reason) {
+			ownhttp.WriteJSONError(w, 400, "BAD_REASON", "reason must be one of the supported report reasons")
+			return
+		}
+		if len(req.Note) > 500 {
+			req.Note = req.Note[:500]
+		}
+
+		content, ok := findContentInSphere(w, r, m.Contents, sphereId, contentId)
+		if !ok {
+			return
+		}
+		if content.Deleted {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "content not found")
+			return
+		}
+
+		// un reporte abierto por usuario y contenido
+		now := time.Now()
+		res, err := m.Reports.UpdateOne(r.Context(),
+			bson.M{"contentId": contentId, "reporterId": userId, "status": models.ReportOpen},
+			bson.M{"$setOnInsert": models.ContentReport{
+				SphereID:   sphereId,
+				ContentID:  contentId,
+				ReporterID: userId,
+				Reason:     req.Reason,
+				Note:       req.Note,
+				Status:     models.ReportOpen,
+				CreatedAt:  now,
+			}},
+			options.UpdateOne().SetUpsert(true),
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			ownhttp.WriteJSON(w, 200, bson.M{"contentId": contentId.Hex(), "status": "already_reported"})
+			return
+		}
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "INSERT_FAIL", err.Error())
+			return
+		}
+		if res.UpsertedCount == 0 {
+			ownhttp.WriteJSON(w, 200, bson.M{"contentId": contentId.Hex(), "status": "already_reported"})
+			return
+		}
+
+		// el reporter no se publica
+		publishModeration(m.EventStore, models.ModerationEvent{
+			SphereID:  sphereId,
+			Action:    "reported",
+			ContentID: contentId.Hex(),
+			Reason:    req.Reason,
+			CreatedAt: now,
+		})
+
+		ownhttp.WriteJSON(w, 201, bson.M{"contentId": contentId.Hex(), "status": models.ReportOpen})
+	}
+}
+
+// cola de moderación: contenidos con reportes abiertos, los más reportados primero
+// GET /spheres/{sphereId}/moderation/queue?limit=50
+func GetModerationQueue(m *Moderation) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sphereId := r.PathValue("sphereId")
+		if _, ok := requireModerator(w, r, m.Spheres, sphereId); !ok {
+			return
+		}
+
+		limit := int64(50)
+		if l, err := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64); err == nil && l > 0 {
+			limit = min(l, 200)
+		}
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.D{
+				{Key: "sphereId", Value: sphereId},
+				{Key: "status", Value: models.ReportOpen},
+			}}},
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: "$contentId"},
+				{Key: "reportCount", Value: bson.D{{Key: "$sum", Value: 1}}},
+				{Key: "reasons", Value: bson.D{{Key: "$addToSet", Value: "$reason"}}},
+				{Key: "firstReportedAt", Value: bson.D{{Key: "$min", Value: "$createdAt"}}},
+				{Key: "lastReportedAt", Value: bson.D{{Key: "$max", Value: "$createdAt"}}},
+			}}},
+			{{Key: "$sort", Value: bson.D{{Key: "reportCount", Value: -1}, {Key: "lastReportedAt", Value: -1}}}},
+			{{Key: "$limit", Value: limit}},
+			{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: constants.SphereContentsCollectionName},
+				{Key: "let", Value: bson.D{{Key: "cid", Value: "$_id"}}},
+				{Key: "pipeline", Value: mongo.Pipeline{
+					{{Key: "$match", Value: bson.D{
+						{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$_id", "$$cid"}}}},
+					}}},
+					{{Key: "$lookup", Value: usersLookup}},
+					{{Key: "$unwind", Value: bson.D{
+						{Key: "path", Value: "$user"},
+						{Key: "preserveNullAndEmptyArrays", Value: true},
+					}}},
+					{{Key: "$project", Value: bson.D{
+						{Key: "_id", Value: 1},
+						{Key: "type", Value: 1},
+						{Key: "text", Value: 1},
+						{Key: "mediaUrls", Value: 1},
+						{Key: "parentId", Value: 1},
+						{Key: "user", Value: 1},
+						{Key: "hidden", Value: 1},
+						{Key: "deleted", Value: 1},
+						{Key: "flags", Value: 1},
+						{Key: "createdAt", Value: 1},
+					}}},
+				}},
+				{Key: "as", Value: "content"},
+			}}},
+			{{Key: "$unwind", Value: bson.D{
+				{Key: "path", Value: "$content"},
+				{Key: "preserveNullAndEmptyArrays", Value: true},
+			}}},
+			{{Key: "$project", Value: bson.D{
+				{Key: "_id", Value: 0},
+				{Key: "contentId", Value: "$_id"},
+				{Key: "reportCount", Value: 1},
+				{Key: "reasons", Value: 1},
+				{Key: "firstReportedAt", Value: 1},
+				{Key: "lastReportedAt", Value: 1},
+				{Key: "content", Value: 1},
+			}}},
+		}
+
+		cur, err := m.Reports.Aggregate(r.Context(), pipeline)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		defer cur.Close(r.Context())
+
+		items := []bson.M{}
+		if err := cur.All(r.Context(), &items); err != nil {
+			ownhttp.WriteJSONError(w, 500, "CURSOR_FAIL", err.Error())
+			return
+		}
+
+		ownhttp.WriteJSON(w, 200, bson.M{"items": items})
+	}
+}
+
+//	{
+//	  "action": "hide|unhide|delete|dismiss|mute|unmute|ban|unban",
+//	  "contentId": "...",   // acciones sobre contenido
+//	  "userId": "...",      // acciones sobre usuario (si falta se usa el autor del contentId)
+//	  "durationSec": 86400, // mute (obligatorio) / ban (opcional)
+//	  "reason": "spam"
+//	}
+//
+// POST /spheres/{sphereId}/moderation/actions
+func ModerateSphere(m *Moderation) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sphereId := r.PathValue("sphereId")
+		sphere, ok := requireModerator(w, r, m.Spheres, sphereId)
+		if !ok {
+			return
+		}
+		p := ownhttp.PrincipalFrom(r.Context())
+
+		var req struct {
+			Action      string `json:"action"`
+			ContentID   string `json:"contentId"`
+			UserID      string `json:"userId"`
+			DurationSec int64  `json:"durationSec"`
+			Reason      string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_JSON", "invalid json body")
+			return
+		}
+		if req.DurationSec < 0 || req.DurationSec > maxSanctionSec {
+			ownhttp.WriteJSONError(w, 400, "BAD_DURATION", "durationSec must be between 0 and 365 days")
+			return
+		}
+
+		var content *models.SphereContent
+		if req.ContentID != "" {
+			contentId, err := bson.ObjectIDFromHex(req.ContentID)
+			if err != nil {
+				ownhttp.WriteJSONError(w, 400, "BAD_CONTENT_ID", "invalid content id")
+				return
+			}
+			if content, ok = findContentInSphere(w, r, m.Contents, sphereId, contentId); !ok {
+				return
+			}
+		}
+
+		now := time.Now()
+		evt := models.ModerationEvent{
+			SphereID:  sphereId,
+			Action:    req.Action,
+			ActorID:   p.UserID,
+			Reason:    req.Reason,
+			CreatedAt: now,
+		}
+
+		switch req.Action {
+		case "hide", "unhide", "delete", "dismiss":
+			if content == nil {
+				ownhttp.WriteJSONError(w, 400, "CONTENT_REQUIRED", "contentId is required for "+req.Action)
+				return
+			}
+			evt.ContentID = content.ID.Hex()
+			evt.UserID = content.UserID.Hex()
+
+			var err error
+			switch req.Action {
+			case "hide", "unhide":
+				hidden := req.Action == "hide"
+				set := bson.M{"hidden": hidden, "updatedAt": now}
+				if hidden {
+					set["hiddenBy"] = p.UserID
+					set["hiddenAt"] = now
+				}
+				_, err = m.Contents.UpdateByID(r.Context(), content.ID, bson.M{"$set": set})
+				// ocultar también saca el pin; unhide no lo devuelve
+				if err == nil && hidden {
+					err = unpinContent(r.Context(), m.Contents, m.Spheres, sphereId, content.ID)
+				}
+				if err == nil {
+					_ = m.EventStore.PublishJSON(constants.StreamSpheres, "spheres.content.updated."+sphereId, ksuid.New().String(), models.SphereContentUpdated{
+						ID:        content.ID.Hex(),
+						SphereID:  sphereId,
+						Updates:   map[string]interface{}{"hidden": hidden},
+						UpdatedAt: now,
+					}, nil)
+				}
+			case "delete":
+				_, err = softDeleteContent(r.Context(), m.Contents, m.Spheres, m.EventStore, sphereId, content.ID, p.UserID)
+			}
+			if err != nil {
+				ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
+				return
+			}
+
+			if req.Action != "unhide" {
+				status := models.ReportResolved
+				if req.Action == "dismiss" {
+					status = models.ReportDismissed
+				}
+				_, err = m.Reports.UpdateMany(r.Context(),
+					bson.M{"contentId": content.ID, "status": models.ReportOpen},
+					bson.M{"$set": bson.M{"status": status, "resolvedBy": p.UserID, "resolvedAt": now}},
+				)
+				if err != nil {
+					ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
+					return
+				}
+			}
+
+		case "mute", "unmute", "ban", "unban":
+			targetHex := req.UserID
+			if targetHex == "" && content != nil {
+				targetHex = content.UserID.Hex()
+			}
+			target, err := bson.ObjectIDFromHex(targetHex)
+			if err != nil {
+				ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid userId")
+				return
+			}
+			evt.UserID = target.Hex()
+			if content != nil {
+				evt.ContentID = content.ID.Hex()
+			}
+
+			// el owner no se sanciona; entre moderadores solo decide el owner
+			if target.Hex() == sphere.CreatedBy || (sphere.IsModerator(target.Hex()) && sphere.CreatedBy != p.UserID && !p.HasRole(adminRole)) {
+				ownhttp.WriteJSONError(w, 403, "FORBIDDEN", "cannot sanction a moderator of this sphere")
+				return
+			}
+
+			kind := models.SanctionMute
+			if req.Action == "ban" || req.Action == "unban" {
+				kind = models.SanctionBan
+			}
+			filter := bson.M{"sphereId": sphereId, "userId": target, "type": kind}
+
+			if req.Action == "unmute" || req.Action == "unban" {
+				_, err = m.Sanctions.DeleteOne(r.Context(), filter)
+			} else {
+				if kind == models.SanctionMute && req.DurationSec <= 0 {
+					ownhttp.WriteJSONError(w, 400, "DURATION_REQUIRED", "durationSec is required for mute")
+					return
+				}
+				sanction := models.Sanction{
+					SphereID:  sphereId,
+					UserID:    target,
+					Type:      kind,
+					Reason:    req.Reason,
+					By:        p.UserID,
+					CreatedAt: now,
+				}
+				if req.DurationSec > 0 {
+					exp := now.Add(time.Duration(req.DurationSec) * time.Second)
+					sanction.ExpiresAt = &exp
+					evt.ExpiresAt = &exp
+				}
+				_, err = m.Sanctions.ReplaceOne(r.Context(), filter, sanction, options.Replace().SetUpsert(true))
+			}
+			if err != nil {
+				ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
+				return
+			}
+
+		default:
+			ownhttp.WriteJSONError(w, 400, "BAD_ACTION", "unsupported moderation action")
+			return
+		}
+
+		writeAudit(r.Context(), m.Audit, models.AuditEntry{
+			SphereID:     sphereId,
+			ActorID:      p.UserID,
+			Action:       "moderation." + req.Action,
+			TargetID:     evt.ContentID,
+			TargetUserID: evt.UserID,
+			Reason:       req.Reason,
+			CreatedAt:    now,
+		})
+		publishModeration(m.EventStore, evt)
+
+		ownhttp.WriteJSON(w, 200, evt)
+	}
+}
+
+// requireModerator carga la sphere y exige que el principal la modere.
+func requireModerator(w http.ResponseWriter, r *http.Request, spheres *mongo.Collection, sphereId string) (*models.Sphere, bool) {
+	p, _, ok := principalUser(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	sphere, err := findSphere(r.Context(), spheres, sphereId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "sphere not found")
+		return nil, false
+	}
+	if err != nil {
+		ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+		return nil, false
+	}
+	if !canModerate(sphere, p) {
+		ownhttp.WriteJSONError(w, 403, "FORBIDDEN", "moderators only")
+		return nil, false
+	}
+	return sphere, true
+}
+
+// flagForReview abre un reporte de sistema para que el contenido entre a la cola.
+func flagForReview(ctx context.Context, m *Moderation, sphereId string, contentId bson.ObjectID, verdict filter.Result) {
+	reason := "spam"
+	notes := make([]string, 0, len(verdict.Hits))
+	for _, h := range verdict.Hits {
+		if strings.HasPrefix(h.Rule, "url.") || strings.HasPrefix(h.Rule, "solana.") {
+			reason = "scam"
+		}
+		notes = append(notes, h.Rule+": "+h.Reason)
+	}
+
+	now := time.Now()
+	_, err := m.Reports.InsertOne(ctx, models.ContentReport{
+		SphereID:   sphereId,
+		ContentID:  contentId,
+		ReporterID: bson.NilObjectID, // sistema
+		Reason:     reason,
+		Note:       strings.Join(notes, "; "),
+		Status:     models.ReportOpen,
+		CreatedAt:  now,
+	})
+	if err != nil {
+		logrus.WithError(err).Warnf("content %s: failed to open system report", contentId.Hex())
+		return
+	}
+
+	publishModeration(m.EventStore, models.ModerationEvent{
+		SphereID:  sphereId,
+		Action:    "flagged",
+		ContentID: contentId.Hex(),
+		Reason:    reason,
+		CreatedAt: now,
+	})
+}
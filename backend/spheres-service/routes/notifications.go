@@ -0,0 +1,257 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"moonmap.io/go-commons/ownhttp"
+	"moonmap.io/spheres-service/models"
+)
+
+// GET /notifications?unread=true&type=&cursor=&limit=
+func GetNotifications(inbox *mongo.Collection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, userId, ok := principalUser(w, r)
+		if !ok {
+			return
+		}
+
+		p, err := parsePage(r, 30, 100)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_CURSOR", err.Error())
+			return
+		}
+
+		match := bson.D{{Key: "userId", Value: userId}}
+		if v := r.URL.Query().Get("unread"); v != "" {
+			unread, err := strconv.ParseBool(v)
+			if err != nil {
+				ownhttp.WriteJSONError(w, 400, "BAD_REQUEST", "unread must be true or false")
+				return
+			}
+			if unread {
+				match = append(match, bson.E{Key: "read", Value: false})
+			}
+		}
+		if t := r.URL.Query().Get("type"); t != "" {
+			if !slices.Contains(models.NotificationTypes, t) {
+				ownhttp.WriteJSONError(w, 400, "BAD_TYPE", "invalid notification type")
+				return
+			}
+			match = append(match, bson.E{Key: "type", Value: t})
+		}
+
+		opts := options.Find().
+			SetSort(p.sort()).
+			SetLimit(p.fetch()).
+			SetProjection(bson.M{"key": 0})
+		cur, err := inbox.Find(r.Context(), p.apply(match), opts)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		defer cur.Close(r.Context())
+
+		items := []bson.M{}
+		if err := cur.All(r.Context(), &items); err != nil {
+			ownhttp.WriteJSONError(w, 500, "CURSOR_FAIL", err.Error())
+			return
+		}
+
+		items, next, prev := p.finish(items)
+		ownhttp.WriteJSON(w, 200, bson.M{"items": items, "nextCursor": next, "prevCursor": prev})
+	}
+}
+
+// GET /notifications/unread-count
+func GetUnreadNotificationCount(inbox *mongo.Collection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, userId, ok := principalUser(w, r)
+		if !ok {
+			return
+		}
+		n, err := inbox.CountDocuments(r.Context(), bson.M{"userId": userId, "read": false})
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		ownhttp.WriteJSON(w, 200, bson.M{"unread": n})
+	}
+}
+
+// PATCH /notifications/{notificationId}  {"read": true|false}
+func SetNotificationRead(inbox *mongo.Collection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, userId, ok := principalUser(w, r)
+		if !ok {
+			return
+		}
+		id, err := bson.ObjectIDFromHex(r.PathValue("notificationId"))
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid notification id")
+			return
+		}
+
+		var req struct {
+			Read *bool `json:"read"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Read == nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_JSON", "read is required")
+			return
+		}
+
+		update := bson.M{"$set": bson.M{"read": true, "readAt": time.Now().UTC()}}
+		if !*req.Read {
+			update = bson.M{"$set": bson.M{"read": false}, "$unset": bson.M{"readAt": ""}}
+		}
+
+		var n models.Notification
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		err = inbox.FindOneAndUpdate(r.Context(), bson.M{"_id": id, "userId": userId}, update, opts).Decode(&n)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "notification not found")
+			return
+		}
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
+			return
+		}
+		ownhttp.WriteJSON(w, 200, n)
+	}
+}
+
+// POST /notifications/read  {"ids": [...]} o {"all": true, "sphereId": "..."}
+func MarkNotificationsRead(inbox *mongo.Collection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, userId, ok := principalUser(w, r)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			IDs      []string `json:"ids"`
+			All      bool     `json:"all"`
+			SphereID string   `json:"sphereId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_JSON", err.Error())
+			return
+		}
+
+		filter := bson.M{"userId": userId, "read": false}
+		switch {
+		case req.All:
+			if req.SphereID != "" {
+				filter["sphereId"] = req.SphereID
+			}
+		case len(req.IDs) > 0 && len(req.IDs) <= 200:
+			ids := make([]bson.ObjectID, 0, len(req.IDs))
+			for _, h := range req.IDs {
+				oid, err := bson.ObjectIDFromHex(h)
+				if err != nil {
+					ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid notification id "+h)
+					return
+				}
+				ids = append(ids, oid)
+			}
+			filter["_id"] = bson.M{"$in": ids}
+		default:
+			ownhttp.WriteJSONError(w, 400, "BAD_REQUEST", "ids (max 200) or all is required")
+			return
+		}
+
+		res, err := inbox.UpdateMany(r.Context(), filter, bson.M{"$set": bson.M{"read": true, "readAt": time.Now().UTC()}})
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
+			return
+		}
+		ownhttp.WriteJSON(w, 200, bson.M{"updated": res.ModifiedCount})
+	}
+}
+
+// GET /notifications/preferences
+func GetNotificationPrefs(prefs *mongo.Collection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, userId, ok := principalUser(w, r)
+		if !ok {
+			return
+		}
+		p := models.NotificationPrefs{UserID: userId, MutedSpheres: []string{}, MutedTypes: []string{}, MutedUsers: []bson.ObjectID{}}
+		err := prefs.FindOne(r.Context(), bson.M{"_id": userId}).Decode(&p)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		ownhttp.WriteJSON(w, 200, p)
+	}
+}
+
+// PUT /notifications/preferences  {"mutedSpheres": [], "mutedTypes": [], "mutedUsers": []}
+func PutNotificationPrefs(prefs *mongo.Collection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, userId, ok := principalUser(w, r)
+		if !ok {
+			return
+		}
+
+		var req struct {
+			MutedSpheres []string `json:"mutedSpheres"`
+			MutedTypes   []string `json:"mutedTypes"`
+			MutedUsers   []string `json:"mutedUsers"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_JSON", err.Error())
+			return
+		}
+		if len(req.MutedSpheres)+len(req.MutedUsers) > 500 {
+			ownhttp.WriteJSONError(w, 400, "TOO_MANY", "too many muted entries")
+			return
+		}
+
+		p := models.NotificationPrefs{
+			UserID:       userId,
+			MutedSpheres: []string{},
+			MutedTypes:   []string{},
+			MutedUsers:   []bson.ObjectID{},
+			UpdatedAt:    time.Now().UTC(),
+		}
+		for _, s := range req.MutedSpheres {
+			if s != "" && !slices.Contains(p.MutedSpheres, s) {
+				p.MutedSpheres = append(p.MutedSpheres, s)
+			}
+		}
+		for _, t := range req.MutedTypes {
+			if !slices.Contains(models.NotificationTypes, t) {
+				ownhttp.WriteJSONError(w, 400, "BAD_TYPE", "invalid notification type "+t)
+				return
+			}
+			if !slices.Contains(p.MutedTypes, t) {
+				p.MutedTypes = append(p.MutedTypes, t)
+			}
+		}
+		for _, h := range req.MutedUsers {
+			oid, err := bson.ObjectIDFromHex(h)
+			if err != nil {
+				ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid user id "+h)
+				return
+			}
+			if !slices.Contains(p.MutedUsers, oid) {
+				p.MutedUsers = append(p.MutedUsers, oid)
+			}
+		}
+
+		_, err := prefs.ReplaceOne(r.Context(), bson.M{"_id": userId}, p, options.Replace().SetUpsert(true))
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
+			return
+		}
+		ownhttp.WriteJSON(w, 200, p)
+	}
+}
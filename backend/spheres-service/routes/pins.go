@@ -0,0 +1,223 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"moonmap.io/go-commons/constants"
+	"moonmap.io/go-commons/ownhttp"
+	"moonmap.io/spheres-service/models"
+)
+
+const maxPinnedPosts = 5
+
+// PUT /spheres/{sphereId}/pins/{contentId}
+// DELETE /spheres/{sphereId}/pins/{contentId}
+func PinSphereContent(m *Moderation) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sphereId := r.PathValue("sphereId")
+		if _, ok := requireModerator(w, r, m.Spheres, sphereId); !ok {
+			return
+		}
+		p := ownhttp.PrincipalFrom(r.Context())
+
+		contentId, err := bson.ObjectIDFromHex(r.PathValue("contentId"))
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid content id")
+			return
+		}
+		content, ok := findContentInSphere(w, r, m.Contents, sphereId, contentId)
+		if !ok {
+			return
+		}
+
+		now := time.Now()
+		action := "content.pin"
+		if r.Method == http.MethodDelete {
+			action = "content.unpin"
+			if err := unpinContent(r.Context(), m.Contents, m.Spheres, sphereId, contentId); err != nil {
+				ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
+				return
+			}
+		} else {
+			if content.ParentID != nil || content.Deleted || content.Hidden || content.MediaPending {
+				ownhttp.WriteJSONError(w, 400, "NOT_PINNABLE", "only visible top-level posts can be pinned")
+				return
+			}
+			if content.PinnedAt != nil {
+				ownhttp.WriteJSON(w, 200, bson.M{"contentId": contentId, "pinned": true, "pinnedAt": content.PinnedAt})
+				return
+			}
+			reserved, err := reservePin(r.Context(), m.Contents, m.Spheres, sphereId, contentId)
+			if err != nil {
+				ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
+				return
+			}
+			if !reserved {
+				ownhttp.WriteJSONError(w, 409, "TOO_MANY_PINS", "unpin a post first")
+				return
+			}
+
+			filter := append(pinnableFilter(sphereId), bson.E{Key: "_id", Value: contentId})
+			res, err := m.Contents.UpdateOne(r.Context(), filter, bson.M{"$set": bson.M{"pinnedAt": now}})
+			if err == nil && res.MatchedCount == 0 {
+				// se borró u ocultó entre la lectura y el update: se libera el lugar
+				err = unpinContent(r.Context(), m.Contents, m.Spheres, sphereId, contentId)
+				if err == nil {
+					ownhttp.WriteJSONError(w, 400, "NOT_PINNABLE", "only visible top-level posts can be pinned")
+					return
+				}
+			}
+			if err != nil {
+				ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
+				return
+			}
+		}
+
+		writeAudit(r.Context(), m.Audit, models.AuditEntry{
+			SphereID:  sphereId,
+			ActorID:   p.UserID,
+			Action:    action,
+			TargetID:  contentId.Hex(),
+			CreatedAt: now,
+		})
+
+		pinned := action == "content.pin"
+		updates := map[string]any{"pinned": pinned}
+		if pinned {
+			updates["pinnedAt"] = now
+		}
+		evt := models.SphereContentUpdated{
+			ID:        contentId.Hex(),
+			SphereID:  sphereId,
+			Updates:   updates,
+			UpdatedAt: now,
+		}
+		subject := "spheres.content.updated." + sphereId
+		_ = m.EventStore.PublishJSON(constants.StreamSpheres, subject, ksuid.New().String(), evt, nil)
+
+		ownhttp.WriteJSON(w, 200, bson.M{"contentId": contentId, "pinned": pinned})
+	}
+}
+
+// GET /spheres/{sphereId}/pins  (más reciente primero)
+func GetSpherePins(collection, assets *mongo.Collection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sphereId := r.PathValue("sphereId")
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: pinnedFilter(sphereId)}},
+			{{Key: "$sort", Value: bson.D{{Key: "pinnedAt", Value: -1}}}},
+			{{Key: "$limit", Value: maxPinnedPosts}},
+		}
+		pipeline = append(pipeline, replyStatsStages...)
+		pipeline = append(pipeline,
+			bson.D{{Key: "$lookup", Value: usersLookup}},
+			bson.D{{Key: "$unwind", Value: bson.D{
+				{Key: "path", Value: "$user"},
+				{Key: "preserveNullAndEmptyArrays", Value: true},
+			}}},
+			bson.D{{Key: "$project", Value: endProjection}},
+		)
+
+		cur, err := collection.Aggregate(r.Context(), pipeline)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		defer cur.Close(r.Context())
+
+		items := []bson.M{}
+		if err := cur.All(r.Context(), &items); err != nil {
+			ownhttp.WriteJSONError(w, 500, "CURSOR_FAIL", err.Error())
+			return
+		}
+		if err := attachMedia(r.Context(), assets, items); err != nil {
+			ownhttp.WriteJSONError(w, 500, "MEDIA_FAIL", err.Error())
+			return
+		}
+		ownhttp.WriteJSON(w, 200, bson.M{"items": items})
+	}
+}
+
+// pinnableFilter: posts raíz visibles, lo mismo que se puede mostrar en GET .../pins
+func pinnableFilter(sphereId string) bson.D {
+	return bson.D{
+		{Key: "sphereId", Value: sphereId},
+		{Key: "parentId", Value: nil},
+		{Key: "deleted", Value: false},
+		{Key: "hidden", Value: bson.D{{Key: "$ne", Value: true}}},
+		mediaSettled,
+	}
+}
+
+func pinnedFilter(sphereId string) bson.D {
+	return append(pinnableFilter(sphereId), bson.E{Key: "pinnedAt", Value: bson.D{{Key: "$exists", Value: true}}})
+}
+
+// reservePin agrega el post a spheres.pinnedIds solo si quedan lugares; es un único
+// update condicional, así dos moderadores a la vez no pasan el límite.
+func reservePin(ctx context.Context, contents, spheres *mongo.Collection, sphereId string, contentId bson.ObjectID) (bool, error) {
+	if err := syncPins(ctx, contents, spheres, sphereId); err != nil {
+		return false, err
+	}
+	res, err := spheres.UpdateOne(ctx, bson.M{
+		"_id": sphereId,
+		"$or": bson.A{
+			bson.M{"pinnedIds": contentId},
+			bson.M{"pinnedIds." + strconv.Itoa(maxPinnedPosts-1): bson.M{"$exists": false}},
+		},
+	}, bson.M{"$addToSet": bson.M{"pinnedIds": contentId}})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount == 1, nil
+}
+
+// syncPins arma pinnedIds desde los posts fijados visibles, para spheres anteriores al campo.
+func syncPins(ctx context.Context, contents, spheres *mongo.Collection, sphereId string) error {
+	err := spheres.FindOne(ctx, bson.M{"_id": sphereId, "pinnedIds": bson.M{"$exists": true}}).Err()
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	cur, err := contents.Find(ctx, pinnedFilter(sphereId), options.Find().
+		SetSort(bson.D{{Key: "pinnedAt", Value: -1}}).
+		SetLimit(maxPinnedPosts).
+		SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	var docs []struct {
+		ID bson.ObjectID `bson:"_id"`
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return err
+	}
+	ids := make([]bson.ObjectID, 0, len(docs))
+	for _, d := range docs {
+		ids = append(ids, d.ID)
+	}
+	// condicional: si otra request ya lo inicializó no se pisa
+	_, err = spheres.UpdateOne(ctx, bson.M{"_id": sphereId, "pinnedIds": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"pinnedIds": ids}})
+	return err
+}
+
+// unpinContent saca el pin del post y libera su lugar en la sphere (unpin, borrado u ocultado).
+func unpinContent(ctx context.Context, contents, spheres *mongo.Collection, sphereId string, contentId bson.ObjectID) error {
+	if _, err := contents.UpdateOne(ctx, bson.M{"_id": contentId, "sphereId": sphereId}, bson.M{"$unset": bson.M{"pinnedAt": ""}}); err != nil {
+		return err
+	}
+	_, err := spheres.UpdateOne(ctx, bson.M{"_id": sphereId}, bson.M{"$pull": bson.M{"pinnedIds": contentId}})
+	return err
+}
@@ -0,0 +1,253 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/segmentio/ksuid"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"moonmap.io/go-commons/constants"
+	"moonmap.io/go-commons/ownhttp"
+	"moonmap.io/go-commons/system"
+	"moonmap.io/spheres-service/models"
+)
+
+const (
+	minPollOptions = 2
+	maxPollOptions = 10
+	maxPollOption  = 80
+	minPollOpen    = 5 * time.Minute
+	maxPollOpen    = 30 * 24 * time.Hour
+)
+
+// colecciones que usan las encuestas
+type Polls struct {
+	Contents  *mongo.Collection
+	Votes     *mongo.Collection
+	Sanctions *mongo.Collection
+
+	EventStore *system.NatsEventStore
+}
+
+// pedido de encuesta en POST .../contents (type poll)
+type pollReq struct {
+	Options  []string   `json:"options"`
+	ClosesAt *time.Time `json:"closesAt"`
+	Duration int64      `json:"durationSeconds"` // alternativa a closesAt
+}
+
+// build valida el pedido y arma la encuesta embebida.
+func (p *pollReq) build(now time.Time) (*models.Poll, string, string) {
+	if p == nil {
+		return nil, "BAD_POLL", "poll is required for type poll"
+	}
+	poll := &models.Poll{Options: []models.PollOption{}}
+	for _, o := range p.Options {
+		o = strings.TrimSpace(o)
+		if o == "" || utf8.RuneCountInString(o) > maxPollOption {
+			return nil, "BAD_POLL", "poll options must be 1-80 characters"
+		}
+		poll.Options = append(poll.Options, models.PollOption{ID: len(poll.Options), Text: o})
+	}
+	if len(poll.Options) < minPollOptions || len(poll.Options) > maxPollOptions {
+		return nil, "BAD_POLL", "poll needs between 2 and 10 options"
+	}
+
+	switch {
+	case p.ClosesAt != nil:
+		poll.ClosesAt = p.ClosesAt.UTC()
+	case p.Duration > 0:
+		poll.ClosesAt = now.Add(time.Duration(p.Duration) * time.Second)
+	default:
+		poll.ClosesAt = now.Add(24 * time.Hour)
+	}
+	if open := poll.ClosesAt.Sub(now); open < minPollOpen || open > maxPollOpen {
+		return nil, "BAD_POLL", "poll must stay open between 5 minutes and 30 days"
+	}
+	return poll, "", ""
+}
+
+func publishPoll(es *system.NatsEventStore, action string, contentId bson.ObjectID, sphereId string, poll *models.Poll) {
+	evt := models.PollEvent{
+		ContentID:  contentId,
+		SphereID:   sphereId,
+		Options:    poll.Options,
+		TotalVotes: poll.TotalVotes,
+		Closed:     poll.Closed,
+		ClosesAt:   poll.ClosesAt,
+	}
+	subject := "spheres.poll." + action + "." + sphereId
+	_ = es.PublishJSON(constants.StreamSpheres, subject, ksuid.New().String(), evt, nil)
+}
+
+// POST /spheres/{sphereId}/contents/{contentId}/votes  {"option": 0}
+func VotePoll(pl *Polls) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, userId, ok := principalUser(w, r)
+		if !ok {
+			return
+		}
+		sphereId := r.PathValue("sphereId")
+		contentId, err := bson.ObjectIDFromHex(r.PathValue("contentId"))
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid content id")
+			return
+		}
+		if !enforceSanctions(w, r, pl.Sanctions, sphereId, userId) {
+			return
+		}
+
+		var req struct {
+			Option *int `json:"option"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Option == nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_JSON", "option is required")
+			return
+		}
+
+		var content struct {
+			Poll *models.Poll `bson:"poll"`
+		}
+		err = pl.Contents.FindOne(r.Context(), bson.M{
+			"_id":          contentId,
+			"sphereId":     sphereId,
+			"type":         models.ContentPoll,
+			"deleted":      false,
+			"hidden":       bson.M{"$ne": true},
+			"mediaPending": bson.M{"$ne": true},
+		}).Decode(&content)
+		if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && content.Poll == nil) {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "poll not found in sphere")
+			return
+		}
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		now := time.Now()
+		if content.Poll.Closed || !now.Before(content.Poll.ClosesAt) {
+			ownhttp.WriteJSONError(w, 409, "POLL_CLOSED", "poll is closed")
+			return
+		}
+		if *req.Option < 0 || *req.Option >= len(content.Poll.Options) {
+			ownhttp.WriteJSONError(w, 400, "BAD_OPTION", "invalid poll option")
+			return
+		}
+
+		// un voto por usuario (índice único)
+		vote := models.PollVote{
+			ID:        bson.NewObjectID(),
+			ContentID: contentId,
+			SphereID:  sphereId,
+			UserID:    userId,
+			Option:    *req.Option,
+			CreatedAt: now,
+		}
+		if _, err := pl.Votes.InsertOne(r.Context(), vote); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				ownhttp.WriteJSONError(w, 409, "ALREADY_VOTED", "you already voted in this poll")
+				return
+			}
+			ownhttp.WriteJSONError(w, 500, "INSERT_FAIL", err.Error())
+			return
+		}
+
+		var updated struct {
+			Poll models.Poll `bson:"poll"`
+		}
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"poll": 1})
+		err = pl.Contents.FindOneAndUpdate(r.Context(),
+			bson.M{"_id": contentId, "poll.closed": false, "poll.closesAt": bson.M{"$gt": now}},
+			bson.M{"$inc": bson.M{"poll.totalVotes": 1, "poll.options." + strconv.Itoa(*req.Option) + ".votes": 1}},
+			opts,
+		).Decode(&updated)
+		if err != nil {
+			// se cerró entre medio: el voto no cuenta
+			_, _ = pl.Votes.DeleteOne(r.Context(), bson.M{"_id": vote.ID})
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				ownhttp.WriteJSONError(w, 409, "POLL_CLOSED", "poll is closed")
+				return
+			}
+			ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
+			return
+		}
+
+		publishPoll(pl.EventStore, "voted", contentId, sphereId, &updated.Poll)
+		ownhttp.WriteJSON(w, 200, bson.M{"contentId": contentId, "option": vote.Option, "poll": updated.Poll})
+	}
+}
+
+// GET /spheres/{sphereId}/contents/{contentId}/votes/me
+func GetMyPollVote(pl *Polls) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, userId, ok := principalUser(w, r)
+		if !ok {
+			return
+		}
+		contentId, err := bson.ObjectIDFromHex(r.PathValue("contentId"))
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid content id")
+			return
+		}
+		var vote models.PollVote
+		err = pl.Votes.FindOne(r.Context(), bson.M{"contentId": contentId, "userId": userId, "sphereId": r.PathValue("sphereId")}).Decode(&vote)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSON(w, 200, bson.M{"contentId": contentId, "voted": false, "option": nil})
+			return
+		}
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		ownhttp.WriteJSON(w, 200, bson.M{"contentId": contentId, "voted": true, "option": vote.Option, "votedAt": vote.CreatedAt})
+	}
+}
+
+// RunPollCloser marca como cerradas las encuestas vencidas y publica spheres.poll.closed.
+// El update condicional hace que una sola réplica publique cada cierre.
+func RunPollCloser(ctx context.Context, contents *mongo.Collection, es *system.NatsEventStore, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		closeDuePolls(ctx, contents, es)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func closeDuePolls(ctx context.Context, contents *mongo.Collection, es *system.NatsEventStore) {
+	for {
+		var c struct {
+			ID       bson.ObjectID `bson:"_id"`
+			SphereID string        `bson:"sphereId"`
+			Poll     models.Poll   `bson:"poll"`
+		}
+		err := contents.FindOneAndUpdate(ctx,
+			bson.M{"type": models.ContentPoll, "poll.closed": false, "poll.closesAt": bson.M{"$lte": time.Now()}},
+			bson.M{"$set": bson.M{"poll.closed": true}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"sphereId": 1, "poll": 1}),
+		).Decode(&c)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				logrus.WithError(err).Warn("poll closer: update failed")
+			}
+			return
+		}
+		publishPoll(es, "closed", c.ID, c.SphereID, &c.Poll)
+	}
+}
@@ -1,11 +1,18 @@
 package routes
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/segmentio/ksuid"
+	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
@@ -15,94 +22,466 @@ import (
 	"moonmap.io/spheres-service/models"
 )
 
-// PATCH /spheres/{sphereId}/contents/{contentId}/reactions
-func ReactSphereContent(collection *mongo.Collection, eventStore *system.NatsEventStore) http.HandlerFunc {
+const maxSphereReactions = 24
+
+// colecciones que usan las reacciones
+type Reactions struct {
+	Spheres   *mongo.Collection
+	Contents  *mongo.Collection
+	Reactions *mongo.Collection
+	Sanctions *mongo.Collection
+
+	EventStore *system.NatsEventStore
+}
+
+// el símbolo termina como clave de reactionCounts: sin '.', '$' ni espacios
+func validReactionSymbol(s string) bool {
+	if s == "" || len(s) > 32 || !utf8.ValidString(s) || strings.ContainsAny(s, ".$") {
+		return false
+	}
+	return !strings.ContainsFunc(s, unicode.IsSpace)
+}
+
+// POST|PATCH|DELETE /spheres/{sphereId}/contents/{contentId}/reactions
+func ReactSphereContent(rx *Reactions) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPatch && r.Method != http.MethodPost && r.Method != http.MethodDelete {
-			ownhttp.WriteJSONError(w, 405, "NOT_ALLOWED", "method")
+		_, userId, ok := principalUser(w, r)
+		if !ok {
 			return
 		}
 
-		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/spheres/"), "/")
-		if len(parts) < 4 || parts[1] != "contents" || parts[3] != "reactions" {
-			ownhttp.WriteJSONError(w, 400, "BAD_PATH", "invalid path")
-			return
-		}
-		sphereIdHex := parts[0]
-		contentId, err := bson.ObjectIDFromHex(parts[2])
+		sphereId := r.PathValue("sphereId")
+		contentId, err := bson.ObjectIDFromHex(r.PathValue("contentId"))
 		if err != nil {
 			ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid content id")
 			return
 		}
 
+		if !enforceSanctions(w, r, rx.Sanctions, sphereId, userId) {
+			return
+		}
+
 		var req struct {
-			UserID   string  `json:"userId"`   // requiered for audit
-			Symbol   string  `json:"symbol"`   // emoji or reactions code
+			Symbol   string  `json:"symbol"`   // emoji permitido por la sphere
 			ParentID *string `json:"parentId"` // hex o nil
-
 		}
-
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			ownhttp.WriteJSONError(w, 400, "BAD_JSON", err.Error())
 			return
 		}
 
-		userIdObjectID, err := bson.ObjectIDFromHex(req.UserID)
-		if err != nil {
-			ownhttp.WriteJSONError(w, 400, "OBJECT_ID", err.Error())
-			return
-		}
-
+		// el contenido tiene que ser de la sphere del path
+		filter := bson.M{"_id": contentId, "sphereId": sphereId, "deleted": false, "hidden": bson.M{"$ne": true}, "mediaPending": bson.M{"$ne": true}}
 		if req.ParentID != nil {
-			_, err = bson.ObjectIDFromHex(*req.ParentID)
+			parentOID, err := bson.ObjectIDFromHex(*req.ParentID)
 			if err != nil {
-				ownhttp.WriteJSONError(w, 400, "OBJECT_ID", err.Error())
+				ownhttp.WriteJSONError(w, 400, "BAD_PARENT_ID", err.Error())
 				return
 			}
+			filter["parentId"] = parentOID
+		} else {
+			filter["$or"] = []bson.M{
+				{"parentId": bson.M{"$exists": false}},
+				{"parentId": nil},
+			}
+		}
+
+		var content models.SphereContent
+		err = rx.Contents.FindOne(r.Context(), filter).Decode(&content)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "content not found in sphere")
+			return
+		}
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
 		}
 
-		// build update
 		action := "added"
-		var update bson.M
+		changed := false
 		if r.Method == http.MethodDelete {
-			update = bson.M{"$pull": bson.M{"reactions." + req.Symbol: req.UserID}}
 			action = "removed"
-		} else {
-			update = bson.M{"$addToSet": bson.M{"reactions." + req.Symbol: req.UserID}}
-		}
-
-		filter := bson.M{"_id": contentId}
-		if req.ParentID != nil {
-			parentOID, err := bson.ObjectIDFromHex(*req.ParentID)
+			res, err := rx.Reactions.DeleteOne(r.Context(), bson.M{"contentId": contentId, "userId": userId, "symbol": req.Symbol})
 			if err != nil {
-				ownhttp.WriteJSONError(w, 400, "BAD_PARENT_ID", err.Error())
+				ownhttp.WriteJSONError(w, 500, "REACTION_FAIL", err.Error())
 				return
 			}
-			filter["parentId"] = parentOID
+			changed = res.DeletedCount == 1
 		} else {
-			filter["$or"] = []bson.M{
-				{"parentId": bson.M{"$exists": false}},
-				{"parentId": nil},
+			sphere, err := findSphere(r.Context(), rx.Spheres, sphereId)
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+				return
+			}
+			if !sphere.AllowsReaction(req.Symbol) {
+				ownhttp.WriteJSONError(w, 400, "BAD_SYMBOL", "reaction not allowed in this sphere")
+				return
 			}
-		}
 
-		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
-		result := models.SphereContentReaction{}
+			_, err = rx.Reactions.InsertOne(r.Context(), models.Reaction{
+				ID:        bson.NewObjectID(),
+				ContentID: contentId,
+				SphereID:  sphereId,
+				UserID:    userId,
+				Symbol:    req.Symbol,
+				CreatedAt: time.Now(),
+			})
+			// ya había reaccionado con ese símbolo: no-op
+			if err != nil && !mongo.IsDuplicateKeyError(err) {
+				ownhttp.WriteJSONError(w, 500, "REACTION_FAIL", err.Error())
+				return
+			}
+			changed = err == nil
+		}
 
-		err = collection.FindOneAndUpdate(r.Context(), filter, update, opts).Decode(&result)
+		counts, err := applyReactionCount(r.Context(), rx.Contents, contentId, req.Symbol, changed, action == "removed")
 		if err != nil {
 			ownhttp.WriteJSONError(w, 500, "REACTION_FAIL", err.Error())
 			return
 		}
 
-		result.Action = action
-		result.Symbol = req.Symbol
-		result.UserID = userIdObjectID
+		result := models.SphereContentReaction{
+			ID:       contentId,
+			SphereID: sphereId,
+			UserID:   userId,
+			ParentID: content.ParentID,
+			Symbol:   req.Symbol,
+			Action:   action,
+			Counts:   counts,
+		}
 
-		messageId := ksuid.New()
-		subject := "spheres.content.reacted." + sphereIdHex
-		_ = eventStore.PublishJSON(constants.StreamSpheres, subject, messageId.String(), result, nil)
+		if changed {
+			subject := "spheres.content.reacted." + sphereId
+			_ = rx.EventStore.PublishJSON(constants.StreamSpheres, subject, ksuid.New().String(), result, nil)
+		}
 
 		ownhttp.WriteJSON(w, 200, result)
 	}
 }
+
+// applyReactionCount ajusta el contador denormalizado y devuelve los contadores actuales.
+func applyReactionCount(ctx context.Context, contents *mongo.Collection, contentId bson.ObjectID, symbol string, changed, removed bool) (map[string]int, error) {
+	var doc struct {
+		Counts map[string]int `bson:"reactionCounts"`
+	}
+	proj := options.FindOne().SetProjection(bson.M{"reactionCounts": 1})
+	if !changed {
+		err := contents.FindOne(ctx, bson.M{"_id": contentId}, proj).Decode(&doc)
+		return orEmpty(doc.Counts), err
+	}
+
+	key := "reactionCounts." + symbol
+	delta := 1
+	if removed {
+		delta = -1
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"reactionCounts": 1})
+	err := contents.FindOneAndUpdate(ctx, bson.M{"_id": contentId}, bson.M{"$inc": bson.M{key: delta}}, opts).Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	// no se dejan símbolos en cero
+	if removed && doc.Counts[symbol] <= 0 {
+		_, err = contents.UpdateOne(ctx, bson.M{"_id": contentId, key: bson.M{"$lte": 0}}, bson.M{"$unset": bson.M{key: ""}})
+		delete(doc.Counts, symbol)
+	}
+	return orEmpty(doc.Counts), err
+}
+
+func orEmpty(m map[string]int) map[string]int {
+	if m == nil {
+		return map[string]int{}
+	}
+	return m
+}
+
+// GET /spheres/{sphereId}/contents/{contentId}/reactions?symbol=&cursor=&limit=
+func GetSphereContentReactions(rx *Reactions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sphereId := r.PathValue("sphereId")
+		contentId, err := bson.ObjectIDFromHex(r.PathValue("contentId"))
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid content id")
+			return
+		}
+
+		p, err := parsePage(r, 50, 200)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_CURSOR", err.Error())
+			return
+		}
+
+		var content struct {
+			Counts map[string]int `bson:"reactionCounts"`
+		}
+		err = rx.Contents.FindOne(r.Context(), bson.M{
+			"_id":          contentId,
+			"sphereId":     sphereId,
+			"deleted":      false,
+			"hidden":       bson.M{"$ne": true},
+			"mediaPending": bson.M{"$ne": true},
+		}, options.FindOne().SetProjection(bson.M{"reactionCounts": 1})).Decode(&content)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "content not found in sphere")
+			return
+		}
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+
+		match := bson.D{{Key: "contentId", Value: contentId}}
+		if symbol := r.URL.Query().Get("symbol"); symbol != "" {
+			match = append(match, bson.E{Key: "symbol", Value: symbol})
+		}
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: p.apply(match)}},
+			{{Key: "$sort", Value: p.sort()}},
+			{{Key: "$limit", Value: p.fetch()}},
+			{{Key: "$lookup", Value: usersLookup}},
+			{{Key: "$unwind", Value: bson.D{
+				{Key: "path", Value: "$user"},
+				{Key: "preserveNullAndEmptyArrays", Value: true},
+			}}},
+			{{Key: "$project", Value: bson.D{
+				{Key: "_id", Value: 1},
+				{Key: "symbol", Value: 1},
+				{Key: "user", Value: 1},
+				{Key: "createdAt", Value: 1},
+			}}},
+		}
+		cur, err := rx.Reactions.Aggregate(r.Context(), pipeline)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		defer cur.Close(r.Context())
+
+		items := []bson.M{}
+		if err := cur.All(r.Context(), &items); err != nil {
+			ownhttp.WriteJSONError(w, 500, "CURSOR_FAIL", err.Error())
+			return
+		}
+
+		items, next, prev := p.finish(items)
+		ownhttp.WriteJSON(w, 200, bson.M{
+			"counts":     orEmpty(content.Counts),
+			"items":      items,
+			"nextCursor": next,
+			"prevCursor": prev,
+		})
+	}
+}
+
+// GET /spheres/{sphereId}/reactions
+func GetSphereReactions(spheres *mongo.Collection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sphereId := r.PathValue("sphereId")
+		sphere, err := findSphere(r.Context(), spheres, sphereId)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "sphere not found")
+			return
+		}
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		ownhttp.WriteJSON(w, 200, bson.M{"sphereId": sphereId, "symbols": sphere.Reactions()})
+	}
+}
+
+// PUT /spheres/{sphereId}/reactions  {"symbols": [...]} (vacío vuelve al set por defecto)
+func SetSphereReactions(spheres, audit *mongo.Collection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sphereId := r.PathValue("sphereId")
+		if _, ok := requireModerator(w, r, spheres, sphereId); !ok {
+			return
+		}
+		p := ownhttp.PrincipalFrom(r.Context())
+
+		var req struct {
+			Symbols []string `json:"symbols"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_JSON", err.Error())
+			return
+		}
+
+		symbols := []string{}
+		for _, s := range req.Symbols {
+			s = strings.TrimSpace(s)
+			if !validReactionSymbol(s) {
+				ownhttp.WriteJSONError(w, 400, "BAD_SYMBOL", "invalid reaction symbol "+s)
+				return
+			}
+			if !slices.Contains(symbols, s) {
+				symbols = append(symbols, s)
+			}
+		}
+		if len(symbols) > maxSphereReactions {
+			ownhttp.WriteJSONError(w, 400, "TOO_MANY", "too many reaction symbols")
+			return
+		}
+
+		now := time.Now()
+		update := bson.M{"$set": bson.M{"allowedReactions": symbols, "lastUpdated": now}}
+		if len(symbols) == 0 {
+			update = bson.M{"$unset": bson.M{"allowedReactions": ""}, "$set": bson.M{"lastUpdated": now}}
+			symbols = models.DefaultReactions
+		}
+		if _, err := spheres.UpdateByID(r.Context(), sphereId, update); err != nil {
+			ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
+			return
+		}
+
+		writeAudit(r.Context(), audit, models.AuditEntry{
+			SphereID:  sphereId,
+			ActorID:   p.UserID,
+			Action:    "reactions.update",
+			Meta:      map[string]any{"symbols": symbols},
+			CreatedAt: now,
+		})
+
+		ownhttp.WriteJSON(w, 200, bson.M{"sphereId": sphereId, "symbols": symbols})
+	}
+}
+
+// la migración corre hasta completarse una vez; después queda la marca en spheres_migrations
+const reactionsMigrationID = "embedded-reactions-v1"
+
+// MigrateEmbeddedReactions pasa los arrays reactions.<symbol> viejos a sphere_reactions.
+// Los contadores se suman con $inc (solo lo que se insertó de verdad), así no pisa
+// reacciones nuevas que llegan mientras corre ni se duplica si corre en dos réplicas.
+func MigrateEmbeddedReactions(ctx context.Context, contents, reactions, migrations *mongo.Collection) {
+	err := migrations.FindOne(ctx, bson.M{"_id": reactionsMigrationID, "done": true}).Err()
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		logrus.WithError(err).Error("reactions migration: cannot read marker")
+		return
+	}
+
+	cur, err := contents.Find(ctx, bson.M{"reactions": bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"sphereId": 1, "reactions": 1, "createdAt": 1}))
+	if err != nil {
+		logrus.WithError(err).Error("reactions migration: query failed")
+		return
+	}
+	defer cur.Close(ctx)
+
+	migrated, failed := 0, 0
+	for cur.Next(ctx) {
+		var c struct {
+			ID        bson.ObjectID       `bson:"_id"`
+			SphereID  string              `bson:"sphereId"`
+			Reactions map[string][]string `bson:"reactions"`
+			CreatedAt time.Time           `bson:"createdAt"`
+		}
+		if err := cur.Decode(&c); err != nil {
+			failed++
+			continue
+		}
+
+		docs := []any{}
+		symbols := []string{}
+		for symbol, users := range c.Reactions {
+			if !validReactionSymbol(symbol) {
+				continue
+			}
+			for _, u := range users {
+				uid, err := bson.ObjectIDFromHex(u)
+				if err != nil {
+					continue
+				}
+				docs = append(docs, models.Reaction{
+					ID:        bson.NewObjectID(),
+					ContentID: c.ID,
+					SphereID:  c.SphereID,
+					UserID:    uid,
+					Symbol:    symbol,
+					CreatedAt: c.CreatedAt,
+				})
+				symbols = append(symbols, symbol)
+			}
+		}
+
+		// duplicados = ya reaccionó por el sistema nuevo (y ya se contó) o ya se migró
+		inserted := make([]bool, len(docs))
+		complete := true
+		if len(docs) > 0 {
+			for i := range inserted {
+				inserted[i] = true
+			}
+			_, err := reactions.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+			var bwe mongo.BulkWriteException
+			switch {
+			case err == nil:
+			case errors.As(err, &bwe):
+				for _, we := range bwe.WriteErrors {
+					inserted[we.Index] = false
+					if !mongo.IsDuplicateKeyError(we.WriteError) {
+						complete = false
+					}
+				}
+				if bwe.WriteConcernError != nil {
+					complete = false
+				}
+			default:
+				logrus.WithError(err).Errorf("reactions migration: content %s", c.ID.Hex())
+				failed++
+				continue
+			}
+		}
+
+		inc := bson.M{}
+		for i, ok := range inserted {
+			if ok {
+				key := "reactionCounts." + symbols[i]
+				n, _ := inc[key].(int)
+				inc[key] = n + 1
+			}
+		}
+		update := bson.M{}
+		if len(inc) > 0 {
+			update["$inc"] = inc
+		}
+		// si algo falló quedan los arrays: la próxima vez se inserta (y cuenta) lo que falta
+		if complete {
+			update["$unset"] = bson.M{"reactions": ""}
+		}
+		if len(update) > 0 {
+			if _, err := contents.UpdateByID(ctx, c.ID, update); err != nil {
+				logrus.WithError(err).Errorf("reactions migration: content %s", c.ID.Hex())
+				failed++
+				continue
+			}
+		}
+		if !complete {
+			logrus.Errorf("reactions migration: content %s partially migrated", c.ID.Hex())
+			failed++
+			continue
+		}
+		migrated++
+	}
+	if err := cur.Err(); err != nil {
+		logrus.WithError(err).Error("reactions migration: cursor failed")
+		return
+	}
+	if migrated > 0 {
+		logrus.Infof("reactions migration: %d contents migrated", migrated)
+	}
+	if failed > 0 {
+		logrus.Warnf("reactions migration: %d contents failed, retrying on next start", failed)
+		return
+	}
+
+	_, err = migrations.UpdateOne(ctx, bson.M{"_id": reactionsMigrationID},
+		bson.M{"$set": bson.M{"done": true, "migrated": migrated, "doneAt": time.Now()}},
+		options.UpdateOne().SetUpsert(true))
+	if err != nil {
+		logrus.WithError(err).Warn("reactions migration: cannot store marker")
+	}
+}
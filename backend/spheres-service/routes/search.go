@@ -0,0 +1,207 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"moonmap.io/go-commons/ownhttp"
+	"moonmap.io/go-commons/typesense"
+)
+
+// GET /spheres/{sphereId}/search?q=&author=&from=&to=&hasMedia=&mention=&cashtag=&page=&perPage=
+func SearchSphereContents(ts *typesense.Client, tsColl string, collection, assets *mongo.Collection) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sphereId := r.PathValue("sphereId")
+		qs := r.URL.Query()
+
+		filters := []string{"sphereId:=" + typesense.FilterValue(sphereId)}
+		if author := qs.Get("author"); author != "" {
+			if _, err := bson.ObjectIDFromHex(author); err != nil {
+				ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid author")
+				return
+			}
+			filters = append(filters, "userId:="+typesense.FilterValue(author))
+		}
+		for param, op := range map[string]string{"from": ">=", "to": "<="} {
+			v := qs.Get(param)
+			if v == "" {
+				continue
+			}
+			t, ok := parseTimeParam(v)
+			if !ok {
+				ownhttp.WriteJSONError(w, 400, "BAD_DATE", "invalid "+param+", use RFC3339 or unix seconds")
+				return
+			}
+			filters = append(filters, "createdAt:"+op+strconv.FormatInt(t.Unix(), 10))
+		}
+		if v := qs.Get("hasMedia"); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				ownhttp.WriteJSONError(w, 400, "BAD_REQUEST", "hasMedia must be true or false")
+				return
+			}
+			filters = append(filters, "hasMedia:="+strconv.FormatBool(b))
+		}
+		if v := facetValues(qs.Get("mention"), "@", strings.ToLower); v != "" {
+			filters = append(filters, "mentions:=["+v+"]")
+		}
+		if v := facetValues(qs.Get("cashtag"), "$", strings.ToUpper); v != "" {
+			filters = append(filters, "cashtags:=["+v+"]")
+		}
+
+		q := strings.TrimSpace(qs.Get("q"))
+		if q == "" && len(filters) == 1 {
+			ownhttp.WriteJSONError(w, 400, "EMPTY_QUERY", "q or a filter is required")
+			return
+		}
+		sortBy := "_text_match:desc,createdAt:desc"
+		if q == "" {
+			q, sortBy = "*", "createdAt:desc"
+		}
+
+		page := max(intParam(qs, "page", 1), 1)
+		perPage := min(max(intParam(qs, "perPage", 20), 1), 50)
+
+		params := url.Values{}
+		params.Set("q", q)
+		params.Set("query_by", "text,authorName")
+		params.Set("filter_by", strings.Join(filters, " && "))
+		params.Set("facet_by", "mentions,cashtags")
+		params.Set("max_facet_values", "10")
+		params.Set("sort_by", sortBy)
+		params.Set("highlight_fields", "text")
+		params.Set("page", strconv.Itoa(page))
+		params.Set("per_page", strconv.Itoa(perPage))
+
+		res, err := ts.Search(r.Context(), tsColl, params)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 502, "SEARCH_FAIL", err.Error())
+			return
+		}
+
+		// se hidrata desde mongo para devolver el mismo shape que el feed
+		ids := make([]bson.ObjectID, 0, len(res.Hits))
+		snippets := map[string]string{}
+		for _, h := range res.Hits {
+			var doc struct {
+				ID string `json:"id"`
+			}
+			if json.Unmarshal(h.Document, &doc) != nil {
+				continue
+			}
+			oid, err := bson.ObjectIDFromHex(doc.ID)
+			if err != nil {
+				continue
+			}
+			ids = append(ids, oid)
+			for _, hl := range h.Highlights {
+				if hl.Field == "text" {
+					snippets[doc.ID] = hl.Snippet
+				}
+			}
+		}
+
+		items := []bson.M{}
+		if len(ids) > 0 {
+			pipeline := mongo.Pipeline{
+				{{Key: "$match", Value: bson.D{
+					{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}},
+					{Key: "sphereId", Value: sphereId},
+					{Key: "deleted", Value: false},
+					{Key: "hidden", Value: bson.D{{Key: "$ne", Value: true}}},
+					mediaSettled,
+				}}},
+				{{Key: "$lookup", Value: usersLookup}},
+				{{Key: "$unwind", Value: bson.D{
+					{Key: "path", Value: "$user"},
+					{Key: "preserveNullAndEmptyArrays", Value: true},
+				}}},
+				{{Key: "$project", Value: endProjection}},
+			}
+			cur, err := collection.Aggregate(r.Context(), pipeline)
+			if err != nil {
+				ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+				return
+			}
+			defer cur.Close(r.Context())
+
+			docs := []bson.M{}
+			if err := cur.All(r.Context(), &docs); err != nil {
+				ownhttp.WriteJSONError(w, 500, "CURSOR_FAIL", err.Error())
+				return
+			}
+			if err := attachMedia(r.Context(), assets, docs); err != nil {
+				ownhttp.WriteJSONError(w, 500, "MEDIA_FAIL", err.Error())
+				return
+			}
+
+			// orden de relevancia de typesense
+			byID := make(map[bson.ObjectID]bson.M, len(docs))
+			for _, d := range docs {
+				if id, ok := d["_id"].(bson.ObjectID); ok {
+					byID[id] = d
+				}
+			}
+			for _, id := range ids {
+				if d, ok := byID[id]; ok {
+					if s, ok := snippets[id.Hex()]; ok {
+						d["highlight"] = s
+					}
+					items = append(items, d)
+				}
+			}
+		}
+
+		facets := bson.M{"mentions": []bson.M{}, "cashtags": []bson.M{}}
+		for _, f := range res.FacetCounts {
+			counts := make([]bson.M, 0, len(f.Counts))
+			for _, c := range f.Counts {
+				counts = append(counts, bson.M{"value": c.Value, "count": c.Count})
+			}
+			facets[f.FieldName] = counts
+		}
+
+		ownhttp.WriteJSON(w, 200, bson.M{
+			"found":   res.Found,
+			"page":    page,
+			"perPage": perPage,
+			"items":   items,
+			"facets":  facets,
+		})
+	}
+}
+
+// "btc,@alice" -> "`btc`,`alice`"
+func facetValues(raw, prefix string, norm func(string) string) string {
+	var out []string
+	for _, v := range strings.Split(raw, ",") {
+		v = norm(strings.TrimPrefix(strings.TrimSpace(v), prefix))
+		if v != "" {
+			out = append(out, typesense.FilterValue(v))
+		}
+	}
+	return strings.Join(out, ",")
+}
+
+func parseTimeParam(v string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, true
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(n, 0), true
+	}
+	return time.Time{}, false
+}
+
+func intParam(qs url.Values, key string, def int) int {
+	if n, err := strconv.Atoi(qs.Get(key)); err == nil {
+		return n
+	}
+	return def
+}
@@ -1,53 +1,854 @@
 package routes
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
+	"maps"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/segmentio/ksuid"
+	"github.com/sirupsen/logrus"
+	"moonmap.io/go-commons/constants"
+	"moonmap.io/go-commons/helpers"
 	"moonmap.io/go-commons/ownhttp"
+	"moonmap.io/go-commons/system"
+	"moonmap.io/spheres-service/models"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
-// POST /spheres
-func CreateSphere(collection *mongo.Collection) http.HandlerFunc {
+const (
+	maxSphereTitle       = 80
+	maxSphereDescription = 1000
+	maxSphereRules       = 20
+	maxSphereRule        = 300
+)
+
+// colecciones que usa el ciclo de vida de las spheres
+type Spheres struct {
+	Spheres   *mongo.Collection
+	Members   *mongo.Collection
+	Assets    *mongo.Collection // media_assets (s3-service)
+	Mints     *mongo.Collection
+	Projects  *mongo.Collection
+	Waves     *mongo.Collection // waves_sessions (waves-service)
+	Sanctions *mongo.Collection
+	Audit     *mongo.Collection
+
+	EventStore *system.NatsEventStore
+}
+
+func (sp *Spheres) publish(action, sphereId string, data any) {
+	subject := "spheres.sphere." + action + "." + sphereId
+	_ = sp.EventStore.PublishJSON(constants.StreamSpheres, subject, ksuid.New().String(), data, nil)
+}
+
+// verifyMint: el mint tiene que estar indexado (mints) o ser el CA de un proyecto
+func (sp *Spheres) verifyMint(ctx context.Context, mint string) (bool, error) {
+	err := sp.Mints.FindOne(ctx, bson.M{"$or": bson.A{bson.M{"_id": mint}, bson.M{"mint": mint}}}).Err()
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return false, err
+	}
+	err = sp.Projects.FindOne(ctx, bson.M{"contractAddress": mint}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// sphereMeta: campos editables (nil = no se tocan)
+type sphereMeta struct {
+	Title         *string   `json:"title"`
+	Description   *string   `json:"description"`
+	Rules         *[]string `json:"rules"`
+	BannerMediaID *string   `json:"bannerMediaId"` // "" lo quita
+	PostingMode   *string   `json:"postingMode"`
+}
+
+// validate normaliza y devuelve el $set/$unset
+func (m *sphereMeta) validate() (set, unset bson.M, code, msg string) {
+	set, unset = bson.M{}, bson.M{}
+	if m.Title != nil {
+		t := strings.TrimSpace(*m.Title)
+		if utf8.RuneCountInString(t) > maxSphereTitle {
+			return nil, nil, "BAD_TITLE", "title too long"
+		}
+		set["title"] = t
+	}
+	if m.Description != nil {
+		d := strings.TrimSpace(*m.Description)
+		if utf8.RuneCountInString(d) > maxSphereDescription {
+			return nil, nil, "BAD_DESCRIPTION", "description too long"
+		}
+		set["description"] = d
+	}
+	if m.Rules != nil {
+		rules := []string{}
+		for _, r := range *m.Rules {
+			r = strings.TrimSpace(r)
+			if r == "" {
+				continue
+			}
+			if utf8.RuneCountInString(r) > maxSphereRule {
+				return nil, nil, "BAD_RULES", "rule too long"
+			}
+			rules = append(rules, r)
+		}
+		if len(rules) > maxSphereRules {
+			return nil, nil, "BAD_RULES", "too many rules"
+		}
+		set["rules"] = rules
+	}
+	if m.PostingMode != nil {
+		if *m.PostingMode != models.PostingOpen && *m.PostingMode != models.PostingReadOnly {
+			return nil, nil, "BAD_POSTING_MODE", "postingMode must be open or read_only"
+		}
+		set["postingMode"] = *m.PostingMode
+	}
+	if m.BannerMediaID != nil && *m.BannerMediaID == "" {
+		unset["bannerMediaId"] = ""
+	}
+	return set, unset, "", ""
+}
+
+// checkBanner: el banner sube por s3-service (namespace spheres, profile banner) y es del usuario
+func (sp *Spheres) checkBanner(w http.ResponseWriter, r *http.Request, sphereId, userId, raw string) (*bson.ObjectID, bool) {
+	oid, err := bson.ObjectIDFromHex(raw)
+	if err != nil {
+		ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid bannerMediaId")
+		return nil, false
+	}
+	assets, err := loadAssets(r.Context(), sp.Assets, []bson.ObjectID{oid})
+	if err != nil {
+		ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+		return nil, false
+	}
+	a, ok := assets[oid]
+	if !ok || !a.IsSphereUpload(sphereId, models.MediaProfileBanner, userId) {
+		ownhttp.WriteJSONError(w, 400, "MEDIA_INVALID", "banner must be a spheres/banner upload for this sphere")
+		return nil, false
+	}
+	if a.Status == models.MediaFailed {
+		ownhttp.WriteJSONError(w, 400, "MEDIA_INVALID", "banner processing failed")
+		return nil, false
+	}
+	return &oid, true
+}
+
+// sphereView agrega el banner resuelto (urls solo cuando está ready)
+func (sp *Spheres) sphereViews(ctx context.Context, spheres []models.Sphere) ([]bson.M, error) {
+	ids := []bson.ObjectID{}
+	for _, s := range spheres {
+		if s.BannerMediaID != nil {
+			ids = append(ids, *s.BannerMediaID)
+		}
+	}
+	assets, err := loadAssets(ctx, sp.Assets, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]bson.M, 0, len(spheres))
+	for _, s := range spheres {
+		if s.PostingMode == "" {
+			s.PostingMode = models.PostingOpen
+		}
+		if s.Rules == nil {
+			s.Rules = []string{}
+		}
+		v := bson.M{
+			"_id":              s.ID,
+			"mint":             s.ID,
+			"createdBy":        s.CreatedBy,
+			"moderators":       s.Moderators,
+			"title":            s.Title,
+			"description":      s.Description,
+			"rules":            s.Rules,
+			"postingMode":      s.PostingMode,
+			"allowedReactions": s.Reactions(),
+			"memberCount":      s.MemberCount,
+			"lastPostAt":       s.LastPostAt,
+			"createdAt":        s.CreatedAt,
+			"lastUpdated":      s.LastUpdated,
+			"banner":           nil,
+		}
+		if s.BannerMediaID != nil {
+			if a, ok := assets[*s.BannerMediaID]; ok {
+				v["banner"] = a.View()
+			}
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func (sp *Spheres) writeSphere(w http.ResponseWriter, r *http.Request, status int, sphere *models.Sphere) {
+	views, err := sp.sphereViews(r.Context(), []models.Sphere{*sphere})
+	if err != nil {
+		ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+		return
+	}
+	ownhttp.WriteJSON(w, status, views[0])
+}
+
+// POST /spheres  {"mintId", "title", "description", "rules", "postingMode"}
+func CreateSphere(sp *Spheres) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			ownhttp.WriteJSONError(w, 405, "NOT_ALLOWED", "method")
+		p, userId, ok := principalUser(w, r)
+		if !ok {
 			return
 		}
 
 		var req struct {
-			MintID    string `json:"mintId"`
-			CreatedBy string `json:"createdBy"`
+			MintID string `json:"mintId"`
+			sphereMeta
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			ownhttp.WriteJSONError(w, 400, "BAD_JSON", "decode")
 			return
 		}
+		req.MintID = strings.TrimSpace(req.MintID)
+		if _, err := helpers.Base58ToPublicKey(req.MintID); err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_MINT", "mintId is not a valid solana address")
+			return
+		}
+		if req.BannerMediaID != nil {
+			// el banner se sube con la sphere ya creada (scopeId = mint)
+			ownhttp.WriteJSONError(w, 400, "BAD_REQUEST", "set the banner with PATCH after creating the sphere")
+			return
+		}
+		set, _, code, msg := req.validate()
+		if code != "" {
+			ownhttp.WriteJSONError(w, 400, code, msg)
+			return
+		}
+
+		exists, err := sp.verifyMint(r.Context(), req.MintID)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		if !exists {
+			ownhttp.WriteJSONError(w, 422, "MINT_NOT_FOUND", "unknown mint")
+			return
+		}
+
+		now := time.Now()
+		sphere := models.Sphere{
+			ID:          req.MintID,
+			Mint:        req.MintID,
+			CreatedBy:   p.UserID,
+			Moderators:  []string{},
+			PostingMode: models.PostingOpen,
+			Rules:       []string{},
+			MemberCount: 1,
+			CreatedAt:   now,
+			LastUpdated: now,
+		}
+		if v, ok := set["title"].(string); ok {
+			sphere.Title = v
+		}
+		if v, ok := set["description"].(string); ok {
+			sphere.Description = v
+		}
+		if v, ok := set["rules"].([]string); ok {
+			sphere.Rules = v
+		}
+		if v, ok := set["postingMode"].(string); ok {
+			sphere.PostingMode = v
+		}
+
+		if _, err := sp.Spheres.InsertOne(r.Context(), sphere); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				ownhttp.WriteJSONError(w, 409, "SPHERE_EXISTS", "sphere already exists for this mint")
+				return
+			}
+			ownhttp.WriteJSONError(w, 500, "INSERT_FAIL", err.Error())
+			return
+		}
+
+		_, err = sp.Members.InsertOne(r.Context(), models.SphereMember{
+			ID:       bson.NewObjectID(),
+			SphereID: sphere.ID,
+			UserID:   userId,
+			Role:     models.RoleOwner,
+			JoinedAt: now,
+		})
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			logrus.WithError(err).Errorf("sphere %s: failed to add owner membership", sphere.ID)
+		}
+
+		sp.publish("created", sphere.ID, sphere)
+		sp.writeSphere(w, r, 201, &sphere)
+	}
+}
+
+// GET /spheres/{sphereId}
+func GetSphere(sp *Spheres) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sphere, err := findSphere(r.Context(), sp.Spheres, r.PathValue("sphereId"))
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "sphere not found")
+			return
+		}
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		sp.writeSphere(w, r, 200, sphere)
+	}
+}
+
+// PATCH /spheres/{sphereId}  (moderadores; postingMode solo owner/admin)
+func UpdateSphere(sp *Spheres) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sphereId := r.PathValue("sphereId")
+		sphere, ok := requireModerator(w, r, sp.Spheres, sphereId)
+		if !ok {
+			return
+		}
+		p := ownhttp.PrincipalFrom(r.Context())
+
+		var req sphereMeta
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_JSON", err.Error())
+			return
+		}
+		set, unset, code, msg := req.validate()
+		if code != "" {
+			ownhttp.WriteJSONError(w, 400, code, msg)
+			return
+		}
+		if req.PostingMode != nil && sphere.CreatedBy != p.UserID && !p.HasRole(adminRole) {
+			ownhttp.WriteJSONError(w, 403, "FORBIDDEN", "only the sphere owner can change the posting mode")
+			return
+		}
+		if req.BannerMediaID != nil && *req.BannerMediaID != "" {
+			oid, ok := sp.checkBanner(w, r, sphereId, p.UserID, *req.BannerMediaID)
+			if !ok {
+				return
+			}
+			set["bannerMediaId"] = *oid
+		}
+		if len(set) == 0 && len(unset) == 0 {
+			ownhttp.WriteJSONError(w, 400, "EMPTY_UPDATE", "nothing to update")
+			return
+		}
+
+		now := time.Now()
+		changed := make([]string, 0, len(set)+len(unset))
+		for k := range set {
+			changed = append(changed, k)
+		}
+		for k := range unset {
+			changed = append(changed, k)
+		}
+		slices.Sort(changed)
+		set["lastUpdated"] = now
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+
+		var updated models.Sphere
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		err := sp.Spheres.FindOneAndUpdate(r.Context(), bson.M{"_id": sphereId}, update, opts).Decode(&updated)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "sphere not found")
+			return
+		}
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
+			return
+		}
+
+		writeAudit(r.Context(), sp.Audit, models.AuditEntry{
+			SphereID:  sphereId,
+			ActorID:   p.UserID,
+			Action:    "sphere.update",
+			Meta:      map[string]any{"fields": changed},
+			CreatedAt: now,
+		})
+		sp.publish("updated", sphereId, bson.M{"_id": sphereId, "fields": changed, "updatedAt": now})
+		sp.writeSphere(w, r, 200, &updated)
+	}
+}
+
+// GET /spheres?sort=activity|members|new&page=&perPage=
+// activity: participantes en waves activas y después último post
+func ListSpheres(sp *Spheres) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		qs := r.URL.Query()
+		page := max(intParam(qs, "page", 1), 1)
+		perPage := min(max(intParam(qs, "perPage", 20), 1), 50)
+
+		sortBy := qs.Get("sort")
+		var sort bson.D
+		switch sortBy {
+		case "", "activity":
+			sortBy = "activity"
+			sort = bson.D{{Key: "lastPostAt", Value: -1}, {Key: "_id", Value: 1}}
+		case "members":
+			sort = bson.D{{Key: "memberCount", Value: -1}, {Key: "_id", Value: 1}}
+		case "new":
+			sort = bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: 1}}
+		default:
+			ownhttp.WriteJSONError(w, 400, "BAD_SORT", "sort must be activity, members or new")
+			return
+		}
+
+		// waves activas: pocas y por índice (active); la cuenta se arma acá y no con
+		// un $lookup por sphere
+		live, err := sp.liveCounts(r.Context())
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+
+		offset := int64((page - 1) * perPage)
+		limit := int64(perPage)
+		var spheres []models.Sphere
+		filter := bson.M{}
+		if sortBy == "activity" {
+			// primero las que tienen wave en vivo (liveCount desc), después el resto por lastPostAt
+			liveSpheres, err := sp.findSpheres(r.Context(), bson.M{"_id": bson.M{"$in": slices.Collect(maps.Keys(live))}}, nil)
+			if err != nil {
+				ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+				return
+			}
+			slices.SortFunc(liveSpheres, func(a, b models.Sphere) int {
+				if c := cmp.Compare(live[b.ID], live[a.ID]); c != 0 {
+					return c
+				}
+				if c := compareTimeDesc(a.LastPostAt, b.LastPostAt); c != 0 {
+					return c
+				}
+				return cmp.Compare(a.ID, b.ID)
+			})
+			if offset < int64(len(liveSpheres)) {
+				spheres = slices.Clone(liveSpheres[offset:min(offset+limit, int64(len(liveSpheres)))])
+				limit -= int64(len(spheres))
+				offset = 0
+			} else {
+				offset -= int64(len(liveSpheres))
+			}
+			ids := make([]string, 0, len(liveSpheres))
+			for _, l := range liveSpheres {
+				ids = append(ids, l.ID)
+			}
+			filter["_id"] = bson.M{"$nin": ids}
+		}
+		if limit > 0 {
+			rest, err := sp.findSpheres(r.Context(), filter, options.Find().SetSort(sort).SetSkip(offset).SetLimit(limit))
+			if err != nil {
+				ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+				return
+			}
+			spheres = append(spheres, rest...)
+		}
+
+		items, err := sp.sphereViews(r.Context(), spheres)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		for i := range items {
+			items[i]["liveCount"] = live[spheres[i].ID]
+		}
 
-		sphere := bson.M{
-			"_id":         req.MintID,
-			"createdBy":   req.CreatedBy,
-			"createdAt":   time.Now(),
-			"lastUpdated": time.Now(),
+		ownhttp.WriteJSON(w, 200, bson.M{"page": page, "perPage": perPage, "items": items})
+	}
+}
+
+// liveCounts: participantes en waves activas por sphere (solo las que tienen alguna)
+func (sp *Spheres) liveCounts(ctx context.Context) (map[string]int64, error) {
+	cur, err := sp.Waves.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "active", Value: true}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$sphereId"},
+			{Key: "n", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$size", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$participants", bson.A{}}}}}}}}},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "n", Value: bson.D{{Key: "$gt", Value: 0}}}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		ID string `bson:"_id"`
+		N  int64  `bson:"n"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	out := make(map[string]int64, len(rows))
+	for _, r := range rows {
+		out[r.ID] = r.N
+	}
+	return out, nil
+}
+
+func (sp *Spheres) findSpheres(ctx context.Context, filter bson.M, opts *options.FindOptionsBuilder) ([]models.Sphere, error) {
+	if opts == nil {
+		opts = options.Find()
+	}
+	cur, err := sp.Spheres.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	out := []models.Sphere{}
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// compareTimeDesc: más nuevo primero, sin fecha al final (como el $sort de mongo)
+func compareTimeDesc(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return b.Compare(*a)
+}
+
+// POST /spheres/{sphereId}/join
+func JoinSphere(sp *Spheres) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, userId, ok := principalUser(w, r)
+		if !ok {
+			return
+		}
+		sphereId := r.PathValue("sphereId")
+		sphere, err := findSphere(r.Context(), sp.Spheres, sphereId)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "sphere not found")
+			return
+		}
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
 		}
 
-		inserted, err := collection.InsertOne(r.Context(), sphere)
+		// los muteados pueden unirse; los baneados no
+		s, err := activeSanction(r.Context(), sp.Sanctions, sphereId, userId)
 		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		if s != nil && s.Type == models.SanctionBan {
+			ownhttp.WriteJSONError(w, 403, "BANNED", "you are banned from this sphere")
+			return
+		}
+
+		role := sphere.RoleOf(p.UserID)
+		_, err = sp.Members.InsertOne(r.Context(), models.SphereMember{
+			ID:       bson.NewObjectID(),
+			SphereID: sphereId,
+			UserID:   userId,
+			Role:     role,
+			JoinedAt: time.Now(),
+		})
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
 			ownhttp.WriteJSONError(w, 500, "INSERT_FAIL", err.Error())
 			return
 		}
+		joined := err == nil
 
-		mintId, ok := inserted.InsertedID.(string)
+		count, err := sp.bumpMembers(r.Context(), sphereId, joined, 1)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
+			return
+		}
+		if joined {
+			sp.publish("joined", sphereId, bson.M{"sphereId": sphereId, "userId": p.UserID, "role": role, "memberCount": count})
+		}
+		ownhttp.WriteJSON(w, 200, bson.M{"sphereId": sphereId, "member": true, "role": role, "memberCount": count})
+	}
+}
+
+// POST /spheres/{sphereId}/leave  (el owner no puede irse)
+func LeaveSphere(sp *Spheres) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, userId, ok := principalUser(w, r)
 		if !ok {
-			ownhttp.WriteJSONError(w, 500, "INSERT_FAIL", "invalid objectid creating sphere")
 			return
 		}
+		sphereId := r.PathValue("sphereId")
+		sphere, err := findSphere(r.Context(), sp.Spheres, sphereId)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "sphere not found")
+			return
+		}
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		if sphere.CreatedBy == p.UserID {
+			ownhttp.WriteJSONError(w, 409, "OWNER_CANNOT_LEAVE", "the owner cannot leave the sphere")
+			return
+		}
+
+		res, err := sp.Members.DeleteOne(r.Context(), bson.M{"sphereId": sphereId, "userId": userId})
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "DELETE_FAIL", err.Error())
+			return
+		}
+		left := res.DeletedCount == 1
 
-		sphere["_id"] = mintId
-		ownhttp.WriteJSON(w, 201, sphere)
+		// un moderador que se va deja de moderar
+		if slices.Contains(sphere.Moderators, p.UserID) {
+			_, err := sp.Spheres.UpdateByID(r.Context(), sphereId, bson.M{
+				"$pull": bson.M{"moderators": p.UserID},
+				"$set":  bson.M{"lastUpdated": time.Now()},
+			})
+			if err != nil {
+				ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
+				return
+			}
+			writeAudit(r.Context(), sp.Audit, models.AuditEntry{
+				SphereID:     sphereId,
+				ActorID:      p.UserID,
+				Action:       "moderator.remove",
+				TargetUserID: p.UserID,
+				Reason:       "left sphere",
+			})
+		}
+
+		count, err := sp.bumpMembers(r.Context(), sphereId, left, -1)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
+			return
+		}
+		if left {
+			sp.publish("left", sphereId, bson.M{"sphereId": sphereId, "userId": p.UserID, "memberCount": count})
+		}
+		ownhttp.WriteJSON(w, 200, bson.M{"sphereId": sphereId, "member": false, "memberCount": count})
+	}
+}
+
+// bumpMembers ajusta memberCount si hubo cambio y devuelve el valor actual.
+func (sp *Spheres) bumpMembers(ctx context.Context, sphereId string, changed bool, delta int) (int64, error) {
+	var doc struct {
+		MemberCount int64 `bson:"memberCount"`
+	}
+	proj := bson.M{"memberCount": 1}
+	if !changed {
+		err := sp.Spheres.FindOne(ctx, bson.M{"_id": sphereId}, options.FindOne().SetProjection(proj)).Decode(&doc)
+		return doc.MemberCount, err
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(proj)
+	err := sp.Spheres.FindOneAndUpdate(ctx, bson.M{"_id": sphereId}, bson.M{"$inc": bson.M{"memberCount": delta}}, opts).Decode(&doc)
+	return doc.MemberCount, err
+}
+
+// GET /spheres/{sphereId}/members?role=&cursor=&limit=
+func GetSphereMembers(sp *Spheres) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sphereId := r.PathValue("sphereId")
+		p, err := parsePage(r, 50, 200)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_CURSOR", err.Error())
+			return
+		}
+
+		match := bson.D{{Key: "sphereId", Value: sphereId}}
+		if role := r.URL.Query().Get("role"); role != "" {
+			if role != models.RoleOwner && role != models.RoleMod && role != models.RoleMember {
+				ownhttp.WriteJSONError(w, 400, "BAD_ROLE", "role must be owner, mod or member")
+				return
+			}
+			match = append(match, bson.E{Key: "role", Value: role})
+		}
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: p.apply(match)}},
+			{{Key: "$sort", Value: p.sort()}},
+			{{Key: "$limit", Value: p.fetch()}},
+			{{Key: "$lookup", Value: usersLookup}},
+			{{Key: "$unwind", Value: bson.D{
+				{Key: "path", Value: "$user"},
+				{Key: "preserveNullAndEmptyArrays", Value: true},
+			}}},
+			{{Key: "$project", Value: bson.D{
+				{Key: "_id", Value: 1},
+				{Key: "role", Value: 1},
+				{Key: "user", Value: 1},
+				{Key: "joinedAt", Value: 1},
+			}}},
+		}
+		cur, err := sp.Members.Aggregate(r.Context(), pipeline)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		defer cur.Close(r.Context())
+
+		items := []bson.M{}
+		if err := cur.All(r.Context(), &items); err != nil {
+			ownhttp.WriteJSONError(w, 500, "CURSOR_FAIL", err.Error())
+			return
+		}
+		items, next, prev := p.finish(items)
+		ownhttp.WriteJSON(w, 200, bson.M{"items": items, "nextCursor": next, "prevCursor": prev})
+	}
+}
+
+// GET /spheres/{sphereId}/membership  (del usuario autenticado)
+func GetSphereMembership(sp *Spheres) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, userId, ok := principalUser(w, r)
+		if !ok {
+			return
+		}
+		sphereId := r.PathValue("sphereId")
+		var m models.SphereMember
+		err := sp.Members.FindOne(r.Context(), bson.M{"sphereId": sphereId, "userId": userId}).Decode(&m)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSON(w, 200, bson.M{"sphereId": sphereId, "member": false, "role": nil})
+			return
+		}
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		ownhttp.WriteJSON(w, 200, bson.M{"sphereId": sphereId, "member": true, "role": m.Role, "joinedAt": m.JoinedAt})
+	}
+}
+
+// solo el creador de la sphere (o un admin) administra moderadores
+// PUT /spheres/{sphereId}/moderators/{userId}
+// DELETE /spheres/{sphereId}/moderators/{userId}
+func SetSphereModerator(sp *Spheres) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, _, ok := principalUser(w, r)
+		if !ok {
+			return
+		}
+
+		sphereId := r.PathValue("sphereId")
+		target := r.PathValue("userId")
+		targetId, err := bson.ObjectIDFromHex(target)
+		if err != nil {
+			ownhttp.WriteJSONError(w, 400, "BAD_OBJECT_ID", "invalid userId")
+			return
+		}
+
+		sphere, err := findSphere(r.Context(), sp.Spheres, sphereId)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			ownhttp.WriteJSONError(w, 404, "NOT_FOUND", "sphere not found")
+			return
+		}
+		if err != nil {
+			ownhttp.WriteJSONError(w, 500, "QUERY_FAIL", err.Error())
+			return
+		}
+		if sphere.CreatedBy != p.UserID && !p.HasRole(adminRole) {
+			ownhttp.WriteJSONError(w, 403, "FORBIDDEN", "only the sphere owner can manage moderators")
+			return
+		}
+		if target == sphere.CreatedBy {
+			ownhttp.WriteJSONError(w, 400, "BAD_REQUEST", "the owner is always a moderator")
+			return
+		}
+
+		now := time.Now()
+		action := "moderator.add"
+		role := models.RoleMod
+		update := bson.M{"$addToSet": bson.M{"moderators": target}, "$set": bson.M{"lastUpdated": now}}
+		if r.Method == http.MethodDelete {
+			action = "moderator.remove"
+			role = models.RoleMember
+			update = bson.M{"$pull": bson.M{"moderators": target}, "$set": bson.M{"lastUpdated": now}}
+		}
+
+		if _, err := sp.Spheres.UpdateByID(r.Context(), sphereId, update); err != nil {
+			ownhttp.WriteJSONError(w, 500, "UPDATE_FAIL", err.Error())
+			return
+		}
+
+		// el rol de la membresía acompaña; un moderador nuevo queda como miembro
+		filter := bson.M{"sphereId": sphereId, "userId": targetId}
+		if role == models.RoleMod {
+			res, err := sp.Members.UpdateOne(r.Context(), filter, bson.M{
+				"$set":         bson.M{"role": role},
+				"$setOnInsert": bson.M{"_id": bson.NewObjectID(), "joinedAt": now},
+			}, options.UpdateOne().SetUpsert(true))
+			if err == nil && res.UpsertedCount == 1 {
+				_, err = sp.bumpMembers(r.Context(), sphereId, true, 1)
+			}
+			if err != nil {
+				logrus.WithError(err).Errorf("sphere %s: failed to sync member role", sphereId)
+			}
+		} else if _, err := sp.Members.UpdateOne(r.Context(), filter, bson.M{"$set": bson.M{"role": role}}); err != nil {
+			logrus.WithError(err).Errorf("sphere %s: failed to sync member role", sphereId)
+		}
+
+		writeAudit(r.Context(), sp.Audit, models.AuditEntry{
+			SphereID:     sphereId,
+			ActorID:      p.UserID,
+			Action:       action,
+			TargetUserID: target,
+			CreatedAt:    now,
+		})
+
+		ownhttp.WriteJSON(w, 200, bson.M{"sphereId": sphereId, "userId": target, "action": action})
+	}
+}
+
+// BackfillSpheres completa los documentos viejos ({_id, createdBy}): mint para el
+// índice único, membresía del owner y memberCount. Es idempotente.
+func BackfillSpheres(ctx context.Context, spheres, members *mongo.Collection) {
+	_, err := spheres.UpdateMany(ctx, bson.M{"mint": bson.M{"$exists": false}}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{{Key: "mint", Value: "$_id"}}}},
+	})
+	if err != nil {
+		logrus.WithError(err).Error("spheres backfill: mint")
+	}
+
+	cur, err := spheres.Find(ctx, bson.M{"memberCount": bson.M{"$exists": false}})
+	if err != nil {
+		logrus.WithError(err).Error("spheres backfill: query")
+		return
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var s models.Sphere
+		if err := cur.Decode(&s); err != nil {
+			continue
+		}
+		if owner, err := bson.ObjectIDFromHex(s.CreatedBy); err == nil {
+			_, err := members.InsertOne(ctx, models.SphereMember{
+				ID:       bson.NewObjectID(),
+				SphereID: s.ID,
+				UserID:   owner,
+				Role:     models.RoleOwner,
+				JoinedAt: s.CreatedAt,
+			})
+			if err != nil && !mongo.IsDuplicateKeyError(err) {
+				logrus.WithError(err).Errorf("spheres backfill: owner of %s", s.ID)
+				continue
+			}
+		}
+		n, err := members.CountDocuments(ctx, bson.M{"sphereId": s.ID})
+		if err != nil {
+			continue
+		}
+		_, _ = spheres.UpdateByID(ctx, s.ID, bson.M{"$set": bson.M{"memberCount": n}})
 	}
 }
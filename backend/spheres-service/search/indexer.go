@@ -0,0 +1,246 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"moonmap.io/go-commons/constants"
+	"moonmap.io/go-commons/helpers"
+	"moonmap.io/go-commons/system"
+	"moonmap.io/go-commons/typesense"
+	"moonmap.io/spheres-service/models"
+)
+
+const indexerConsumer = "spheres-search-indexer"
+
+// Indexer mantiene la colección de typesense al día con los eventos spheres.content.*
+type Indexer struct {
+	TS         *typesense.Client
+	Collection string
+	Contents   *mongo.Collection
+	Wallets    *mongo.Collection
+}
+
+// Start asegura el schema y crea el consumer durable (compartido entre réplicas).
+func (ix *Indexer) Start(ctx context.Context, es *system.NatsEventStore) {
+	created, err := ix.TS.EnsureCollection(ctx, typesense.SphereContentsSchema(ix.Collection))
+	if err != nil {
+		logrus.WithError(err).Error("typesense: cannot ensure sphere contents collection, search indexing disabled")
+		return
+	}
+	if created {
+		logrus.Infof("typesense collection %s created", ix.Collection)
+	}
+	// el consumer arranca con DeliverNew: lo que ya estaba en mongo entra por el backfill
+	if created || helpers.GetEnvBool("SEARCH_BACKFILL", false) {
+		go func() {
+			if err := ix.Backfill(ctx); err != nil {
+				logrus.WithError(err).Error("search indexer: backfill failed")
+			}
+		}()
+	}
+
+	es.CreateConsumer(constants.StreamSpheres, indexerConsumer, []string{
+		"spheres.content.added.*",
+		"spheres.content.updated.*",
+		"spheres.content.deleted.*",
+	}, func(msg jetstream.Msg) error {
+		return ix.handle(ctx, msg)
+	})
+}
+
+func (ix *Indexer) handle(ctx context.Context, msg jetstream.Msg) error {
+	// spheres.content.<action>.<sphereId>
+	toks := strings.Split(msg.Subject(), ".")
+	if len(toks) < 4 {
+		return nil
+	}
+
+	var evt struct {
+		ID string `json:"_id"`
+	}
+	if err := json.Unmarshal(msg.Data(), &evt); err != nil || evt.ID == "" {
+		logrus.WithError(err).Warnf("search indexer: bad payload on %s", msg.Subject())
+		return nil
+	}
+
+	if toks[2] == "deleted" {
+		return ix.TS.Delete(ctx, ix.Collection, evt.ID)
+	}
+	return ix.Reindex(ctx, evt.ID)
+}
+
+const backfillBatch = 500
+
+// contenido indexable: lo mismo que ven los lectores
+var visibleFilter = bson.M{"deleted": false, "hidden": bson.M{"$ne": true}, "mediaPending": bson.M{"$ne": true}}
+
+type indexedContent struct {
+	models.SphereContent `bson:",inline"`
+	UpdatedAt            time.Time `bson:"updatedAt"`
+}
+
+// Backfill pagina sphere_contents por _id y sube todo lo visible en bloques.
+// Corre junto al consumer: después de cada bloque se revisan los que cambiaron
+// mientras se subía, para que un delete/edit procesado antes no quede pisado.
+func (ix *Indexer) Backfill(ctx context.Context) error {
+	started := time.Now()
+	total, failed := 0, 0
+	after := bson.NilObjectID
+	for {
+		readAt := time.Now()
+		filter := bson.M{"_id": bson.M{"$gt": after}}
+		for k, v := range visibleFilter {
+			filter[k] = v
+		}
+		cur, err := ix.Contents.Find(ctx, filter,
+			options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(backfillBatch))
+		if err != nil {
+			return err
+		}
+		var batch []indexedContent
+		err = cur.All(ctx, &batch)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		after = batch[len(batch)-1].ID
+
+		names, err := ix.authorNames(ctx, batch)
+		if err != nil {
+			return err
+		}
+		docs := make([]any, 0, len(batch))
+		ids := make([]bson.ObjectID, 0, len(batch))
+		for i := range batch {
+			docs = append(docs, contentDoc(&batch[i], names[batch[i].UserID]))
+			ids = append(ids, batch[i].ID)
+		}
+		n, err := ix.TS.Import(ctx, ix.Collection, docs)
+		if err != nil && n == 0 {
+			return err
+		}
+		if n > 0 {
+			logrus.WithError(err).Warnf("search indexer: %d documents failed to import", n)
+		}
+		total += len(batch) - n
+		failed += n
+
+		if err := ix.recheck(ctx, ids, readAt); err != nil {
+			return err
+		}
+	}
+	logrus.Infof("search indexer: backfill of %s done, %d indexed, %d failed in %s", ix.Collection, total, failed, time.Since(started).Round(time.Second))
+	return nil
+}
+
+// recheck vuelve a indexar los del bloque que dejaron de ser visibles, se editaron o se
+// borraron desde que se leyeron (los cambios posteriores llegan por el consumer).
+func (ix *Indexer) recheck(ctx context.Context, ids []bson.ObjectID, readAt time.Time) error {
+	cur, err := ix.Contents.Find(ctx, bson.M{"_id": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"deleted": 1, "hidden": 1, "mediaPending": 1, "updatedAt": 1}))
+	if err != nil {
+		return err
+	}
+	var now []indexedContent
+	if err := cur.All(ctx, &now); err != nil {
+		return err
+	}
+
+	// margen por diferencias de reloj entre réplicas
+	since := readAt.Add(-5 * time.Second)
+	seen := make(map[bson.ObjectID]bool, len(now))
+	for _, c := range now {
+		seen[c.ID] = true
+		if c.Deleted || c.Hidden || c.MediaPending || c.UpdatedAt.After(since) {
+			if err := ix.Reindex(ctx, c.ID.Hex()); err != nil {
+				return err
+			}
+		}
+	}
+	for _, id := range ids {
+		if !seen[id] {
+			if err := ix.TS.Delete(ctx, ix.Collection, id.Hex()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (ix *Indexer) authorNames(ctx context.Context, batch []indexedContent) (map[bson.ObjectID]string, error) {
+	ids := make([]bson.ObjectID, 0, len(batch))
+	for _, c := range batch {
+		ids = append(ids, c.UserID)
+	}
+	cur, err := ix.Wallets.Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Find().SetProjection(bson.M{"fullName": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var authors []struct {
+		ID       bson.ObjectID `bson:"_id"`
+		FullName string        `bson:"fullName"`
+	}
+	if err := cur.All(ctx, &authors); err != nil {
+		return nil, err
+	}
+	names := make(map[bson.ObjectID]string, len(authors))
+	for _, a := range authors {
+		names[a.ID] = a.FullName
+	}
+	return names, nil
+}
+
+// Reindex lee el contenido de mongo y lo sube (o lo saca si ya no es visible).
+func (ix *Indexer) Reindex(ctx context.Context, id string) error {
+	oid, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil
+	}
+
+	var c indexedContent
+	err = ix.Contents.FindOne(ctx, bson.M{"_id": oid}).Decode(&c)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && (c.Deleted || c.Hidden || c.MediaPending)) {
+		return ix.TS.Delete(ctx, ix.Collection, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	var author struct {
+		FullName string `bson:"fullName"`
+	}
+	_ = ix.Wallets.FindOne(ctx, bson.M{"_id": c.UserID}).Decode(&author)
+
+	return ix.TS.Upsert(ctx, ix.Collection, contentDoc(&c, author.FullName))
+}
+
+func contentDoc(c *indexedContent, authorName string) typesense.SphereContentDoc {
+	doc := typesense.SphereContentDoc{
+		ID:            c.ID.Hex(),
+		SphereID:      c.SphereID,
+		UserID:        c.UserID.Hex(),
+		AuthorName:    authorName,
+		Type:          c.Type,
+		Text:          c.Text,
+		Mentions:      Mentions(c.Text),
+		Cashtags:      Cashtags(c.Text),
+		HasMedia:      len(c.MediaUrls) > 0 || len(c.MediaIDs) > 0,
+		IsReply:       c.ParentID != nil,
+		CreatedAtUnix: c.CreatedAt.Unix(),
+	}
+	if c.ParentID != nil {
+		doc.ParentID = c.ParentID.Hex()
+	}
+	return doc
+}
@@ -0,0 +1,36 @@
+package search
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	// @usuario (sin capturar emails: el @ no puede venir pegado a una palabra)
+	mentionRe = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_]{2,32})\b`)
+	// $TICKER: empieza con letra para no tomar montos ("$5")
+	cashtagRe = regexp.MustCompile(`(?:^|[^\w$])\$([A-Za-z][A-Za-z0-9]{0,9})\b`)
+)
+
+// Mentions devuelve los @handles en minúscula, sin repetir. Para notificaciones solo
+// cuentan los @<userId> (ver notifications.resolveMentions).
+func Mentions(text string) []string {
+	return collect(mentionRe, text, strings.ToLower)
+}
+
+// Cashtags devuelve los $TICKER en mayúscula, sin repetir.
+func Cashtags(text string) []string {
+	return collect(cashtagRe, text, strings.ToUpper)
+}
+
+func collect(re *regexp.Regexp, text string, norm func(string) string) []string {
+	out := []string{}
+	for _, m := range re.FindAllStringSubmatch(text, -1) {
+		v := norm(m[1])
+		if !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"net/http"
+	"time"
 
 	lksdk "github.com/livekit/server-sdk-go/v2"
 	"github.com/sirupsen/logrus"
@@ -21,7 +22,8 @@ type Service struct {
 	collSessions *mongo.Collection
 	collWallets  *mongo.Collection
 
-	store *Store
+	store   *Store
+	limiter *ownhttp.RateLimiter
 
 	ctx context.Context
 
@@ -63,6 +65,9 @@ func (s *Service) Config(ctx context.Context) {
 		logrus.Fatal(err)
 	}
 
+	// waves no usa NATS: buckets en memoria por réplica
+	s.limiter = system.NewRateLimiter(ctx, nil)
+
 	s.livekitClient = lksdk.NewRoomServiceClient(s.livekitURL, s.livekitApiKey, s.livekitApiSecret)
 	logrus.Infof("%s configured", constants.WavesServiceName)
 }
@@ -77,7 +82,7 @@ func (s *Service) Start(sys *system.System) {
 
 func (s *Service) routes() *http.ServeMux {
 	mux := ownhttp.Routes()
-	mux.HandleFunc("/waves/join", s.handleJoin)
+	mux.HandleFunc("/waves/join", s.limiter.Limit("waves.join", ownhttp.Per(20, time.Minute, ownhttp.KeyByIP), s.handleJoin))
 	mux.HandleFunc("/waves/webhook", s.handleWebhook)
 	mux.HandleFunc("/waves/stats", s.handleStats)
 	return mux